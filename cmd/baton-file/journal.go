@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/conductorone/baton-file/pkg/connector"
+
+	"github.com/spf13/cobra"
+)
+
+// journalFlags holds the flags shared by the journal subcommands, locating and reading the input file.
+type journalFlags struct {
+	input       string
+	mappingFile string
+	inputFormat string
+}
+
+// register adds the flags to a journal subcommand.
+func (f *journalFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.input, inputFileField.FieldName, "i", "", "Path to the input file whose journal is read")
+	cmd.Flags().StringVar(&f.mappingFile, mappingFileField.FieldName, "", "Path to the YAML mapping file used to read the input file")
+	cmd.Flags().StringVar(&f.inputFormat, inputFormatField.FieldName, "auto", "Format of the input file")
+	_ = cmd.MarkFlagRequired(inputFileField.FieldName)
+}
+
+// newJournalCommand creates the journal command, whose subcommands read the change journal kept next to the input file.
+func newJournalCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "journal",
+		Short: "Inspect the journal of changes written back to the input file",
+		Long: `Every change the connector writes back to the input file is appended to a journal next to it (<input>.journal.jsonl).
+The version of the file before the first journaled change is kept as the journal's baseline (<input name>.journal-baseline.<ext>).`,
+	}
+	cmd.AddCommand(newJournalReplayCommand(), newJournalVerifyCommand())
+	return cmd
+}
+
+// newJournalReplayCommand creates the journal replay command, which applies the journal to its baseline.
+func newJournalReplayCommand() *cobra.Command {
+	var flags journalFlags
+	var output string
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Apply the journaled changes to the baseline, optionally writing the result to a new file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loadOptions, err := newLoadOptions(flags.mappingFile, flags.inputFormat)
+			if err != nil {
+				return err
+			}
+			replay, err := connector.ReplayJournal(cmd.Context(), flags.input, loadOptions)
+			if err != nil {
+				return err
+			}
+			if output != "" {
+				if err := connector.WriteFileData(output, replay.Data); err != nil {
+					return fmt.Errorf("failed to write %s: %w", output, err)
+				}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Replayed %d journal entries over baseline %s, ending at %s\n", replay.Entries, replay.BaselineHash, replay.FinalHash)
+			return nil
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the replayed records to this YAML, JSON or Excel file")
+	return cmd
+}
+
+// newJournalVerifyCommand creates the journal verify command, which fails unless the input file equals the replayed journal.
+func newJournalVerifyCommand() *cobra.Command {
+	var flags journalFlags
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check that the input file equals the baseline with every journaled change applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			loadOptions, err := newLoadOptions(flags.mappingFile, flags.inputFormat)
+			if err != nil {
+				return err
+			}
+			entries, err := connector.VerifyJournal(cmd.Context(), flags.input, loadOptions)
+			if err != nil {
+				return fmt.Errorf("journal verification failed: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s matches its baseline with %d journaled changes applied\n", flags.input, entries)
+			return nil
+		},
+	}
+	flags.register(cmd)
+	return cmd
+}
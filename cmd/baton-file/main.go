@@ -25,8 +25,60 @@ var inputFileField = field.StringField(
 	field.WithShortHand("i"),
 )
 
+var mappingFileField = field.StringField(
+	"mapping-file",
+	field.WithDescription("Path to a YAML mapping file describing the input file layout (sheet names, named tables, header rows, column mappings)"),
+)
+
+var inputFormatField = field.StringField(
+	"input-format",
+	field.WithDescription("Format of the input file: auto (detect from the file extension), xlsx, ods, xls, yaml, json, ndjson, ldif, scim, host-snapshot, kubernetes-rbac, aws-iam or sqlite"),
+	field.WithDefaultValue("auto"),
+)
+
+var resourcesPageSizeField = field.IntField(
+	"resources-page-size",
+	field.WithDescription("Number of resources returned per page"),
+	field.WithDefaultValue(50),
+)
+
+var entitlementsPageSizeField = field.IntField(
+	"entitlements-page-size",
+	field.WithDescription("Number of entitlements returned per page"),
+	field.WithDefaultValue(50),
+)
+
+var grantsPageSizeField = field.IntField(
+	"grants-page-size",
+	field.WithDescription("Number of grants returned per page"),
+	field.WithDefaultValue(50),
+)
+
+var gitField = field.BoolField(
+	"git",
+	field.WithDescription("Git-backed mode: the input file is in a git working tree, syncs record the commit they read, and every change written back is committed"),
+)
+
+var gitBranchField = field.StringField(
+	"git-branch",
+	field.WithDescription("In git-backed mode, commit changes to this branch instead of the checked-out branch, leaving the working tree untouched"),
+)
+
+var watchField = field.BoolField(
+	"watch",
+	field.WithDescription("Service mode only: watch the input file and report the grants added to or removed from it through the event feed, so edits reach ConductorOne without waiting for the next sync"),
+)
+
 var ConfigurationFields = []field.SchemaField{
 	inputFileField,
+	mappingFileField,
+	inputFormatField,
+	resourcesPageSizeField,
+	entitlementsPageSizeField,
+	grantsPageSizeField,
+	gitField,
+	gitBranchField,
+	watchField,
 }
 
 func main() {
@@ -50,10 +102,11 @@ func main() {
 
 	// Set command usage details.
 	cmd.Use = "baton-file"
-	cmd.Short = "Process data files (xlsx, yaml, json) into Baton resources"
-	cmd.Long = `baton-file processes structured data files (.xlsx, .yaml, .json) containing resource, entitlement, and grant data.
+	cmd.Short = "Process data files (xlsx, ods, xls, yaml, json, ndjson, ldif, sqlite) into Baton resources"
+	cmd.Long = `baton-file processes structured data files (.xlsx, .ods, .xls, .yaml, .json, .ndjson/.jsonl, .ldif, .db/.sqlite) containing resource, entitlement, and grant data.
 
 It expects the data to be organized into specific sheets (Excel) or top-level keys (YAML/JSON): 'users', 'resources', 'entitlements', 'grants'.
+NDJSON files hold one record per line, tagged with its section through a 'kind' key, LDIF exports are mapped by object class, and SQLite databases hold one table per section.
 
 By default (without --client-id and --client-secret flags), it generates a C1Z file compatible with ConductorOne.
 If authentication flags are provided, it runs as a direct connector.`
@@ -66,6 +119,8 @@ If authentication flags are provided, it runs as a direct connector.`
 		pflag.Shorthand = "s"
 	}
 
+	cmd.AddCommand(newJournalCommand())
+
 	err = cmd.Execute()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error executing command:", err.Error())
@@ -88,14 +143,48 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 		return nil, fmt.Errorf("input file not found: %s", inputFile)
 	}
 
-	fc, err := connector.NewFileConnector(ctx, inputFile)
+	pageSizes := connector.PageSizes{
+		Resources:    v.GetInt(resourcesPageSizeField.FieldName),
+		Entitlements: v.GetInt(entitlementsPageSizeField.FieldName),
+		Grants:       v.GetInt(grantsPageSizeField.FieldName),
+	}
+	if pageSizes.Resources <= 0 || pageSizes.Entitlements <= 0 || pageSizes.Grants <= 0 {
+		return nil, fmt.Errorf("page sizes must be greater than zero")
+	}
+
+	loadOptions, err := newLoadOptions(v.GetString(mappingFileField.FieldName), v.GetString(inputFormatField.FieldName))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []connector.Option{
+		connector.WithPageSizes(pageSizes),
+		connector.WithLoadOptions(loadOptions),
+	}
+	gitBranch := v.GetString(gitBranchField.FieldName)
+	if v.GetBool(gitField.FieldName) {
+		opts = append(opts, connector.WithGit(connector.GitOptions{Branch: gitBranch}))
+	} else if gitBranch != "" {
+		return nil, fmt.Errorf("--git-branch requires --git")
+	}
+
+	fc, err := connector.NewFileConnector(ctx, inputFile, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file connector: %w", err)
 	}
 
-	// Use the connector builder to create the gRPC server instance.
-	// The wraps our FileConnector.
-	c, err := connectorbuilder.NewConnector(ctx, fc)
+	// In watch mode the FileConnector is wrapped so it also provides the event feed.
+	var builder connectorbuilder.ConnectorBuilder = fc
+	if v.GetBool(watchField.FieldName) {
+		if v.GetString("client-id") == "" {
+			return nil, fmt.Errorf("--watch requires service mode (--client-id and --client-secret)")
+		}
+		builder = connector.NewWatchingConnector(ctx, fc, connector.WatchOptions{})
+	}
+
+	// Create the gRPC server instance, which wraps our FileConnector.
+	// It fails when the input file cannot be loaded, and picks up resource types added to the file later.
+	c, err := connector.NewConnectorServer(ctx, builder)
 	if err != nil {
 		l.Error("Error creating connector server", zap.Error(err))
 		return nil, fmt.Errorf("failed to create connector server: %w", err)
@@ -103,3 +192,24 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 
 	return c, nil
 }
+
+// newLoadOptions builds the options for reading the input file from the --mapping-file and --input-format values.
+func newLoadOptions(mappingFile string, formatName string) (*connector.LoadOptions, error) {
+	loadOptions := &connector.LoadOptions{}
+	if mappingFile != "" {
+		mapped, err := connector.LoadMappingFile(mappingFile)
+		if err != nil {
+			return nil, err
+		}
+		loadOptions = mapped
+	}
+	// An explicit --input-format overrides the format set in the mapping file.
+	if formatName != "" && formatName != "auto" {
+		format, err := connector.ParseInputFormat(formatName)
+		if err != nil {
+			return nil, err
+		}
+		loadOptions.Format = format
+	}
+	return loadOptions, nil
+}
@@ -0,0 +1,143 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+)
+
+// userResourceTypeId is the ID of the resource type built from the users section.
+const userResourceTypeId = "user"
+
+// userSyncer is the syncer for the users section. It adds account provisioning to fileSyncer.
+// The SDK accepts a single account manager per connector, so only the "user" resource type gets one.
+type userSyncer struct {
+	*fileSyncer
+}
+
+// newUserSyncer creates a userSyncer for the user resource type.
+func newUserSyncer(rt *v2.ResourceType, store *dataStore, pageSizes PageSizes) *userSyncer {
+	return &userSyncer{fileSyncer: newFileSyncer(rt, store, pageSizes)}
+}
+
+// The CreateAccount method adds a user to the users section of the input file.
+// It implements the CreateAccount method, required by the connectorbuilder.AccountManager interface.
+// The login becomes the user's name, the primary email (or the first one) its email, and the remaining profile
+// attributes its profile; display_name, email and type profile keys fill the matching fields.
+// When a user with the same login already exists it is returned unchanged, so a retried request never creates a duplicate.
+// No credential is generated, since the file holds no passwords.
+func (us *userSyncer) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	credentialOptions *v2.CredentialOptions,
+) (connectorbuilder.CreateAccountResponse, []*v2.PlaintextData, annotations.Annotations, error) {
+	if credentialOptions.GetRandomPassword() != nil {
+		return nil, nil, nil, fmt.Errorf("CreateAccount: only accounts without a password can be created")
+	}
+
+	userData, err := accountUserData(accountInfo)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("CreateAccount: %w", err)
+	}
+
+	var applied *LoadedData
+	change := fileChange{
+		operation: "create account " + userData.Name,
+		principal: userResourceTypeId + ":" + userData.Name,
+		apply: func(data *LoadedData) error {
+			applied = data
+			for _, u := range data.Users {
+				if u.Name == userData.Name {
+					return errNoChange
+				}
+			}
+			if recordExists(data, userData.Name) {
+				return fmt.Errorf("a resource named %q already exists", userData.Name)
+			}
+			data.Users = append(data.Users, userData)
+			return nil
+		},
+	}
+	if err := us.store.update(ctx, change); err != nil {
+		return nil, nil, nil, fmt.Errorf("CreateAccount: %w", err)
+	}
+
+	created, err := us.store.writtenResource(ctx, userData.Name, applied)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("CreateAccount: %w", err)
+	}
+	return &v2.CreateAccountResponse_SuccessResult{Resource: created, IsCreateAccountResult: true}, nil, nil, nil
+}
+
+// The CreateAccountCapabilityDetails method reports the credential options supported by CreateAccount.
+// It implements the CreateAccountCapabilityDetails method, required by the connectorbuilder.AccountManager interface.
+// Accounts are only created without a password.
+func (us *userSyncer) CreateAccountCapabilityDetails(ctx context.Context) (*v2.CredentialDetailsAccountProvisioning, annotations.Annotations, error) {
+	return &v2.CredentialDetailsAccountProvisioning{
+		SupportedCredentialOptions: []v2.CapabilityDetailCredentialOption{
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+		},
+		PreferredCredentialOption: v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+	}, nil, nil
+}
+
+// accountUserData maps the account information of a CreateAccount request onto a new users row.
+func accountUserData(accountInfo *v2.AccountInfo) (UserData, error) {
+	login := strings.TrimSpace(accountInfo.GetLogin())
+	if login == "" {
+		return UserData{}, fmt.Errorf("login is required")
+	}
+
+	profile := make(map[string]interface{})
+	if accountInfo.GetProfile() != nil {
+		profile = accountInfo.GetProfile().AsMap()
+	}
+	takeString := func(keys ...string) string {
+		for _, key := range keys {
+			if v, ok := profile[key].(string); ok {
+				delete(profile, key)
+				if v != "" {
+					return v
+				}
+			}
+		}
+		return ""
+	}
+
+	email := ""
+	for _, e := range accountInfo.GetEmails() {
+		if e.GetIsPrimary() {
+			email = e.GetAddress()
+			break
+		}
+	}
+	if email == "" && len(accountInfo.GetEmails()) > 0 {
+		email = accountInfo.GetEmails()[0].GetAddress()
+	}
+	if profileEmail := takeString("email"); email == "" {
+		email = profileEmail
+	}
+
+	displayName := takeString("display_name", "displayName")
+	if displayName == "" {
+		firstName, _ := profile["first_name"].(string)
+		lastName, _ := profile["last_name"].(string)
+		displayName = strings.TrimSpace(firstName + " " + lastName)
+	}
+	if displayName == "" {
+		displayName = login
+	}
+
+	return UserData{
+		Name:        login,
+		DisplayName: displayName,
+		Email:       email,
+		Status:      "enabled",
+		Type:        takeString("type", "account_type"),
+		Profile:     profile,
+	}, nil
+}
@@ -0,0 +1,458 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	configv1 "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Names of the custom actions exposed by the connector.
+const (
+	actionDisableUser       = "disable_user"
+	actionUpdateUserProfile = "update_user_profile"
+	actionSetResourceOwner  = "set_resource_owner"
+	actionValidateFile      = "validate_file"
+)
+
+// maxActionResults bounds the number of completed action results kept for GetActionStatus.
+const maxActionResults = 1000
+
+// actionHandler runs a custom action with its ID and arguments and returns its response.
+// Changes made by the action are journaled with the action ID as their request ID.
+type actionHandler func(ctx context.Context, id string, args *structpb.Struct) (*structpb.Struct, error)
+
+// The actionResult struct holds the outcome of an invoked action, returned by GetActionStatus.
+type actionResult struct {
+	name     string
+	status   v2.BatonActionStatus
+	response *structpb.Struct
+}
+
+// The fileActionManager struct runs maintenance actions against the input file.
+// Actions that change the file go through dataStore.update, so each one is a single atomic edit of the file's current content.
+// Actions complete before InvokeAction returns; their results are kept so GetActionStatus can report them.
+type fileActionManager struct {
+	store    *dataStore
+	schemas  map[string]*v2.BatonActionSchema
+	handlers map[string]actionHandler
+
+	mu      sync.Mutex
+	results map[string]actionResult
+	order   []string // Result IDs, oldest first
+}
+
+// newFileActionManager creates the action manager for the given data store.
+func newFileActionManager(store *dataStore) *fileActionManager {
+	am := &fileActionManager{
+		store:   store,
+		results: make(map[string]actionResult),
+	}
+	am.schemas = map[string]*v2.BatonActionSchema{
+		actionDisableUser: {
+			Name:        actionDisableUser,
+			DisplayName: "Disable User",
+			Description: "Sets the status of a user in the input file to disabled.",
+			Arguments: []*configv1.Field{
+				stringActionField("user_id", "User", "Name of the user to disable", true),
+				ticketActionField(),
+			},
+			ReturnTypes: []*configv1.Field{
+				boolActionField("success", "Success", "Whether the user is disabled"),
+			},
+		},
+		actionUpdateUserProfile: {
+			Name:        actionUpdateUserProfile,
+			DisplayName: "Update User Profile",
+			Description: "Merges attributes into the profile of a user in the input file. Attributes set to null are removed.",
+			Arguments: []*configv1.Field{
+				stringActionField("user_id", "User", "Name of the user to update", true),
+				stringMapActionField("profile", "Profile", "Profile attributes to set, or null to remove", true),
+				ticketActionField(),
+			},
+			ReturnTypes: []*configv1.Field{
+				boolActionField("success", "Success", "Whether the profile was updated"),
+				stringMapActionField("profile", "Profile", "The user's profile after the update", false),
+			},
+		},
+		actionSetResourceOwner: {
+			Name:        actionSetResourceOwner,
+			DisplayName: "Set Resource Owner",
+			Description: "Sets the owner of a secret resource in the input file. An empty owner removes it.",
+			Arguments: []*configv1.Field{
+				stringActionField("resource_id", "Resource", "Name of the secret resource", true),
+				stringActionField("owner_id", "Owner", "Name of the user or resource owning the secret", false),
+				ticketActionField(),
+			},
+			ReturnTypes: []*configv1.Field{
+				boolActionField("success", "Success", "Whether the owner was set"),
+			},
+		},
+		actionValidateFile: {
+			Name:        actionValidateFile,
+			DisplayName: "Validate File",
+			Description: "Reads the input file and reports duplicate names, dangling references and values that cannot be parsed.",
+			ReturnTypes: []*configv1.Field{
+				boolActionField("valid", "Valid", "Whether the file has no errors"),
+				intActionField("users", "Users", "Number of users in the file"),
+				intActionField("resources", "Resources", "Number of resources in the file"),
+				intActionField("entitlements", "Entitlements", "Number of entitlements in the file"),
+				intActionField("grants", "Grants", "Number of grants in the file"),
+				intActionField("errors", "Errors", "Number of errors found"),
+				intActionField("warnings", "Warnings", "Number of warnings found"),
+				listActionField("issues", "Issues", "Errors and warnings found, each with its severity, section, record and message"),
+				stringActionField("error", "Error", "Why the file could not be read, when it could not", false),
+			},
+		},
+	}
+	am.handlers = map[string]actionHandler{
+		actionDisableUser:       am.disableUser,
+		actionUpdateUserProfile: am.updateUserProfile,
+		actionSetResourceOwner:  am.setResourceOwner,
+		actionValidateFile:      am.validateFile,
+	}
+	return am
+}
+
+// The RegisterActionManager method returns the manager of the connector's custom actions.
+// It implements the RegisterActionManager method, required by the connectorbuilder.RegisterActionManager interface.
+func (fc *FileConnector) RegisterActionManager(ctx context.Context) (connectorbuilder.CustomActionManager, error) {
+	return newFileActionManager(fc.store), nil
+}
+
+// The ListActionSchemas method returns the schemas of all custom actions, sorted by name.
+// It implements the ListActionSchemas method, required by the connectorbuilder.CustomActionManager interface.
+func (am *fileActionManager) ListActionSchemas(ctx context.Context) ([]*v2.BatonActionSchema, annotations.Annotations, error) {
+	names := make([]string, 0, len(am.schemas))
+	for name := range am.schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	schemas := make([]*v2.BatonActionSchema, 0, len(names))
+	for _, name := range names {
+		schemas = append(schemas, am.schemas[name])
+	}
+	return schemas, nil, nil
+}
+
+// The GetActionSchema method returns the schema of a custom action.
+// It implements the GetActionSchema method, required by the connectorbuilder.CustomActionManager interface.
+func (am *fileActionManager) GetActionSchema(ctx context.Context, name string) (*v2.BatonActionSchema, annotations.Annotations, error) {
+	schema, ok := am.schemas[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("GetActionSchema: unknown action %q", name)
+	}
+	return schema, nil, nil
+}
+
+// The InvokeAction method runs a custom action and returns its ID, status and response.
+// It implements the InvokeAction method, required by the connectorbuilder.CustomActionManager interface.
+// Required arguments are checked against the action's schema before the action runs.
+func (am *fileActionManager) InvokeAction(
+	ctx context.Context,
+	name string,
+	args *structpb.Struct,
+) (string, v2.BatonActionStatus, *structpb.Struct, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	schema, ok := am.schemas[name]
+	if !ok {
+		return "", v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED, nil, nil, fmt.Errorf("InvokeAction: unknown action %q", name)
+	}
+	for _, arg := range schema.GetArguments() {
+		if arg.GetIsRequired() && !hasActionArg(args, arg.GetName()) {
+			return "", v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED, nil, nil, fmt.Errorf("InvokeAction: %s: argument %s is required", name, arg.GetName())
+		}
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED, nil, nil, fmt.Errorf("InvokeAction: %s: failed to generate action ID: %w", name, err)
+	}
+	id := hex.EncodeToString(b)
+
+	response, err := am.handlers[name](ctx, id, args)
+	if err != nil {
+		return "", v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED, nil, nil, fmt.Errorf("InvokeAction: %s: %w", name, err)
+	}
+
+	am.storeResult(id, actionResult{name: name, status: v2.BatonActionStatus_BATON_ACTION_STATUS_COMPLETE, response: response})
+	l.Info("Invoked custom action", zap.String("action", name), zap.String("action_id", id))
+	return id, v2.BatonActionStatus_BATON_ACTION_STATUS_COMPLETE, response, nil, nil
+}
+
+// The GetActionStatus method returns the status, name and response of an invoked action.
+// It implements the GetActionStatus method, required by the connectorbuilder.CustomActionManager interface.
+// Only the most recent results are kept, and they are lost when the connector restarts.
+func (am *fileActionManager) GetActionStatus(ctx context.Context, id string) (v2.BatonActionStatus, string, *structpb.Struct, annotations.Annotations, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	result, ok := am.results[id]
+	if !ok {
+		return v2.BatonActionStatus_BATON_ACTION_STATUS_UNKNOWN, "", nil, nil, fmt.Errorf("GetActionStatus: unknown action ID %q", id)
+	}
+	return result.status, result.name, result.response, nil, nil
+}
+
+// storeResult keeps the result of an action under its ID, dropping the oldest result once maxActionResults is reached.
+func (am *fileActionManager) storeResult(id string, result actionResult) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if len(am.order) >= maxActionResults {
+		delete(am.results, am.order[0])
+		am.order = am.order[1:]
+	}
+	am.results[id] = result
+	am.order = append(am.order, id)
+}
+
+// disableUser sets the status of a user to disabled. Disabling a disabled user succeeds without rewriting the file.
+func (am *fileActionManager) disableUser(ctx context.Context, id string, args *structpb.Struct) (*structpb.Struct, error) {
+	name := actionStringArg(args, "user_id")
+
+	change := fileChange{
+		operation: "disable user " + name,
+		principal: userResourceTypeId + ":" + name,
+		requestID: id,
+		ticket:    actionStringArg(args, "ticket_id"),
+		apply: func(data *LoadedData) error {
+			for i := range data.Users {
+				u := &data.Users[i]
+				if u.Name != name {
+					continue
+				}
+				if strings.EqualFold(strings.TrimSpace(u.Status), "disabled") {
+					return errNoChange
+				}
+				u.Status = "disabled"
+				return nil
+			}
+			return fmt.Errorf("user %q not found", name)
+		},
+	}
+	if err := am.store.update(ctx, change); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(map[string]interface{}{"success": true})
+}
+
+// updateUserProfile merges attributes into the profile of a user. Attributes with a null value are removed.
+func (am *fileActionManager) updateUserProfile(ctx context.Context, id string, args *structpb.Struct) (*structpb.Struct, error) {
+	name := actionStringArg(args, "user_id")
+	updates := args.GetFields()["profile"].GetStructValue().AsMap()
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("argument profile must be a non-empty object")
+	}
+
+	var profile map[string]interface{}
+	change := fileChange{
+		operation: "update profile of user " + name,
+		principal: userResourceTypeId + ":" + name,
+		requestID: id,
+		ticket:    actionStringArg(args, "ticket_id"),
+		apply: func(data *LoadedData) error {
+			for i := range data.Users {
+				u := &data.Users[i]
+				if u.Name != name {
+					continue
+				}
+				if u.Profile == nil {
+					u.Profile = make(map[string]interface{})
+				}
+				changed := false
+				for key, value := range updates {
+					current, exists := u.Profile[key]
+					switch {
+					case value == nil && exists:
+						delete(u.Profile, key)
+						changed = true
+					case value != nil && (!exists || fmt.Sprint(current) != fmt.Sprint(value)):
+						u.Profile[key] = value
+						changed = true
+					}
+				}
+				profile = u.Profile
+				if !changed {
+					return errNoChange
+				}
+				return nil
+			}
+			return fmt.Errorf("user %q not found", name)
+		},
+	}
+	if err := am.store.update(ctx, change); err != nil {
+		return nil, err
+	}
+	return newActionResponse(map[string]interface{}{"success": true, "profile": profile})
+}
+
+// setResourceOwner sets or clears the owner of a secret resource. The owner must be a user or resource defined in the file.
+func (am *fileActionManager) setResourceOwner(ctx context.Context, id string, args *structpb.Struct) (*structpb.Struct, error) {
+	name := actionStringArg(args, "resource_id")
+	owner := strings.TrimSpace(actionStringArg(args, "owner_id"))
+	if owner == name {
+		return nil, fmt.Errorf("resource %q cannot own itself", name)
+	}
+
+	// The change is recorded against the resource's type, read from the file as it was last loaded
+	snapshot, err := am.store.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resource, ok := snapshot.resources[name]
+	if !ok {
+		return nil, fmt.Errorf("resource %q not found", name)
+	}
+
+	change := fileChange{
+		operation: "set owner of resource " + name,
+		resource:  resource.GetId().GetResourceType() + ":" + name,
+		requestID: id,
+		ticket:    actionStringArg(args, "ticket_id"),
+		apply: func(data *LoadedData) error {
+			if owner != "" && !recordExists(data, owner) {
+				return fmt.Errorf("owner %q not found", owner)
+			}
+			for i := range data.Resources {
+				r := &data.Resources[i]
+				if r.Name != name {
+					continue
+				}
+				if !strings.EqualFold(r.ResourceFunction, "secret") {
+					return fmt.Errorf("resource %q is not a secret", name)
+				}
+				if r.Owner == owner {
+					return errNoChange
+				}
+				r.Owner = owner
+				return nil
+			}
+			return fmt.Errorf("resource %q not found", name)
+		},
+	}
+	if err := am.store.update(ctx, change); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(map[string]interface{}{"success": true})
+}
+
+// validateFile reads the input file and returns its validation report, with "valid" set when it has no errors.
+// A file that cannot be read at all is reported as invalid with the read error, rather than failing the action.
+func (am *fileActionManager) validateFile(ctx context.Context, id string, args *structpb.Struct) (*structpb.Struct, error) {
+	data, err := LoadFileData(ctx, am.store.filePath, am.store.loadOptions)
+	if err != nil {
+		return structpb.NewStruct(map[string]interface{}{"valid": false, "error": err.Error()})
+	}
+	report := validateLoadedData(data)
+	return newActionResponse(struct {
+		Valid bool `json:"valid"`
+		*validationReport
+	}{Valid: report.Errors == 0, validationReport: report})
+}
+
+// newActionResponse converts a value to an action response through its JSON form,
+// which gives the field names and the plain values a structpb.Struct can hold.
+func newActionResponse(v interface{}) (*structpb.Struct, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode action response: %w", err)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("failed to encode action response: %w", err)
+	}
+	return structpb.NewStruct(fields)
+}
+
+// hasActionArg reports whether an argument is set to a non-null, non-empty value.
+func hasActionArg(args *structpb.Struct, name string) bool {
+	value, ok := args.GetFields()[name]
+	if !ok {
+		return false
+	}
+	switch v := value.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		return false
+	case *structpb.Value_StringValue:
+		return strings.TrimSpace(v.StringValue) != ""
+	}
+	return true
+}
+
+// actionStringArg returns a string argument, or "" when it is missing or not a string.
+func actionStringArg(args *structpb.Struct, name string) string {
+	return args.GetFields()[name].GetStringValue()
+}
+
+// stringActionField returns the schema of a string argument or return value.
+func stringActionField(name, displayName, description string, required bool) *configv1.Field {
+	return &configv1.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		IsRequired:  required,
+		Field:       &configv1.Field_StringField{StringField: &configv1.StringField{}},
+	}
+}
+
+// ticketActionField returns the schema of the optional ticket argument of actions that change the file.
+// The ticket is recorded in the commit of the change in git-backed mode.
+func ticketActionField() *configv1.Field {
+	return stringActionField("ticket_id", "Ticket", "ID of the ticket requesting the change, recorded with the change", false)
+}
+
+// stringMapActionField returns the schema of an object argument or return value.
+func stringMapActionField(name, displayName, description string, required bool) *configv1.Field {
+	return &configv1.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		IsRequired:  required,
+		Field:       &configv1.Field_StringMapField{StringMapField: &configv1.StringMapField{}},
+	}
+}
+
+// intActionField returns the schema of an integer return value.
+func intActionField(name, displayName, description string) *configv1.Field {
+	return &configv1.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		Field:       &configv1.Field_IntField{IntField: &configv1.IntField{}},
+	}
+}
+
+// listActionField returns the schema of a list return value.
+func listActionField(name, displayName, description string) *configv1.Field {
+	return &configv1.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		Field:       &configv1.Field_StringSliceField{StringSliceField: &configv1.StringSliceField{}},
+	}
+}
+
+// boolActionField returns the schema of a boolean return value.
+func boolActionField(name, displayName, description string) *configv1.Field {
+	return &configv1.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		Field:       &configv1.Field_BoolField{BoolField: &configv1.BoolField{}},
+	}
+}
@@ -0,0 +1,61 @@
+package connector
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestValidateFileSchemaDeclaresResponse(t *testing.T) {
+	filePath := writeTestFile(t, "access.yaml", watchedModel("alice"))
+	am := newFileActionManager(newDataStore(filePath))
+
+	_, _, response, _, err := am.InvokeAction(context.Background(), actionValidateFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var declared []string
+	for _, field := range am.schemas[actionValidateFile].GetReturnTypes() {
+		declared = append(declared, field.GetName())
+	}
+	for name := range response.GetFields() {
+		if !slices.Contains(declared, name) {
+			t.Errorf("validate_file returns %q, which its schema does not declare", name)
+		}
+	}
+}
+
+func TestSetResourceOwnerRecordsResourceType(t *testing.T) {
+	ctx := context.Background()
+	filePath := initTestRepo(t, "access.yaml", `users:
+  - name: alice
+resources:
+  - resource_type: api_key
+    resource_function: secret
+    name: deploy-key
+`)
+	fc, err := NewFileConnector(ctx, filePath, WithGit(GitOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	am := newFileActionManager(fc.store)
+
+	args, err := structpb.NewStruct(map[string]interface{}{"resource_id": "deploy-key", "owner_id": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, _, err := am.InvokeAction(ctx, actionSetResourceOwner, args); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitTest(t, filepath.Dir(filePath), "log", "-1", "--format=%(trailers:key=Resource)"); got != "Resource: api_key:deploy-key" {
+		t.Errorf("commit trailers = %q, want the resource with its type", got)
+	}
+
+	args.Fields["resource_id"] = structpb.NewStringValue("missing")
+	if _, _, _, _, err := am.InvokeAction(ctx, actionSetResourceOwner, args); err == nil {
+		t.Error("setting the owner of an undefined resource succeeded")
+	}
+}
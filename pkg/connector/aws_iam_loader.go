@@ -0,0 +1,427 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Resource types and entitlement slugs produced for AWS IAM authorization details.
+const (
+	iamGroupResourceType     = "iam_group"
+	iamRoleResourceType      = "iam_role"
+	iamPolicyResourceType    = "iam_policy"
+	iamAccessKeyResourceType = "iam_access_key"
+	iamMemberEntitlement     = "member"
+	iamAssumeEntitlement     = "assume"
+	iamAttachedEntitlement   = "attached"
+)
+
+// The iamAttachedPolicy struct holds a managed policy attachment.
+type iamAttachedPolicy struct {
+	PolicyName string `json:"PolicyName"`
+	PolicyArn  string `json:"PolicyArn"`
+}
+
+// The iamInlinePolicy struct holds the name of an inline policy.
+type iamInlinePolicy struct {
+	PolicyName string `json:"PolicyName"`
+}
+
+// The iamTag struct holds one IAM tag.
+type iamTag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// The iamAuthorizationDetails struct holds the parts of "aws iam get-account-authorization-details" output used by the IAM loader,
+// together with the AccessKeyMetadata list of "aws iam list-access-keys" output.
+type iamAuthorizationDetails struct {
+	UserDetailList []struct {
+		Path                    string              `json:"Path"`
+		UserName                string              `json:"UserName"`
+		UserId                  string              `json:"UserId"`
+		Arn                     string              `json:"Arn"`
+		CreateDate              string              `json:"CreateDate"`
+		GroupList               []string            `json:"GroupList"`
+		AttachedManagedPolicies []iamAttachedPolicy `json:"AttachedManagedPolicies"`
+		UserPolicyList          []iamInlinePolicy   `json:"UserPolicyList"`
+		Tags                    []iamTag            `json:"Tags"`
+	} `json:"UserDetailList"`
+	GroupDetailList []struct {
+		Path                    string              `json:"Path"`
+		GroupName               string              `json:"GroupName"`
+		GroupId                 string              `json:"GroupId"`
+		Arn                     string              `json:"Arn"`
+		AttachedManagedPolicies []iamAttachedPolicy `json:"AttachedManagedPolicies"`
+		GroupPolicyList         []iamInlinePolicy   `json:"GroupPolicyList"`
+	} `json:"GroupDetailList"`
+	RoleDetailList []struct {
+		Path                     string              `json:"Path"`
+		RoleName                 string              `json:"RoleName"`
+		RoleId                   string              `json:"RoleId"`
+		Arn                      string              `json:"Arn"`
+		Description              string              `json:"Description"`
+		AssumeRolePolicyDocument json.RawMessage     `json:"AssumeRolePolicyDocument"`
+		AttachedManagedPolicies  []iamAttachedPolicy `json:"AttachedManagedPolicies"`
+		RolePolicyList           []iamInlinePolicy   `json:"RolePolicyList"`
+	} `json:"RoleDetailList"`
+	Policies []struct {
+		PolicyName  string `json:"PolicyName"`
+		Arn         string `json:"Arn"`
+		Path        string `json:"Path"`
+		Description string `json:"Description"`
+	} `json:"Policies"`
+	AccessKeyMetadata []struct {
+		UserName    string `json:"UserName"`
+		AccessKeyId string `json:"AccessKeyId"`
+		Status      string `json:"Status"`
+		CreateDate  string `json:"CreateDate"`
+	} `json:"AccessKeyMetadata"`
+}
+
+// AWS IAM Loading Logic
+// loadAwsIamData handles the specific logic for reading "aws iam get-account-authorization-details" output.
+// The input is one JSON file or a directory whose .json files are all read and merged, so that the output of
+// "aws iam list-access-keys" can be provided next to the authorization details.
+// IAM users become users; groups, roles and managed policies become resources named by their ARN, with group membership,
+// role trust relationships and policy attachments as entitlements and grants. Access keys become secret resources owned by their user.
+func loadAwsIamData(ctx context.Context, inputPath string) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	files, err := iamInputFiles(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &iamAuthorizationDetails{}
+	for _, file := range files {
+		err := readIamFile(file, details)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	loadedData := convertIamDetails(ctx, details)
+	if len(files) > 1 || files[0] != inputPath {
+		loadedData.includedFiles = files
+	}
+	l.Debug("Loaded AWS IAM authorization details",
+		zap.String("input", inputPath),
+		zap.Int("files", len(files)),
+		zap.Int("users", len(loadedData.Users)),
+		zap.Int("resources", len(loadedData.Resources)),
+	)
+	return loadedData, nil
+}
+
+// iamInputFiles returns the files to read: the input file itself, or the sorted .json files of an input directory.
+func iamInputFiles(inputPath string) ([]string, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat AWS IAM input %s: %w", inputPath, err)
+	}
+	if !info.IsDir() {
+		return []string{inputPath}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(inputPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no .json files found in AWS IAM input directory %s", inputPath)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// readIamFile decodes every JSON value in a file and appends its lists to details.
+// Several values per file are accepted, so paginated CLI output can be concatenated.
+func readIamFile(filePath string, details *iamAuthorizationDetails) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	for {
+		var page iamAuthorizationDetails
+		err := decoder.Decode(&page)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal AWS IAM data from %s: %w", filePath, err)
+		}
+		details.UserDetailList = append(details.UserDetailList, page.UserDetailList...)
+		details.GroupDetailList = append(details.GroupDetailList, page.GroupDetailList...)
+		details.RoleDetailList = append(details.RoleDetailList, page.RoleDetailList...)
+		details.Policies = append(details.Policies, page.Policies...)
+		details.AccessKeyMetadata = append(details.AccessKeyMetadata, page.AccessKeyMetadata...)
+	}
+}
+
+// convertIamDetails maps IAM authorization details onto users, resources, entitlements and grants.
+func convertIamDetails(ctx context.Context, details *iamAuthorizationDetails) *LoadedData {
+	l := ctxzap.Extract(ctx)
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+
+	// Policies are defined first; attachments of policies missing from the input create them implicitly.
+	policies := make(map[string]struct{})
+	addPolicy := func(arn string, name string, description string) string {
+		if _, ok := policies[arn]; !ok {
+			policies[arn] = struct{}{}
+			loadedData.Resources = append(loadedData.Resources, ResourceData{
+				ResourceType:     iamPolicyResourceType,
+				ResourceFunction: "role",
+				Name:             arn,
+				DisplayName:      name,
+				Description:      description,
+			})
+			loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+				ResourceName: arn,
+				Entitlement:  iamAttachedEntitlement,
+				DisplayName:  "Attached",
+				Description:  fmt.Sprintf("Has the %s policy attached", name),
+			})
+		}
+		return arn + ":" + iamAttachedEntitlement
+	}
+	for _, p := range details.Policies {
+		addPolicy(p.Arn, p.PolicyName, p.Description)
+	}
+	attach := func(principal string, attached []iamAttachedPolicy) {
+		for _, p := range attached {
+			entitlementId := addPolicy(p.PolicyArn, p.PolicyName, "Managed policy referenced by attachments but not defined in the input")
+			loadedData.Grants = append(loadedData.Grants, GrantData{Principal: principal, EntitlementId: entitlementId})
+		}
+	}
+
+	groupsByName := make(map[string]string)
+	for _, g := range details.GroupDetailList {
+		groupsByName[g.GroupName] = g.Arn
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     iamGroupResourceType,
+			ResourceFunction: "group",
+			Name:             g.Arn,
+			DisplayName:      g.GroupName,
+			Description:      iamInlinePolicyDescription("IAM group", g.Path, g.GroupPolicyList),
+		})
+		loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+			ResourceName: g.Arn,
+			Entitlement:  iamMemberEntitlement,
+			DisplayName:  "Member",
+			Description:  fmt.Sprintf("Member of the %s group", g.GroupName),
+		})
+		// Members of the group inherit its policies, so the grant is made to the group's member entitlement.
+		attach(g.Arn+":"+iamMemberEntitlement, g.AttachedManagedPolicies)
+	}
+
+	usersByName := make(map[string]string)
+	principals := make(map[string]struct{})
+	for _, u := range details.UserDetailList {
+		usersByName[u.UserName] = u.Arn
+		principals[u.Arn] = struct{}{}
+
+		profile := map[string]interface{}{
+			"arn":         u.Arn,
+			"user_id":     u.UserId,
+			"path":        u.Path,
+			"create_date": u.CreateDate,
+		}
+		if len(u.UserPolicyList) > 0 {
+			profile["inline_policies"] = strings.Join(iamInlinePolicyNames(u.UserPolicyList), ", ")
+		}
+		for _, tag := range u.Tags {
+			profile["tag:"+tag.Key] = tag.Value
+		}
+		loadedData.Users = append(loadedData.Users, UserData{
+			Name:        u.Arn,
+			DisplayName: u.UserName,
+			Type:        "human",
+			Profile:     profile,
+		})
+
+		for _, groupName := range u.GroupList {
+			groupArn, ok := groupsByName[groupName]
+			if !ok {
+				l.Warn("Skipping membership of IAM group missing from the input", zap.String("user", u.UserName), zap.String("group", groupName))
+				continue
+			}
+			loadedData.Grants = append(loadedData.Grants, GrantData{Principal: u.Arn, EntitlementId: groupArn + ":" + iamMemberEntitlement})
+		}
+		attach(u.Arn, u.AttachedManagedPolicies)
+	}
+
+	for _, r := range details.RoleDetailList {
+		principals[r.Arn] = struct{}{}
+	}
+	for _, r := range details.RoleDetailList {
+		description := r.Description
+		if description == "" {
+			description = iamInlinePolicyDescription("IAM role", r.Path, r.RolePolicyList)
+		}
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     iamRoleResourceType,
+			ResourceFunction: "role",
+			Name:             r.Arn,
+			DisplayName:      r.RoleName,
+			Description:      description,
+		})
+		loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+			ResourceName: r.Arn,
+			Entitlement:  iamAssumeEntitlement,
+			DisplayName:  "Assume",
+			Description:  fmt.Sprintf("May assume the %s role", r.RoleName),
+		})
+		// Policies attached to a role apply to whoever assumes it.
+		attach(r.Arn+":"+iamAssumeEntitlement, r.AttachedManagedPolicies)
+
+		trusted, err := iamTrustedPrincipals(r.AssumeRolePolicyDocument)
+		if err != nil {
+			l.Warn("Failed to parse role trust policy", zap.String("role", r.RoleName), zap.Error(err))
+			continue
+		}
+		for _, principal := range trusted {
+			// Only principals defined in the input can be granted; services, accounts and federated principals are skipped.
+			if _, ok := principals[principal]; !ok {
+				continue
+			}
+			if strings.Contains(principal, ":role/") {
+				principal += ":" + iamAssumeEntitlement
+			}
+			loadedData.Grants = append(loadedData.Grants, GrantData{Principal: principal, EntitlementId: r.Arn + ":" + iamAssumeEntitlement})
+		}
+	}
+
+	for _, key := range details.AccessKeyMetadata {
+		owner, ok := usersByName[key.UserName]
+		if !ok {
+			l.Warn("Skipping access key of IAM user missing from the input", zap.String("user", key.UserName), zap.String("access_key_id", key.AccessKeyId))
+			continue
+		}
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     iamAccessKeyResourceType,
+			ResourceFunction: "secret",
+			Name:             key.AccessKeyId,
+			DisplayName:      key.AccessKeyId,
+			Description:      fmt.Sprintf("%s access key of %s, created %s", key.Status, key.UserName, key.CreateDate),
+			Owner:            owner,
+			RotatedAt:        iamKeyRotatedAt(ctx, key.AccessKeyId, key.CreateDate),
+		})
+	}
+
+	return loadedData
+}
+
+// iamKeyRotatedAt returns the RFC 3339 creation time of an access key, which is its last rotation:
+// a key cannot be rotated in place, only replaced by a new key. It is empty when the time cannot be parsed.
+func iamKeyRotatedAt(ctx context.Context, accessKeyId, createDate string) string {
+	if createDate == "" {
+		return ""
+	}
+	created, err := time.Parse(time.RFC3339, createDate)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("Failed to parse CreateDate of access key, skipping its rotation time",
+			zap.String("access_key_id", accessKeyId), zap.String("create_date", createDate), zap.Error(err))
+		return ""
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// iamTrustedPrincipals returns the AWS principal ARNs allowed to assume a role by its trust policy.
+// The CLI returns the document as a JSON object; the raw API returns it URL-encoded, which is accepted as well.
+func iamTrustedPrincipals(document json.RawMessage) ([]string, error) {
+	if len(document) == 0 || string(document) == "null" {
+		return nil, nil
+	}
+
+	var encoded string
+	if json.Unmarshal(document, &encoded) == nil {
+		decoded, err := url.QueryUnescape(encoded)
+		if err != nil {
+			return nil, err
+		}
+		document = json.RawMessage(decoded)
+	}
+
+	var policy struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(document, &policy); err != nil {
+		return nil, err
+	}
+
+	type statement struct {
+		Effect    string          `json:"Effect"`
+		Principal json.RawMessage `json:"Principal"`
+	}
+	var statements []statement
+	if err := json.Unmarshal(policy.Statement, &statements); err != nil {
+		var single statement
+		if err := json.Unmarshal(policy.Statement, &single); err != nil {
+			return nil, err
+		}
+		statements = []statement{single}
+	}
+
+	var arns []string
+	for _, st := range statements {
+		if !strings.EqualFold(st.Effect, "Allow") {
+			continue
+		}
+		var principal struct {
+			AWS json.RawMessage `json:"AWS"`
+		}
+		if err := json.Unmarshal(st.Principal, &principal); err != nil || len(principal.AWS) == 0 {
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(principal.AWS, &list); err != nil {
+			var single string
+			if err := json.Unmarshal(principal.AWS, &single); err != nil {
+				return nil, err
+			}
+			list = []string{single}
+		}
+		arns = append(arns, list...)
+	}
+	return arns, nil
+}
+
+// iamInlinePolicyNames returns the names of inline policies.
+func iamInlinePolicyNames(policies []iamInlinePolicy) []string {
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.PolicyName)
+	}
+	return names
+}
+
+// iamInlinePolicyDescription describes an IAM group or role by its path and inline policies.
+func iamInlinePolicyDescription(kind string, path string, policies []iamInlinePolicy) string {
+	description := fmt.Sprintf("%s at path %s", kind, path)
+	if len(policies) > 0 {
+		description += fmt.Sprintf(" with inline policies %s", strings.Join(iamInlinePolicyNames(policies), ", "))
+	}
+	return description
+}
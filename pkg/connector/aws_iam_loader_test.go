@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertIamAccessKeys(t *testing.T) {
+	var details iamAuthorizationDetails
+	err := json.Unmarshal([]byte(`{
+		"UserDetailList": [{"UserName": "alice", "Arn": "arn:aws:iam::123456789012:user/alice"}],
+		"AccessKeyMetadata": [
+			{"UserName": "alice", "AccessKeyId": "AKIAOLD", "Status": "Inactive", "CreateDate": "2023-01-15T10:00:00+02:00"},
+			{"UserName": "alice", "AccessKeyId": "AKIANEW", "Status": "Active", "CreateDate": "2024-06-01T08:30:00Z"},
+			{"UserName": "alice", "AccessKeyId": "AKIABAD", "Status": "Active", "CreateDate": "yesterday"},
+			{"UserName": "bob", "AccessKeyId": "AKIABOB", "Status": "Active", "CreateDate": "2024-06-01T08:30:00Z"}
+		]
+	}`), &details)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := convertIamDetails(context.Background(), &details)
+	want := map[string]string{"AKIAOLD": "2023-01-15T08:00:00Z", "AKIANEW": "2024-06-01T08:30:00Z", "AKIABAD": ""}
+	got := make(map[string]string)
+	for _, r := range data.Resources {
+		if r.ResourceType != iamAccessKeyResourceType {
+			continue
+		}
+		got[r.Name] = r.RotatedAt
+		if r.Owner != "arn:aws:iam::123456789012:user/alice" {
+			t.Errorf("owner of %s = %q, want alice's ARN", r.Name, r.Owner)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("access keys = %q, want %q", got, want)
+	}
+	for name, rotatedAt := range want {
+		if got[name] != rotatedAt {
+			t.Errorf("rotated_at of %s = %q, want %q", name, got[name], rotatedAt)
+		}
+	}
+}
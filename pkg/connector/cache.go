@@ -7,6 +7,7 @@ import (
 	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
@@ -208,7 +209,22 @@ func buildResourceCache(ctx context.Context, users []UserData, resources []Resou
 			case v2.ResourceType_TRAIT_APP:
 				resourceOptions = append(resourceOptions, rs.WithAppTrait())
 			case v2.ResourceType_TRAIT_SECRET:
-				resourceOptions = append(resourceOptions, rs.WithSecretTrait())
+				var secretOpts []rs.SecretTraitOption
+				if resourceData.RotatedAt != "" {
+					// The current credential was created by the last rotation
+					rotatedAt, err := time.Parse(time.RFC3339, resourceData.RotatedAt)
+					if err != nil {
+						l.Warn("Failed to parse RotatedAt time for secret, skipping field (expected RFC 3339 format)",
+							zap.String("resource_name", resourceData.Name),
+							zap.String("rotated_at_value", resourceData.RotatedAt),
+							zap.Error(err),
+							zap.Int("row_index", i+2),
+						)
+					} else {
+						secretOpts = append(secretOpts, rs.WithSecretCreatedAt(rotatedAt))
+					}
+				}
+				resourceOptions = append(resourceOptions, rs.WithSecretTrait(secretOpts...))
 			}
 		}
 
@@ -227,6 +243,9 @@ func buildResourceCache(ctx context.Context, users []UserData, resources []Resou
 	}
 
 	for _, resourceData := range resources {
+		if resourceData.Owner != "" {
+			setSecretOwner(ctx, cache, resourceData)
+		}
 		if resourceData.ParentResource == "" {
 			continue
 		}
@@ -262,6 +281,41 @@ func buildResourceCache(ctx context.Context, users []UserData, resources []Resou
 	return cache, nil
 }
 
+// The setSecretOwner function records the owner of a secret resource as the identity of its secret trait.
+// It is called by buildResourceCache once all resources exist, since the owner may be defined after the secret.
+// Owners of resources without the secret trait are ignored.
+func setSecretOwner(ctx context.Context, cache map[string]*v2.Resource, resourceData ResourceData) {
+	l := ctxzap.Extract(ctx)
+
+	resource, exists := cache[resourceData.Name]
+	if !exists {
+		return
+	}
+	annos := annotations.Annotations(resource.Annotations)
+	if !annos.Contains(&v2.SecretTrait{}) {
+		l.Warn("Ignoring owner of resource without the secret trait",
+			zap.String("resource_name", resourceData.Name),
+			zap.String("owner", resourceData.Owner))
+		return
+	}
+
+	owner, ownerExists := cache[resourceData.Owner]
+	if !ownerExists {
+		l.Error("Owner not found for secret resource",
+			zap.String("resource_name", resourceData.Name),
+			zap.String("owner", resourceData.Owner))
+		return
+	}
+
+	err := rs.WithSecretTrait(rs.WithSecretIdentityID(owner.Id))(resource)
+	if err != nil {
+		l.Error("Failed to set secret owner",
+			zap.String("resource_name", resourceData.Name),
+			zap.String("owner", resourceData.Owner),
+			zap.Error(err))
+	}
+}
+
 // The buildEntitlementCache function constructs a map of entitlement definitions from the loaded data.
 // It is called by syncer methods to create entitlement definitions based on EntitlementData.
 // The SDK requires these v2.Entitlement objects for grant processing and representing permissions.
@@ -0,0 +1,314 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profileFieldPrefix marks canonical fields that hold a user profile attribute, e.g. "profile.department".
+const profileFieldPrefix = "profile."
+
+// canonicalFields lists the canonical field names of each section.
+// They match the YAML/JSON keys of the corresponding data structs.
+var canonicalFields = map[string][]string{
+	sectionUsers:        {"name", "display_name", "email", "status", "last_login", "type", "profile"},
+	sectionResources:    {"resource_type", "resource_function", "name", "display_name", "description", "parent_resource", "owner", "rotated_at", "credential_hash"},
+	sectionEntitlements: {"resource_name", "entitlement", "display_name", "description", "members"},
+	sectionGrants:       {"principal", "entitlement_id"},
+}
+
+// builtinColumnAliases maps normalized source headers or keys to canonical fields for each section.
+// Every canonical field is also reachable through its own normalized name (e.g. "display name" for display_name).
+var builtinColumnAliases = map[string]map[string]string{
+	sectionUsers: {
+		"user name":     "name",
+		"username":      "name",
+		"login":         "name",
+		"full name":     "display_name",
+		"email address": "email",
+		"e mail":        "email",
+		"account type":  "type",
+		"last logon":    "last_login",
+	},
+	sectionResources: {
+		"type":          "resource_type",
+		"function":      "resource_function",
+		"resource name": "name",
+		"parent":        "parent_resource",
+	},
+	sectionEntitlements: {
+		"resource":                 "resource_name",
+		"slug":                     "entitlement",
+		"entitlement display name": "display_name",
+		"entitlement description":  "description",
+	},
+	sectionGrants: {
+		"principal receiving grant":        "principal",
+		"entitlement granted to principal": "entitlement_id",
+		"entitlement granted to prinicpal": "entitlement_id", // Misspelling used by the original templates
+		"entitlement":                      "entitlement_id",
+	},
+}
+
+// normalizeColumnName lowercases a header or key and treats underscores, hyphens and repeated spaces as a single space.
+func normalizeColumnName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Alias priorities; when several headers or keys of one record resolve to the same field, the highest priority wins.
+const (
+	aliasPriorityBuiltin = iota + 1
+	aliasPriorityCanonical
+	aliasPriorityConfigured
+)
+
+// columnAlias is the canonical field a normalized header or key resolves to, with the priority of that resolution.
+type columnAlias struct {
+	field    string
+	priority int
+}
+
+// The columnAliases type maps normalized source headers or keys to canonical fields for one section.
+type columnAliases map[string]columnAlias
+
+// columnAliases returns the aliases for a section, built from the built-in aliases, the canonical names and the configured column mapping.
+// Configured mappings take precedence over canonical names, which take precedence over built-in aliases.
+func (o *LoadOptions) columnAliases(section string) columnAliases {
+	aliases := make(columnAliases)
+	for source, target := range builtinColumnAliases[section] {
+		aliases[source] = columnAlias{field: target, priority: aliasPriorityBuiltin}
+	}
+	for _, f := range canonicalFields[section] {
+		aliases[normalizeColumnName(f)] = columnAlias{field: f, priority: aliasPriorityCanonical}
+	}
+	if o != nil {
+		for source, target := range o.Columns[section] {
+			aliases[normalizeColumnName(source)] = columnAlias{field: target, priority: aliasPriorityConfigured}
+		}
+	}
+	return aliases
+}
+
+// resolve maps a source header or key to its canonical field.
+// Excel-style "Profile: <key>" headers and database-style "profile.<key>" columns resolve to "profile.<key>", with the key lowercased.
+func (a columnAliases) resolve(name string) (columnAlias, bool) {
+	if alias, ok := a[normalizeColumnName(name)]; ok {
+		return alias, true
+	}
+	trimmed := strings.TrimSpace(name)
+	for _, prefix := range []string{"profile:", profileFieldPrefix} {
+		if len(trimmed) > len(prefix) && strings.EqualFold(trimmed[:len(prefix)], prefix) {
+			profileKey := strings.TrimSpace(trimmed[len(prefix):])
+			if profileKey != "" {
+				return columnAlias{field: profileFieldPrefix + strings.ToLower(profileKey), priority: aliasPriorityCanonical}, true
+			}
+		}
+	}
+	return columnAlias{}, false
+}
+
+// resolveAll resolves a list of headers or keys, returning the canonical field for each position ("" when it doesn't resolve).
+// When several names resolve to the same field only the highest-priority one (leftmost on ties) keeps it.
+func (a columnAliases) resolveAll(names []string) []string {
+	fields := make([]string, len(names))
+	best := make(map[string]int)
+	for i, name := range names {
+		alias, ok := a.resolve(name)
+		if !ok {
+			continue
+		}
+		if j, exists := best[alias.field]; exists {
+			current, _ := a.resolve(names[j])
+			if current.priority >= alias.priority {
+				continue
+			}
+			fields[j] = ""
+		}
+		best[alias.field] = i
+		fields[i] = alias.field
+	}
+	return fields
+}
+
+// validateColumnMapping checks that the configured column mapping only targets known sections and canonical fields.
+func validateColumnMapping(columns map[string]map[string]string) error {
+	for section, mapping := range columns {
+		fields, ok := canonicalFields[section]
+		if !ok {
+			return fmt.Errorf("unknown section %q in columns (expected one of %s)", section, strings.Join(sectionNames, ", "))
+		}
+		for source, target := range mapping {
+			if section == sectionUsers && strings.HasPrefix(target, profileFieldPrefix) && len(target) > len(profileFieldPrefix) {
+				continue
+			}
+			known := false
+			for _, f := range fields {
+				if f == target {
+					known = true
+					break
+				}
+			}
+			if !known {
+				return fmt.Errorf("columns.%s: %q maps to unknown field %q (expected one of %s)", section, source, target, strings.Join(fields, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// The columnIndex type maps canonical fields to 0-based column positions in a sheet.
+type columnIndex map[string]int
+
+// buildColumnIndex resolves the header row of a sheet into a columnIndex.
+func buildColumnIndex(headers []string, aliases columnAliases) columnIndex {
+	index := make(columnIndex)
+	for i, field := range aliases.resolveAll(headers) {
+		if field != "" {
+			index[field] = i
+		}
+	}
+	return index
+}
+
+// get retrieves a cell value for a canonical field, returning an empty string if the column is absent or out of range.
+func (c columnIndex) get(row []string, field string) string {
+	idx, ok := c[field]
+	if !ok || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+// canonicalizeDocument renames the keys of every record in a YAML/JSON document to their canonical fields.
+// Keys that resolve to "profile.<key>" are moved into the record's profile mapping.
+// Keys that don't resolve are left untouched so they are ignored by the decoder as before.
+func canonicalizeDocument(doc *yaml.Node, opts *LoadOptions) {
+	root := resolveAlias(doc)
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = resolveAlias(root.Content[0])
+	}
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section := root.Content[i].Value
+		if !isSectionName(section) {
+			continue
+		}
+		items := resolveAlias(root.Content[i+1])
+		if items.Kind != yaml.SequenceNode {
+			continue
+		}
+		if section == sectionResources {
+			canonicalizeResources(items, opts.columnAliases(sectionResources), opts.columnAliases(sectionEntitlements))
+			continue
+		}
+		aliases := opts.columnAliases(section)
+		for _, item := range items.Content {
+			canonicalizeRecord(resolveAlias(item), aliases)
+		}
+	}
+}
+
+// canonicalizeResources canonicalizes a sequence of resource records, including the entitlements and
+// child resources nested inside them when the document uses the nested shape.
+func canonicalizeResources(items *yaml.Node, resourceAliases columnAliases, entitlementAliases columnAliases) {
+	for _, item := range items.Content {
+		record := resolveAlias(item)
+		canonicalizeRecord(record, resourceAliases)
+		if record.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(record.Content); i += 2 {
+			value := resolveAlias(record.Content[i+1])
+			if value.Kind != yaml.SequenceNode {
+				continue
+			}
+			switch record.Content[i].Value {
+			case "entitlements":
+				for _, ent := range value.Content {
+					canonicalizeRecord(resolveAlias(ent), entitlementAliases)
+				}
+			case "resources":
+				canonicalizeResources(value, resourceAliases, entitlementAliases)
+			}
+		}
+	}
+}
+
+// canonicalizeRecord renames the keys of one record mapping node in place.
+func canonicalizeRecord(record *yaml.Node, aliases columnAliases) {
+	if record.Kind != yaml.MappingNode {
+		return
+	}
+
+	keys := make([]string, 0, len(record.Content)/2)
+	for i := 0; i+1 < len(record.Content); i += 2 {
+		key := record.Content[i]
+		if key.Kind == yaml.ScalarNode && key.Value == "<<" {
+			// Merged mappings are usually anchored outside the section, so canonicalize them as well.
+			merged := resolveAlias(record.Content[i+1])
+			if merged.Kind == yaml.SequenceNode {
+				for _, m := range merged.Content {
+					canonicalizeRecord(resolveAlias(m), aliases)
+				}
+			} else {
+				canonicalizeRecord(merged, aliases)
+			}
+		}
+		if key.Kind != yaml.ScalarNode || key.Value == "<<" {
+			keys = append(keys, "")
+			continue
+		}
+		keys = append(keys, key.Value)
+	}
+	fields := aliases.resolveAll(keys)
+
+	content := make([]*yaml.Node, 0, len(record.Content))
+	profileIdx := -1
+	var profileEntries []*yaml.Node
+	for i := 0; i+1 < len(record.Content); i += 2 {
+		key, value := record.Content[i], record.Content[i+1]
+		field := fields[i/2]
+		switch {
+		case strings.HasPrefix(field, profileFieldPrefix):
+			profileKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.TrimPrefix(field, profileFieldPrefix)}
+			profileEntries = append(profileEntries, profileKey, value)
+			continue
+		case field != "":
+			key.Value = field
+		}
+		if field == "profile" {
+			profileIdx = len(content) + 1
+		}
+		content = append(content, key, value)
+	}
+
+	if len(profileEntries) > 0 {
+		profile := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if profileIdx >= 0 {
+			if existing := resolveAlias(content[profileIdx]); existing.Kind == yaml.MappingNode {
+				// Copy rather than extend in place, since the mapping may be shared through an anchor.
+				profile.Content = append(profile.Content, existing.Content...)
+			}
+			content[profileIdx] = profile
+		} else {
+			content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "profile"}, profile)
+		}
+		profile.Content = append(profile.Content, profileEntries...)
+	}
+	record.Content = content
+}
+
+// resolveAlias follows a YAML alias node to the node it refers to.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
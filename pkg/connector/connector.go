@@ -37,25 +37,36 @@ func (fc *FileConnector) Validate(ctx context.Context) (annotations.Annotations,
 // ResourceSyncers returns a list of syncers for the connector.
 // The function is required by the connectorbuilder.Connector interface.
 // It determines resource types from the input file and creates a syncer instance for each type, enabling the SDK to sync them.
-// The implementation loads minimal data to find resource types, builds the type cache, and creates simple syncers passing only the file path for per-sync loading.
+// The user type gets a userSyncer, which also provisions accounts, and secret types get a secretSyncer, which also rotates credentials.
+// When the input format cannot be written back to, every type gets a readOnlySyncer, so no provisioning is advertised.
+// The implementation loads the data snapshot through the shared data store and creates syncers that reuse that store for per-sync loading.
+// It is called again whenever the resource types of the file change, see NewConnectorServer, which also loads the file
+// before the first call so that a file that cannot be read is reported as an error instead of an empty list of syncers.
 func (fc *FileConnector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
 	l := ctxzap.Extract(ctx)
 	l.Info("ResourceSyncers method called", zap.String("input_file_path", fc.inputFilePath))
-	loadedData, err := LoadFileData(fc.inputFilePath)
+	snapshot, err := fc.store.load(ctx)
 	if err != nil {
 		l.Error("Failed to load input data file to determine resource types", zap.Error(err))
 		return nil
 	}
 
-	resourceTypesCache, err := buildResourceTypeCache(ctx, loadedData.Resources, loadedData.Users)
-	if err != nil {
-		l.Error("Failed to build resource type cache", zap.Error(err))
-		return nil
-	}
-
-	rv := make([]connectorbuilder.ResourceSyncer, 0, len(resourceTypesCache))
-	for _, rt := range resourceTypesCache {
-		rv = append(rv, newFileSyncer(rt, fc.inputFilePath))
+	writable := fc.store.writable()
+	rv := make([]connectorbuilder.ResourceSyncer, 0, len(snapshot.resourceTypes))
+	for _, rt := range snapshot.resourceTypes {
+		if !writable {
+			rv = append(rv, readOnlySyncer{newFileSyncer(rt, fc.store, fc.pageSizes)})
+			continue
+		}
+		if rt.Id == userResourceTypeId {
+			rv = append(rv, newUserSyncer(rt, fc.store, fc.pageSizes))
+			continue
+		}
+		if resourceTypeHasTrait(rt, v2.ResourceType_TRAIT_SECRET) {
+			rv = append(rv, newSecretSyncer(rt, fc.store, fc.pageSizes))
+			continue
+		}
+		rv = append(rv, newFileSyncer(rt, fc.store, fc.pageSizes))
 	}
 
 	l.Info("Created resource syncers", zap.Int("count", len(rv)))
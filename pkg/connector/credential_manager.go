@@ -0,0 +1,116 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/crypto"
+)
+
+// credentialHashScheme prefixes the credential hashes written to the file, so the scheme can change later.
+const credentialHashScheme = "sha256"
+
+// credentialSaltSize is the size in bytes of the random salt of a credential hash.
+const credentialSaltSize = 16
+
+// secretSyncer is the syncer for resource types with the secret trait. It adds credential rotation to fileSyncer.
+// The file models shared credentials without holding them: a rotation generates the new credential, hands it to the
+// caller and records when it happened and a salted hash of the credential. The credential store itself is updated out of band.
+type secretSyncer struct {
+	*fileSyncer
+}
+
+// newSecretSyncer creates a secretSyncer for a secret resource type.
+func newSecretSyncer(rt *v2.ResourceType, store *dataStore, pageSizes PageSizes) *secretSyncer {
+	return &secretSyncer{fileSyncer: newFileSyncer(rt, store, pageSizes)}
+}
+
+// The Rotate method generates a new random credential for a secret resource.
+// It implements the Rotate method, required by the connectorbuilder.CredentialManager interface.
+// The credential follows the requested random password options and is returned as plaintext, which the SDK encrypts
+// before it leaves the connector. The resource's rotated_at and credential_hash fields are updated in the input file;
+// the credential itself is never written.
+func (ss *secretSyncer) Rotate(
+	ctx context.Context,
+	resourceId *v2.ResourceId,
+	credentialOptions *v2.CredentialOptions,
+) ([]*v2.PlaintextData, annotations.Annotations, error) {
+	if resourceId.GetResourceType() != ss.resourceType.Id {
+		return nil, nil, fmt.Errorf("Rotate: resource type %s does not match syncer type %s", resourceId.GetResourceType(), ss.resourceType.Id)
+	}
+	name := resourceId.GetResource()
+
+	if credentialOptions.GetRandomPassword() == nil {
+		return nil, nil, fmt.Errorf("Rotate: only random password credentials are supported")
+	}
+	credential, err := crypto.GeneratePassword(credentialOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Rotate: failed to generate credential: %w", err)
+	}
+	credentialHash, err := hashCredential(credential)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Rotate: %w", err)
+	}
+	rotatedAt := time.Now().UTC().Format(time.RFC3339)
+
+	change := fileChange{
+		operation: "rotate credential of " + name,
+		resource:  ss.resourceType.Id + ":" + name,
+		apply: func(data *LoadedData) error {
+			for i := range data.Resources {
+				r := &data.Resources[i]
+				if r.Name != name || !strings.EqualFold(r.ResourceType, ss.resourceType.Id) {
+					continue
+				}
+				r.RotatedAt = rotatedAt
+				r.CredentialHash = credentialHash
+				return nil
+			}
+			return fmt.Errorf("secret %q not found", name)
+		},
+	}
+	if err := ss.store.update(ctx, change); err != nil {
+		return nil, nil, fmt.Errorf("Rotate: %w", err)
+	}
+
+	return []*v2.PlaintextData{
+		{
+			Name:        "password",
+			Description: fmt.Sprintf("Credential of %s, rotated at %s", name, rotatedAt),
+			Bytes:       []byte(credential),
+		},
+	}, nil, nil
+}
+
+// The RotateCapabilityDetails method reports the credential options supported by Rotate.
+// It implements the RotateCapabilityDetails method, required by the connectorbuilder.CredentialManager interface.
+// Credentials are always rotated to a random password.
+func (ss *secretSyncer) RotateCapabilityDetails(ctx context.Context) (*v2.CredentialDetailsCredentialRotation, annotations.Annotations, error) {
+	return &v2.CredentialDetailsCredentialRotation{
+		SupportedCredentialOptions: []v2.CapabilityDetailCredentialOption{
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_RANDOM_PASSWORD,
+		},
+		PreferredCredentialOption: v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_RANDOM_PASSWORD,
+	}, nil, nil
+}
+
+// hashCredential returns a salted hash of a credential, formatted as "sha256$<salt>$<hash>" with hex encoded values.
+// The hash is the SHA-256 of the salt followed by the credential, so a credential can be checked against the file
+// without the file revealing it.
+func hashCredential(credential string) (string, error) {
+	salt := make([]byte, credentialSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	hasher := sha256.New()
+	hasher.Write(salt)
+	hasher.Write([]byte(credential))
+	return credentialHashScheme + "$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
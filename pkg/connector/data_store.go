@@ -0,0 +1,387 @@
+package connector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// resourceKey identifies a resource by its type and ID.
+type resourceKey struct {
+	resourceType string
+	resource     string
+}
+
+// newResourceKey returns the resourceKey for a v2.ResourceId.
+func newResourceKey(id *v2.ResourceId) resourceKey {
+	return resourceKey{resourceType: id.ResourceType, resource: id.Resource}
+}
+
+// listKey identifies the set of resources of one type that share the same parent.
+// The parent fields are empty for top-level resources.
+type listKey struct {
+	resourceType string
+	parent       resourceKey
+}
+
+// The dataSnapshot struct holds the data parsed from one version of the input file together with the caches built from it.
+// It is built once per file version by the dataStore and shared by all syncers.
+// Resources, entitlements and grants are indexed so that each syncer page is served without scanning the full data set.
+type dataSnapshot struct {
+	loadedData     *LoadedData
+	resourceTypes  map[string]*v2.ResourceType
+	resources      map[string]*v2.Resource
+	entitlements   map[string]*v2.Entitlement
+	resourcesByKey map[listKey][]*v2.Resource
+	entsByResource map[resourceKey][]*v2.Entitlement
+	grantsByRes    map[resourceKey][]*v2.Grant
+
+	// syncAnnotations are added to every resource type listed from the snapshot, e.g. the git commit it was read from.
+	syncAnnotations annotations.Annotations
+}
+
+// annotatedResourceType returns a copy of a resource type carrying the snapshot's sync annotations.
+// They go on the resource types because the SDK stores those in the c1z file, while the annotations of syncer pages are dropped.
+func (s *dataSnapshot) annotatedResourceType(rt *v2.ResourceType) *v2.ResourceType {
+	if len(s.syncAnnotations) == 0 {
+		return rt
+	}
+	annotated := proto.Clone(rt).(*v2.ResourceType)
+	annotated.Annotations = append(annotated.Annotations, s.syncAnnotations...)
+	return annotated
+}
+
+// fileVersion records the modification time and size of a file, which together identify the version that was loaded.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// The dataStore struct loads the input file and caches the resulting dataSnapshot.
+// The file is re-read whenever its modification time or size changes, or when any file it includes changes,
+// so every sync still reflects the file's current state. Changes made by provisioning are written back through update.
+type dataStore struct {
+	filePath    string
+	loadOptions *LoadOptions
+
+	mu       sync.Mutex
+	snapshot *dataSnapshot
+	versions map[string]fileVersion
+
+	// contentHash is the hash of the input file content the connector last read or wrote, see hashFileContent.
+	// Changes written back are planned against it. It is empty for inputs that are directories.
+	contentHash string
+
+	// writeMu serializes changes written back to the input file.
+	writeMu sync.Mutex
+
+	// git is set in git-backed mode, where the input file lives in a git working tree.
+	// gitState is the state of the working tree the snapshot's sync annotations were built from.
+	git      *gitRepo
+	gitState gitState
+}
+
+// newDataStore creates a dataStore for the given input file.
+func newDataStore(filePath string) *dataStore {
+	return &dataStore{filePath: filePath}
+}
+
+// The load method returns the snapshot for the current version of the input file.
+// It is called by every syncer method; the file is only parsed again when it has changed since the last call.
+// In git-backed mode the commit and dirty flag annotating the snapshot are refreshed on every call.
+func (ds *dataStore) load(ctx context.Context) (*dataSnapshot, error) {
+	version, err := statFileVersion(ds.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file %s: %w", ds.filePath, err)
+	}
+
+	// A commit, checkout or reset may leave the file's bytes alone, so the git state is read on every call
+	var state gitState
+	if ds.git != nil {
+		if state, err = ds.git.state(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read git state of input file: %w", err)
+		}
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.snapshot != nil && ds.unchanged(version) {
+		if ds.git == nil || state == ds.gitState {
+			return ds.snapshot, nil
+		}
+		// Syncers may still read the previous snapshot, so the annotations are set on a copy
+		snapshot := *ds.snapshot
+		if snapshot.syncAnnotations, err = state.annotations(ds.git.path); err != nil {
+			return nil, err
+		}
+		ds.snapshot = &snapshot
+		ds.gitState = state
+		return ds.snapshot, nil
+	}
+
+	// The hash is taken before the file is parsed, so an edit made meanwhile is seen as a conflict rather than missed
+	contentHash, err := hashInputFile(ds.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file %s: %w", ds.filePath, err)
+	}
+	loadedData, err := LoadFileData(ctx, ds.filePath, ds.loadOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data file: %w", err)
+	}
+
+	snapshot, err := buildSnapshot(ctx, loadedData)
+	if err != nil {
+		return nil, err
+	}
+	if ds.git != nil {
+		snapshot.syncAnnotations, err = state.annotations(ds.git.path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	versions := map[string]fileVersion{ds.filePath: version}
+	for _, path := range loadedData.includedFiles {
+		v, err := statFileVersion(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat included file %s: %w", path, err)
+		}
+		versions[path] = v
+	}
+
+	ds.snapshot = snapshot
+	ds.gitState = state
+	ds.versions = versions
+	ds.contentHash = contentHash
+	return snapshot, nil
+}
+
+// The writtenResource method returns the user or resource named name as a change written by update left it, reading
+// the input file back. In git-backed mode with a branch the working tree does not hold the change, so the resource is
+// built from the records the change was applied to instead, as captured by the change's apply function.
+func (ds *dataStore) writtenResource(ctx context.Context, name string, applied *LoadedData) (*v2.Resource, error) {
+	var snapshot *dataSnapshot
+	var err error
+	if ds.git != nil && ds.git.branch != "" && applied != nil {
+		snapshot, err = buildSnapshot(ctx, applied)
+	} else {
+		snapshot, err = ds.load(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resource, ok := snapshot.resources[name]
+	if !ok {
+		return nil, fmt.Errorf("%q was written but could not be loaded back", name)
+	}
+	return resource, nil
+}
+
+// unchanged reports whether the input file and every file it included still match the versions that were last loaded.
+// The caller must hold ds.mu.
+func (ds *dataStore) unchanged(inputVersion fileVersion) bool {
+	for path, loaded := range ds.versions {
+		current := inputVersion
+		if path != ds.filePath {
+			v, err := statFileVersion(path)
+			if err != nil {
+				return false
+			}
+			current = v
+		}
+		if !current.modTime.Equal(loaded.modTime) || current.size != loaded.size {
+			return false
+		}
+	}
+	return true
+}
+
+// hashInputFile returns the hash of the content of the input file as hashFileContent does, without reading it all in memory,
+// or "" when the input is a directory.
+func hashInputFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", nil
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// statFileVersion returns the current fileVersion of a file.
+func statFileVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// The buildSnapshot function builds all caches and per-resource indexes for the loaded data.
+// The implementation resolves every grant exactly once and files it under both its principal and its target resource,
+// which mirrors the matching rules previously evaluated on each Grants call.
+func buildSnapshot(ctx context.Context, loadedData *LoadedData) (*dataSnapshot, error) {
+	l := ctxzap.Extract(ctx)
+
+	resourceTypesCache, err := buildResourceTypeCache(ctx, loadedData.Resources, loadedData.Users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build resource type cache: %w", err)
+	}
+	resourceCache, err := buildResourceCache(ctx, loadedData.Users, loadedData.Resources, resourceTypesCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build resource cache: %w", err)
+	}
+	entitlementCache, err := buildEntitlementCache(ctx, loadedData.Entitlements, resourceCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build entitlement cache: %w", err)
+	}
+
+	s := &dataSnapshot{
+		loadedData:     loadedData,
+		resourceTypes:  resourceTypesCache,
+		resources:      resourceCache,
+		entitlements:   entitlementCache,
+		resourcesByKey: make(map[listKey][]*v2.Resource),
+		entsByResource: make(map[resourceKey][]*v2.Entitlement),
+		grantsByRes:    make(map[resourceKey][]*v2.Grant),
+	}
+
+	childTypes := make(map[string]map[string]struct{})
+	for _, rData := range loadedData.Resources {
+		if rData.ParentResource == "" {
+			continue
+		}
+		if childTypes[rData.ParentResource] == nil {
+			childTypes[rData.ParentResource] = make(map[string]struct{})
+		}
+		childTypes[rData.ParentResource][strings.ToLower(rData.ResourceType)] = struct{}{}
+	}
+
+	for name, res := range resourceCache {
+		if types, ok := childTypes[name]; ok {
+			childTypeIds := make([]string, 0, len(types))
+			for childTypeId := range types {
+				childTypeIds = append(childTypeIds, childTypeId)
+			}
+			sort.Strings(childTypeIds)
+
+			annos := annotations.Annotations(res.Annotations)
+			for _, childTypeId := range childTypeIds {
+				annos.Append(&v2.ChildResourceType{ResourceTypeId: childTypeId})
+			}
+			res.Annotations = annos
+		}
+
+		key := listKey{resourceType: res.Id.ResourceType}
+		if res.ParentResourceId != nil {
+			key.parent = newResourceKey(res.ParentResourceId)
+		}
+		s.resourcesByKey[key] = append(s.resourcesByKey[key], res)
+	}
+	for _, resources := range s.resourcesByKey {
+		sort.SliceStable(resources, func(i, j int) bool {
+			return resources[i].Id.Resource < resources[j].Id.Resource
+		})
+	}
+
+	for _, ent := range entitlementCache {
+		key := newResourceKey(ent.Resource.Id)
+		s.entsByResource[key] = append(s.entsByResource[key], ent)
+	}
+	for _, ents := range s.entsByResource {
+		sort.SliceStable(ents, func(i, j int) bool {
+			return ents[i].Slug < ents[j].Slug
+		})
+	}
+
+	for i, grantInfo := range loadedData.Grants {
+		principalIdentifier := grantInfo.Principal
+		entitlementIdentifier := grantInfo.EntitlementId
+
+		var principalResource *v2.Resource
+		if res, ok := resourceCache[principalIdentifier]; ok {
+			principalResource = res
+		} else {
+			if ent, ok := entitlementCache[principalIdentifier]; ok {
+				principalResource = ent.Resource
+			} else {
+				l.Warn("Skipping grant: principal resource not found", zap.String("principal_identifier", principalIdentifier), zap.Int("grant_data_index", i))
+				continue
+			}
+		}
+		principalIdProto := principalResource.Id
+
+		targetEntitlement, ok := entitlementCache[entitlementIdentifier]
+		if !ok {
+			l.Warn("Skipping grant because target entitlement not found in local cache",
+				zap.String("entitlement_id", entitlementIdentifier),
+				zap.Int("grant_data_index", i),
+			)
+			continue
+		}
+
+		grantOptions := []grant.GrantOption{}
+		principalResourceType, rtOk := resourceTypesCache[principalIdProto.ResourceType]
+		if rtOk {
+			isUserOrApp := resourceTypeHasTrait(principalResourceType, v2.ResourceType_TRAIT_USER) || resourceTypeHasTrait(principalResourceType, v2.ResourceType_TRAIT_APP)
+			_, principalWasEntitlementKey := entitlementCache[principalIdentifier]
+
+			if !isUserOrApp && principalWasEntitlementKey {
+				membershipEntitlement := entitlementCache[principalIdentifier]
+				expandableProto := &v2.GrantExpandable{EntitlementIds: []string{membershipEntitlement.Id}}
+				grantOptions = append(grantOptions, grant.WithAnnotation(expandableProto))
+			}
+		} else {
+			l.Warn("Could not find resource type for principal in local cache, skipping expansion check", zap.String("principal_type", principalIdProto.ResourceType), zap.Int("grant_data_index", i))
+		}
+
+		newGrant := grant.NewGrant(targetEntitlement.Resource, targetEntitlement.Slug, principalIdProto, grantOptions...)
+
+		principalKey := newResourceKey(principalIdProto)
+		targetKey := newResourceKey(targetEntitlement.Resource.Id)
+		s.grantsByRes[principalKey] = append(s.grantsByRes[principalKey], newGrant)
+		if targetKey != principalKey {
+			s.grantsByRes[targetKey] = append(s.grantsByRes[targetKey], newGrant)
+		}
+	}
+	for _, grants := range s.grantsByRes {
+		sort.SliceStable(grants, func(i, j int) bool {
+			if grants[i].Principal.Id.String() != grants[j].Principal.Id.String() {
+				return grants[i].Principal.Id.String() < grants[j].Principal.Id.String()
+			}
+			return grants[i].Entitlement.Id < grants[j].Entitlement.Id
+		})
+	}
+
+	l.Info("Built data snapshot",
+		zap.Int("resource_count", len(resourceCache)),
+		zap.Int("entitlement_count", len(entitlementCache)),
+		zap.Int("grant_count", len(loadedData.Grants)),
+	)
+	return s, nil
+}
@@ -0,0 +1,180 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// The dynamicServer struct serves a connector whose resource types come from the input file.
+// The SDK's connector server fixes its resource types when it is built, so the server is built again whenever the
+// types of the file change: they are discovered again when a sync lists resource types and when metadata is requested.
+// Methods that depend on the resource types go to the current server; the others, such as actions, tickets and the event feed,
+// go to the server built at startup, which serves them from the same connector.
+type dynamicServer struct {
+	types.ConnectorServer
+
+	builder connectorbuilder.ConnectorBuilder
+	store   *dataStore
+
+	mu        sync.RWMutex
+	current   types.ConnectorServer
+	signature []string // See resourceTypeSignature
+}
+
+// NewConnectorServer creates the connector server for a FileConnector, or for a WatchingConnector wrapping one.
+// The input file is loaded first, so a file that cannot be read fails the startup instead of leaving the connector
+// without resource types. Resource types added to or removed from the file later are picked up by the next sync.
+func NewConnectorServer(ctx context.Context, builder connectorbuilder.ConnectorBuilder) (types.ConnectorServer, error) {
+	var fc *FileConnector
+	switch c := builder.(type) {
+	case *FileConnector:
+		fc = c
+	case *WatchingConnector:
+		fc = c.FileConnector
+	default:
+		return nil, fmt.Errorf("unsupported connector type %T", builder)
+	}
+
+	snapshot, err := fc.store.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load input file %s: %w", fc.inputFilePath, err)
+	}
+	server, err := connectorbuilder.NewConnector(ctx, builder)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamicServer{
+		ConnectorServer: server,
+		builder:         builder,
+		store:           fc.store,
+		current:         server,
+		signature:       resourceTypeSignature(snapshot),
+	}, nil
+}
+
+// resourceTypeSignature returns the IDs of the resource types of a snapshot with their traits, sorted,
+// which decide the syncers the server is built with.
+func resourceTypeSignature(snapshot *dataSnapshot) []string {
+	signature := make([]string, 0, len(snapshot.resourceTypes))
+	for id, rt := range snapshot.resourceTypes {
+		traits := make([]string, 0, len(rt.Traits))
+		for _, trait := range rt.Traits {
+			traits = append(traits, trait.String())
+		}
+		sort.Strings(traits)
+		signature = append(signature, id+"="+strings.Join(traits, ","))
+	}
+	sort.Strings(signature)
+	return signature
+}
+
+// refresh loads the input file and rebuilds the server when its resource types changed, returning the server to use.
+// A file that cannot be loaded fails the request rather than syncing no resource types.
+func (s *dynamicServer) refresh(ctx context.Context) (types.ConnectorServer, error) {
+	l := ctxzap.Extract(ctx)
+
+	snapshot, err := s.store.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signature := resourceTypeSignature(snapshot)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Equal(signature, s.signature) {
+		return s.current, nil
+	}
+	server, err := connectorbuilder.NewConnector(ctx, s.builder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild connector server for the resource types of the input file: %w", err)
+	}
+	l.Info("Resource types of the input file changed; rebuilt connector server",
+		zap.Strings("previous", s.signature),
+		zap.Strings("current", signature))
+	s.current = server
+	s.signature = signature
+	return server, nil
+}
+
+// server returns the current server.
+func (s *dynamicServer) server() types.ConnectorServer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// ListResourceTypes discovers the resource types of the input file again, as it is called at the start of every sync.
+func (s *dynamicServer) ListResourceTypes(
+	ctx context.Context,
+	request *v2.ResourceTypesServiceListResourceTypesRequest,
+) (*v2.ResourceTypesServiceListResourceTypesResponse, error) {
+	server, err := s.refresh(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListResourceTypes: %w", err)
+	}
+	return server.ListResourceTypes(ctx, request)
+}
+
+// GetMetadata discovers the resource types of the input file again, since the capabilities are reported per resource type.
+func (s *dynamicServer) GetMetadata(ctx context.Context, request *v2.ConnectorServiceGetMetadataRequest) (*v2.ConnectorServiceGetMetadataResponse, error) {
+	server, err := s.refresh(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetMetadata: %w", err)
+	}
+	return server.GetMetadata(ctx, request)
+}
+
+// The methods below depend on the resource types, so they are served by the current server.
+
+func (s *dynamicServer) ListResources(ctx context.Context, request *v2.ResourcesServiceListResourcesRequest) (*v2.ResourcesServiceListResourcesResponse, error) {
+	return s.server().ListResources(ctx, request)
+}
+
+func (s *dynamicServer) ListEntitlements(
+	ctx context.Context,
+	request *v2.EntitlementsServiceListEntitlementsRequest,
+) (*v2.EntitlementsServiceListEntitlementsResponse, error) {
+	return s.server().ListEntitlements(ctx, request)
+}
+
+func (s *dynamicServer) ListGrants(ctx context.Context, request *v2.GrantsServiceListGrantsRequest) (*v2.GrantsServiceListGrantsResponse, error) {
+	return s.server().ListGrants(ctx, request)
+}
+
+func (s *dynamicServer) Grant(ctx context.Context, request *v2.GrantManagerServiceGrantRequest) (*v2.GrantManagerServiceGrantResponse, error) {
+	return s.server().Grant(ctx, request)
+}
+
+func (s *dynamicServer) Revoke(ctx context.Context, request *v2.GrantManagerServiceRevokeRequest) (*v2.GrantManagerServiceRevokeResponse, error) {
+	return s.server().Revoke(ctx, request)
+}
+
+func (s *dynamicServer) CreateResource(ctx context.Context, request *v2.CreateResourceRequest) (*v2.CreateResourceResponse, error) {
+	return s.server().CreateResource(ctx, request)
+}
+
+func (s *dynamicServer) DeleteResource(ctx context.Context, request *v2.DeleteResourceRequest) (*v2.DeleteResourceResponse, error) {
+	return s.server().DeleteResource(ctx, request)
+}
+
+func (s *dynamicServer) DeleteResourceV2(ctx context.Context, request *v2.DeleteResourceV2Request) (*v2.DeleteResourceV2Response, error) {
+	return s.server().DeleteResourceV2(ctx, request)
+}
+
+func (s *dynamicServer) CreateAccount(ctx context.Context, request *v2.CreateAccountRequest) (*v2.CreateAccountResponse, error) {
+	return s.server().CreateAccount(ctx, request)
+}
+
+func (s *dynamicServer) RotateCredential(ctx context.Context, request *v2.RotateCredentialRequest) (*v2.RotateCredentialResponse, error) {
+	return s.server().RotateCredential(ctx, request)
+}
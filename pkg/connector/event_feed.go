@@ -0,0 +1,58 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultEventsPageSize is the number of events returned per ListEvents page when the request sets no page size.
+const defaultEventsPageSize = 100
+
+// The WatchingConnector struct is a FileConnector that watches its input file and reports the grants added to and removed
+// from the file through the event feed, so ConductorOne picks up edits without waiting for the next scheduled sync.
+// It is a separate type because the SDK advertises the event feed capability for any connector implementing ListEvents.
+// Instances are created by NewWatchingConnector.
+type WatchingConnector struct {
+	*FileConnector
+	watcher *fileWatcher
+}
+
+// NewWatchingConnector wraps a FileConnector in watch mode.
+// The file is only watched from the first ListEvents call on, so only the connector process serving requests watches it.
+// Watching stops once ctx is done, so ctx must live as long as the connector.
+func NewWatchingConnector(ctx context.Context, fc *FileConnector, opts WatchOptions) *WatchingConnector {
+	return &WatchingConnector{FileConnector: fc, watcher: newFileWatcher(ctx, fc.store, opts)}
+}
+
+// ListEvents returns the grant and revoke events recorded for changes to the input file.
+// The function is required by the connectorbuilder.EventProvider interface.
+// The first successful call starts watching the file, with its current content as the baseline. Changes to records other than grants
+// produce no events and are picked up by the next full sync.
+func (wc *WatchingConnector) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	if err := wc.watcher.start(ctx); err != nil {
+		return nil, nil, nil, fmt.Errorf("ListEvents: %w", err)
+	}
+
+	size := defaultEventsPageSize
+	cursor := ""
+	if pToken != nil {
+		if pToken.Size > 0 {
+			size = pToken.Size
+		}
+		cursor = pToken.Cursor
+	}
+	events, next, hasMore, err := wc.watcher.list(earliestEvent, cursor, size)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("ListEvents: %w", err)
+	}
+	return events, &pagination.StreamState{Cursor: next, HasMore: hasMore}, nil, nil
+}
@@ -0,0 +1,127 @@
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// errNotEditableInPlace is returned when a change cannot be written back without rewriting the parts of the file around it,
+// e.g. a grant that is one of several listed on a row under both several principals and several entitlements.
+var errNotEditableInPlace = errors.New("cannot be edited in place")
+
+// recordFieldValues returns the canonical field values of a record in its journaled form, with profile attributes
+// as "profile.<key>" fields. Entitlement members are left out, as they are edited through the grants they expand to.
+// Empty values are left out, so a field absent from the file and an empty one compare equal.
+func recordFieldValues(raw json.RawMessage) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if isJSONNull(raw) {
+		return values, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for field, value := range fields {
+		switch field {
+		case "members":
+		case "profile":
+			profile, _ := value.(map[string]interface{})
+			for key, v := range profile {
+				if v != nil && v != "" {
+					values[profileFieldPrefix+key] = v
+				}
+			}
+		default:
+			if value != nil && value != "" {
+				values[field] = value
+			}
+		}
+	}
+	return values, nil
+}
+
+// changedFields returns the fields whose values differ between two versions of a record, in a stable order:
+// canonical fields in their declared order, then profile attributes sorted by key.
+func changedFields(section string, before, after map[string]interface{}) []string {
+	var changed []string
+	seen := make(map[string]bool)
+	for _, field := range canonicalFields[section] {
+		seen[field] = true
+		if !reflect.DeepEqual(before[field], after[field]) {
+			changed = append(changed, field)
+		}
+	}
+	var profileFields []string
+	for _, values := range []map[string]interface{}{before, after} {
+		for field := range values {
+			if !seen[field] && strings.HasPrefix(field, profileFieldPrefix) {
+				seen[field] = true
+				if !reflect.DeepEqual(before[field], after[field]) {
+					profileFields = append(profileFields, field)
+				}
+			}
+		}
+	}
+	sort.Strings(profileFields)
+	return append(changed, profileFields...)
+}
+
+// cellText returns the text a field value is written as in a spreadsheet cell or a delimited list.
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// removeMultiValues removes values from a delimited cell, once each, and returns the remaining cell
+// and whether any value remains. The separator used between values is kept.
+func removeMultiValues(cell string, values []string, delimiter string) (string, bool) {
+	remaining := splitMultiValue(cell, delimiter)
+	for _, value := range values {
+		for i, v := range remaining {
+			if v == value {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	separator := delimiter
+	if strings.Contains(cell, delimiter+" ") {
+		separator = delimiter + " "
+	}
+	return strings.Join(remaining, separator), len(remaining) > 0
+}
+
+// multiValueField returns the field of a grant row a record is one value of, when the row lists several principals
+// or several entitlements: "principal" or "entitlement_id", with the record's value in that field.
+// It returns "" for a row holding a single grant, and fails for a row listing both several principals and several entitlements.
+func multiValueField(row GrantData, record GrantData, delimiter string) (string, string, error) {
+	principals := splitMultiValue(row.Principal, delimiter)
+	entitlementIds := splitMultiValue(row.EntitlementId, delimiter)
+	switch {
+	case len(principals) <= 1 && len(entitlementIds) <= 1:
+		return "", "", nil
+	case len(entitlementIds) <= 1:
+		return "principal", record.Principal, nil
+	case len(principals) <= 1:
+		return "entitlement_id", record.EntitlementId, nil
+	default:
+		return "", "", fmt.Errorf("grant %s -> %s is listed on a row granting several entitlements to several principals and %w",
+			record.Principal, record.EntitlementId, errNotEditableInPlace)
+	}
+}
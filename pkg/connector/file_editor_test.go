@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// assertEditRoundTrip loads the input file, changes its records with mutate, writes the record changes back with edit
+// and checks that loading the file again gives the changed records, under the same keys.
+func assertEditRoundTrip(t *testing.T, filePath string, opts *LoadOptions, edit func(changes []journalRecordChange) error, mutate func(t *testing.T, data *LoadedData)) {
+	t.Helper()
+	ctx := context.Background()
+
+	data, err := LoadFileData(ctx, filePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordsBefore, err := journalRecords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutate(t, data)
+	recordsPlanned, err := journalRecords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := diffJournalRecords(recordsBefore, recordsPlanned)
+	if len(changes) == 0 {
+		t.Fatal("the mutation changes no records")
+	}
+
+	if err := edit(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadFileData(ctx, filePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordsAfter, err := journalRecords(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range diffJournalRecords(recordsPlanned, recordsAfter) {
+		t.Errorf("%s record %q: want %s, got %s", c.Section, c.Key, c.Before, c.After)
+	}
+}
+
+// removeRecord removes the first record with the given journal record key.
+func removeRecord[T any](t *testing.T, records []T, key string) []T {
+	t.Helper()
+	for i, record := range records {
+		if journalRecordKey(record) == key {
+			return append(records[:i:i], records[i+1:]...)
+		}
+	}
+	t.Fatalf("no record %q", key)
+	return nil
+}
+
+// writeTestFile writes content to a new file with the given name in a temporary directory and returns its path.
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
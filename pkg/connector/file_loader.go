@@ -1,123 +1,257 @@
 package connector
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"github.com/xuri/excelize/v2"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
-// getColumnIndex finds the 0-based index of a column name in a header row.
-// Returns -1 if not found. Case-insensitive comparison.
-func getColumnIndex(headers []string, columnName string) int {
-	target := strings.ToLower(strings.TrimSpace(columnName))
-	for i, h := range headers {
-		if strings.ToLower(strings.TrimSpace(h)) == target {
-			return i
+// isBlankRow reports whether every cell in a row is empty or whitespace.
+// Spreadsheets often carry formatted but empty rows below the data, which are skipped without a warning.
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
 		}
 	}
-	return -1
+	return true
 }
 
-// safeGet retrieves a cell value safely, returning an empty string if index is out of bounds.
-func safeGet(row []string, headerMap map[string]int, headerName string) string {
-	idx, ok := headerMap[headerName]
-	if !ok || idx >= len(row) {
-		return ""
-	}
-	return strings.TrimSpace(row[idx])
-}
-
-// The LoadFileData function reads data from the specified input file (Excel, YAML, or JSON).
+// The LoadFileData function reads data from the specified input file (Excel .xlsx/.xls, OpenDocument .ods, YAML, JSON, NDJSON, LDIF, a SCIM export, host snapshots, Kubernetes RBAC manifests, or AWS IAM authorization details, or a SQLite database).
 // It is called by syncer methods to load the complete dataset required for processing.
 // The syncer methods require this to get the raw data before building local caches.
 // Which ensures each sync operation uses data reflecting the file's state at that moment.
-// The implementation detects the file type based on its extension and dispatches to the appropriate parser function.
-func LoadFileData(filePath string) (*LoadedData, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".xlsx":
-		return loadExcelData(filePath, nil)
-	case ".yaml", ".yml":
-		return loadYamlData(filePath)
-	case ".json":
-		return loadJsonData(filePath)
+// The implementation detects the file type based on its extension, unless the options select a format explicitly,
+// and dispatches to the appropriate parser function.
+// The options may be nil, in which case the default layout is expected.
+func LoadFileData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	format, err := resolveInputFormat(filePath, opts.format())
+	if err != nil {
+		return nil, err
+	}
+
+	var loadedData *LoadedData
+	switch format {
+	case InputFormatExcel:
+		loadedData, err = loadExcelData(ctx, filePath, opts)
+	case InputFormatODS:
+		loadedData, err = loadOdsData(ctx, filePath, opts)
+	case InputFormatXLS:
+		loadedData, err = loadXlsData(ctx, filePath, opts)
+	case InputFormatYAML:
+		loadedData, err = loadYamlData(ctx, filePath, opts)
+	case InputFormatJSON:
+		loadedData, err = loadJsonData(filePath, opts)
+	case InputFormatNDJSON:
+		loadedData, err = loadNdjsonData(ctx, filePath, opts)
+	case InputFormatLDIF:
+		loadedData, err = loadLdifData(ctx, filePath)
+	case InputFormatSCIM:
+		loadedData, err = loadScimData(ctx, filePath)
+	case InputFormatHost:
+		loadedData, err = loadHostSnapshotData(ctx, filePath)
+	case InputFormatK8s:
+		loadedData, err = loadKubernetesData(ctx, filePath)
+	case InputFormatAWSIAM:
+		loadedData, err = loadAwsIamData(ctx, filePath)
+	case InputFormatSQLite:
+		loadedData, err = loadSqliteData(ctx, filePath, opts)
 	default:
-		return nil, fmt.Errorf("unsupported file type: '%s' for file: %s", ext, filePath)
+		return nil, fmt.Errorf("unsupported input format: '%s' for file: %s", format, filePath)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return loadedData, nil
+}
+
+// expandMultiValues expands delimited grant cells and entitlement member lists into individual GrantData entries.
+// A grant listing several principals and/or several entitlements becomes one grant per combination.
+// Entitlement members are appended as grants of that entitlement and cleared, so the result only uses the flat grants list.
+// It is applied by the loaders of the formats people write by hand (spreadsheets, YAML, JSON and NDJSON); names read from
+// directory exports and databases may contain the delimiter and are kept as they are. An empty delimiter splits nothing.
+func expandMultiValues(loadedData *LoadedData, delimiter string) {
+	grants := make([]GrantData, 0, len(loadedData.Grants))
+	for _, g := range loadedData.Grants {
+		principals := splitMultiValue(g.Principal, delimiter)
+		entitlementIds := splitMultiValue(g.EntitlementId, delimiter)
+		if len(principals) <= 1 && len(entitlementIds) <= 1 {
+			grants = append(grants, g)
+			continue
+		}
+		for _, principal := range principals {
+			for _, entitlementId := range entitlementIds {
+				grants = append(grants, GrantData{Principal: principal, EntitlementId: entitlementId})
+			}
+		}
+	}
+
+	for i, e := range loadedData.Entitlements {
+		if len(e.Members) == 0 {
+			continue
+		}
+		entitlementId := fmt.Sprintf("%s:%s", e.ResourceName, e.Entitlement)
+		for _, member := range e.Members {
+			for _, principal := range splitMultiValue(member, delimiter) {
+				grants = append(grants, GrantData{Principal: principal, EntitlementId: entitlementId})
+			}
+		}
+		loadedData.Entitlements[i].Members = nil
+	}
+
+	loadedData.Grants = grants
+}
+
+// splitMultiValue splits a delimited cell into its trimmed, non-empty values.
+// An empty delimiter leaves the cell as a single value.
+func splitMultiValue(value string, delimiter string) []string {
+	parts := []string{value}
+	if delimiter != "" {
+		parts = strings.Split(value, delimiter)
+	}
+	values := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			values = append(values, p)
+		}
+	}
+	return values
 }
 
 // JSON Loading Logic
 // loadJsonData handles the specific logic for reading and parsing .json files.
-func loadJsonData(filePath string) (*LoadedData, error) {
+// The JSON document is converted to a YAML node tree so that it shares the key normalization used for YAML files.
+func loadJsonData(filePath string, opts *LoadOptions) (*LoadedData, error) {
 	// Read the entire file content
 	jsonData, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read JSON file %s: %w", filePath, err)
 	}
 
-	// Initialize the target struct
-	var loadedData LoadedData
-
-	// Unmarshal the JSON data into the struct
-	err = json.Unmarshal(jsonData, &loadedData)
+	// Parse the JSON data into a generic value
+	var raw interface{}
+	err = json.Unmarshal(jsonData, &raw)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON data from %s: %w", filePath, err)
 	}
 
-	if loadedData.Users == nil && loadedData.Resources == nil {
+	var doc yaml.Node
+	err = doc.Encode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert JSON data from %s: %w", filePath, err)
 	}
 
-	return &loadedData, nil
-}
-
-// YAML Loading Logic
-// loadYamlData handles the specific logic for reading and parsing .yaml or .yml files.
-func loadYamlData(filePath string) (*LoadedData, error) {
-	// Read the entire file content
-	yamlData, err := os.ReadFile(filePath)
+	loadedData, err := decodeDocument(&doc, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read YAML file %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to decode JSON data from %s: %w", filePath, err)
 	}
+	expandMultiValues(loadedData, opts.delimiter())
+	return loadedData, nil
+}
 
+// decodeDocument normalizes the keys of a YAML/JSON document node and decodes it into LoadedData.
+// Documents may use the flat shape or the nested shape, where resources declare their entitlements and child resources;
+// both are normalized into the flat LoadedData lists. An empty document yields empty LoadedData.
+func decodeDocument(doc *yaml.Node, opts *LoadOptions) (*LoadedData, error) {
 	// Initialize the target struct
 	var loadedData LoadedData
+	if doc.Kind == 0 {
+		return &loadedData, nil
+	}
+
+	canonicalizeDocument(doc, opts)
 
-	// Unmarshal the YAML data into the struct
-	err = yaml.Unmarshal(yamlData, &loadedData)
+	var docData documentData
+	err := doc.Decode(&docData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal YAML data from %s: %w", filePath, err)
+		return nil, err
 	}
 
-	// Basic validation (optional, but good practice)
-	// Example: Check if essential slices are non-nil (they will be empty if the key exists but has no items)
-	if loadedData.Users == nil && loadedData.Resources == nil {
-		// Or return an error, depending on requirements
-		// return nil, fmt.Errorf("YAML file %s seems empty or missing required top-level keys (users, resources)", filePath)
+	loadedData.Users = docData.Users
+	loadedData.Entitlements = docData.Entitlements
+	loadedData.Grants = docData.Grants
+	if docData.Resources != nil {
+		loadedData.Resources = make([]ResourceData, 0, len(docData.Resources))
 	}
-
+	flattenResources(&loadedData, docData.Resources, "")
 	return &loadedData, nil
 }
 
+// flattenResources appends nested resources, their entitlements and their members to the flat LoadedData lists.
+// Child resources get the containing resource as their parent, and nested entitlements are defined on the containing resource.
+func flattenResources(loadedData *LoadedData, resources []nestedResourceData, parent string) {
+	for _, r := range resources {
+		resourceData := r.ResourceData
+		if parent != "" {
+			resourceData.ParentResource = parent
+		}
+		loadedData.Resources = append(loadedData.Resources, resourceData)
+
+		for _, e := range r.Entitlements {
+			entitlementData := e.EntitlementData
+			entitlementData.ResourceName = resourceData.Name
+			entitlementData.Members = append(entitlementData.Members, e.Grants...)
+			loadedData.Entitlements = append(loadedData.Entitlements, entitlementData)
+		}
+
+		flattenResources(loadedData, r.Resources, resourceData.Name)
+	}
+}
+
+// excelProgressInterval is the number of rows between progress log entries while streaming a sheet.
+const excelProgressInterval = 10000
+
 // loadExcelData handles the specific logic for reading and parsing .xlsx files.
-func loadExcelData(filePath string, l *zap.Logger) (*LoadedData, error) {
+// Sheets are streamed row by row through excelize's row iterator, so only the current row is held in memory
+// in addition to the typed structs produced from earlier rows.
+func loadExcelData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
 	defer func() {
 		if err := f.Close(); err != nil {
-			if l != nil {
-				l.Error("failed to close file", zap.Error(err), zap.String("file", filePath))
-			}
+			l.Error("failed to close file", zap.Error(err), zap.String("file", filePath))
 		}
 	}()
 
+	return loadWorkbookData(ctx, filePath, &excelWorkbook{f: f}, opts)
+}
+
+// loadOdsData handles the specific logic for reading OpenDocument spreadsheets (.ods), using the same sheet layout as Excel.
+func loadOdsData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	wb, err := openOdsWorkbook(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return loadWorkbookData(ctx, filePath, wb, opts)
+}
+
+// loadXlsData handles the specific logic for reading legacy Excel 97-2003 workbooks (.xls), using the same sheet layout as Excel.
+func loadXlsData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	wb, err := openXlsWorkbook(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return loadWorkbookData(ctx, filePath, wb, opts)
+}
+
+// loadWorkbookData reads every section from the sheets of a spreadsheet, whatever its file format.
+// Sheets are selected through the configured sheet sources and their rows are processed by the shared sheetConfigs,
+// so header handling and Profile columns behave the same for .xlsx, .ods and .xls files.
+func loadWorkbookData(ctx context.Context, filePath string, wb workbook, opts *LoadOptions) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
 	loadedData := &LoadedData{
 		Users:        make([]UserData, 0),
 		Resources:    make([]ResourceData, 0),
@@ -125,181 +259,315 @@ func loadExcelData(filePath string, l *zap.Logger) (*LoadedData, error) {
 		Grants:       make([]GrantData, 0),
 	}
 
-	type sheetConfig struct {
-		headers []string // List of required header names
-		process func(sheetName string, allRows [][]string, headerMap map[string]int) error
+	sheetConfigs := newSheetConfigs(ctx, loadedData)
+
+	for _, section := range sectionNames {
+		config := sheetConfigs[section]
+		regions, err := resolveSheetRegions(wb, opts.sheetSources(section))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve sheets for section %s in %s: %w", section, filePath, err)
+		}
+		if len(regions) == 0 {
+			l.Warn("No sheet matched section, skipping.", zap.String("section", section))
+		}
+		for _, region := range regions {
+			err := streamSheet(ctx, wb, region, opts.columnAliases(section), config.fields, config.process)
+			if err != nil {
+				l.Warn("Failed to read sheet, skipping.", zap.String("sheet", region.sheet), zap.String("section", section), zap.Error(err))
+			}
+		}
 	}
+	expandMultiValues(loadedData, opts.delimiter())
 
-	sheetConfigs := map[string]sheetConfig{
-		"users": {
-			headers: []string{"Name", "Display Name"}, // Required base headers
-			process: func(sheetName string, allRows [][]string, headerMap map[string]int) error {
-				for i, row := range allRows {
-					if i == 0 {
-						continue
-					}
-					userData := UserData{
-						Name:        safeGet(row, headerMap, "Name"),
-						DisplayName: safeGet(row, headerMap, "Display Name"),
-						Email:       safeGet(row, headerMap, "Email"),
-						Status:      safeGet(row, headerMap, "Status"),
-						Type:        safeGet(row, headerMap, "Type"),
-						Profile:     make(map[string]interface{}),
-					}
-					if userData.Name == "" {
-						if l != nil {
-							l.Warn("Skipping user row due to missing required field(s)", zap.Int("row_index", i+1), zap.Any("row_data", userData))
-						}
-						continue
-					}
+	l.Info("Finished loading data from file",
+		zap.Int("user_count", len(loadedData.Users)),
+		zap.Int("resource_count", len(loadedData.Resources)),
+		zap.Int("entitlement_count", len(loadedData.Entitlements)),
+		zap.Int("grant_count", len(loadedData.Grants)),
+	)
+	return loadedData, nil
+}
 
-					for header := range headerMap {
-						if strings.HasPrefix(header, "Profile: ") {
-							profileKey := strings.TrimSpace(strings.TrimPrefix(header, "Profile: "))
-							if profileKey != "" {
-								profileValue := safeGet(row, headerMap, header)
-								if profileValue != "" {
-									userData.Profile[strings.ToLower(profileKey)] = profileValue
-								}
-							}
-						}
-					}
+// The sheetConfig struct describes how the rows of one section are turned into LoadedData records.
+// It is shared by every tabular input (Excel workbooks and database tables), so headers and Profile columns behave the same.
+type sheetConfig struct {
+	fields  []string // List of required canonical fields
+	process func(rowIndex int, row []string, cols columnIndex)
+}
 
-					loadedData.Users = append(loadedData.Users, userData)
+// newSheetConfigs returns the row processors for each section, appending the records they accept to loadedData.
+func newSheetConfigs(ctx context.Context, loadedData *LoadedData) map[string]sheetConfig {
+	l := ctxzap.Extract(ctx)
+
+	return map[string]sheetConfig{
+		sectionUsers: {
+			fields: []string{"name", "display_name"}, // Required base fields
+			process: func(rowIndex int, row []string, cols columnIndex) {
+				userData := UserData{
+					Name:        cols.get(row, "name"),
+					DisplayName: cols.get(row, "display_name"),
+					Email:       cols.get(row, "email"),
+					Status:      cols.get(row, "status"),
+					LastLogin:   cols.get(row, "last_login"),
+					Type:        cols.get(row, "type"),
+					Profile:     make(map[string]interface{}),
 				}
-				return nil
-			},
-		},
-		"resources": {
-			headers: []string{"Resource Type", "Resource Function", "Name", "Display Name"}, // Required base headers
-			process: func(sheetName string, allRows [][]string, headerMap map[string]int) error {
-				for i, row := range allRows {
-					if i == 0 {
-						continue
-					}
-					resourceData := ResourceData{
-						ResourceType:     safeGet(row, headerMap, "Resource Type"),
-						ResourceFunction: safeGet(row, headerMap, "Resource Function"),
-						Name:             safeGet(row, headerMap, "Name"),
-						DisplayName:      safeGet(row, headerMap, "Display Name"),
-						Description:      safeGet(row, headerMap, "Description"),
-						ParentResource:   safeGet(row, headerMap, "Parent Resource"),
+				if userData.Name == "" {
+					l.Warn("Skipping user row due to missing required field(s)", zap.Int("row_index", rowIndex), zap.Any("row_data", userData))
+					return
+				}
+
+				if profile := cols.get(row, "profile"); profile != "" {
+					// A profile column holds the whole profile as a JSON object, as stored by database exports.
+					if err := json.Unmarshal([]byte(profile), &userData.Profile); err != nil {
+						l.Warn("Ignoring profile that is not a JSON object", zap.Int("row_index", rowIndex), zap.String("user", userData.Name), zap.Error(err))
 					}
-					if resourceData.Name == "" || resourceData.ResourceType == "" || resourceData.ResourceFunction == "" {
-						if l != nil {
-							l.Warn("Skipping resource row due to missing required field(s)", zap.Int("row_index", i+1), zap.Any("row_data", resourceData))
+				}
+				for field := range cols {
+					if profileKey, ok := strings.CutPrefix(field, profileFieldPrefix); ok {
+						profileValue := cols.get(row, field)
+						if profileValue != "" {
+							userData.Profile[profileKey] = profileValue
 						}
-						continue
 					}
-					loadedData.Resources = append(loadedData.Resources, resourceData)
 				}
-				return nil
+
+				loadedData.Users = append(loadedData.Users, userData)
 			},
 		},
-		"entitlements": {
-			headers: []string{"Resource Name", "Entitlement", "Entitlement Display Name"}, // Required base headers
-			process: func(sheetName string, allRows [][]string, headerMap map[string]int) error {
-				for i, row := range allRows {
-					if i == 0 {
-						continue
-					}
-					entitlementData := EntitlementData{
-						ResourceName: safeGet(row, headerMap, "Resource Name"),
-						Entitlement:  safeGet(row, headerMap, "Entitlement"),
-						DisplayName:  safeGet(row, headerMap, "Entitlement Display Name"),
-						Description:  safeGet(row, headerMap, "Entitlement Description"),
-					}
-					if entitlementData.ResourceName == "" || entitlementData.Entitlement == "" {
-						if l != nil {
-							l.Warn("Skipping entitlement row due to missing required field(s)", zap.Int("row_index", i+1), zap.Any("row_data", entitlementData))
-						}
-						continue
-					}
-					loadedData.Entitlements = append(loadedData.Entitlements, entitlementData)
+		sectionResources: {
+			fields: []string{"resource_type", "resource_function", "name", "display_name"}, // Required base fields
+			process: func(rowIndex int, row []string, cols columnIndex) {
+				resourceData := ResourceData{
+					ResourceType:     cols.get(row, "resource_type"),
+					ResourceFunction: cols.get(row, "resource_function"),
+					Name:             cols.get(row, "name"),
+					DisplayName:      cols.get(row, "display_name"),
+					Description:      cols.get(row, "description"),
+					ParentResource:   cols.get(row, "parent_resource"),
+					Owner:            cols.get(row, "owner"),
+					RotatedAt:        cols.get(row, "rotated_at"),
+					CredentialHash:   cols.get(row, "credential_hash"),
 				}
-				return nil
+				if resourceData.Name == "" || resourceData.ResourceType == "" || resourceData.ResourceFunction == "" {
+					l.Warn("Skipping resource row due to missing required field(s)", zap.Int("row_index", rowIndex), zap.Any("row_data", resourceData))
+					return
+				}
+				loadedData.Resources = append(loadedData.Resources, resourceData)
 			},
 		},
-		"grants": {
-			headers: []string{"Principal Receiving Grant", "Entitlement Granted to Prinicpal"}, // Required headers (using typo as seen)
-			process: func(sheetName string, allRows [][]string, headerMap map[string]int) error {
-				for i, row := range allRows {
-					if i == 0 {
-						continue
-					}
-					grantData := GrantData{
-						Principal:     safeGet(row, headerMap, "Principal Receiving Grant"),
-						EntitlementId: safeGet(row, headerMap, "Entitlement Granted to Prinicpal"),
-					}
-					if grantData.Principal == "" || grantData.EntitlementId == "" {
-						if l != nil {
-							l.Warn("Skipping grant row due to missing required field(s)", zap.Int("row_index", i+1), zap.Any("row_data", grantData))
-						}
-						continue
-					}
-					loadedData.Grants = append(loadedData.Grants, grantData)
+		sectionEntitlements: {
+			fields: []string{"resource_name", "entitlement", "display_name"}, // Required base fields
+			process: func(rowIndex int, row []string, cols columnIndex) {
+				entitlementData := EntitlementData{
+					ResourceName: cols.get(row, "resource_name"),
+					Entitlement:  cols.get(row, "entitlement"),
+					DisplayName:  cols.get(row, "display_name"),
+					Description:  cols.get(row, "description"),
+				}
+				if members := cols.get(row, "members"); members != "" {
+					entitlementData.Members = []string{members}
+				}
+				if entitlementData.ResourceName == "" || entitlementData.Entitlement == "" {
+					l.Warn("Skipping entitlement row due to missing required field(s)", zap.Int("row_index", rowIndex), zap.Any("row_data", entitlementData))
+					return
 				}
-				return nil
+				loadedData.Entitlements = append(loadedData.Entitlements, entitlementData)
+			},
+		},
+		sectionGrants: {
+			fields: []string{"principal", "entitlement_id"}, // Required fields
+			process: func(rowIndex int, row []string, cols columnIndex) {
+				grantData := GrantData{
+					Principal:     cols.get(row, "principal"),
+					EntitlementId: cols.get(row, "entitlement_id"),
+				}
+				if grantData.Principal == "" || grantData.EntitlementId == "" {
+					l.Warn("Skipping grant row due to missing required field(s)", zap.Int("row_index", rowIndex), zap.Any("row_data", grantData))
+					return
+				}
+				loadedData.Grants = append(loadedData.Grants, grantData)
 			},
 		},
 	}
+}
 
-	for sheetName, config := range sheetConfigs {
-		rows, err := f.GetRows(sheetName)
-		if err != nil {
-			if l != nil {
-				l.Warn("Failed to read sheet, skipping.", zap.String("sheet", sheetName), zap.Error(err))
+// sheetRegion is the part of a worksheet that holds one section's rows.
+// For a sheet source it starts at the header row and is unbounded; for a named table it is the table's cell range.
+type sheetRegion struct {
+	sheet     string
+	headerRow int // 1-based
+	lastRow   int // 1-based, inclusive; 0 means no limit
+	firstCol  int // 1-based
+	lastCol   int // 1-based, inclusive; 0 means no limit
+}
+
+// resolveSheetRegions turns the configured sheet sources into concrete sheet regions.
+// Sheet names may be glob patterns, matched case-insensitively against every sheet in the workbook.
+// Named tables are looked up in the given sheet, or in every sheet when no sheet is given.
+func resolveSheetRegions(wb workbook, sources []SheetSource) ([]sheetRegion, error) {
+	sheetList := wb.sheetList()
+	regions := make([]sheetRegion, 0, len(sources))
+
+	for _, src := range sources {
+		if src.Table != "" {
+			region, err := wb.findTable(sheetList, src)
+			if err != nil {
+				return nil, err
 			}
+			regions = append(regions, region)
 			continue
 		}
 
-		if len(rows) <= 1 {
-			if l != nil {
-				l.Warn("Sheet has no data rows, skipping.", zap.String("sheet", sheetName))
+		headerRow := src.HeaderRow
+		if headerRow == 0 {
+			headerRow = 1
+		}
+
+		if !strings.ContainsAny(src.Sheet, "*?[") {
+			for _, name := range sheetList {
+				if strings.EqualFold(name, src.Sheet) {
+					regions = append(regions, sheetRegion{sheet: name, headerRow: headerRow, firstCol: 1})
+				}
 			}
 			continue
 		}
 
-		headers := rows[0]
-		headerMap := make(map[string]int)
-		foundRequired := true
-		for _, reqHeader := range config.headers {
-			idx := getColumnIndex(headers, reqHeader)
-			if idx == -1 {
-				if l != nil {
-					l.Error("Required column missing in sheet, skipping.", zap.String("sheet", sheetName), zap.String("missing_header", reqHeader))
-				}
-				foundRequired = false
-				break
+		pattern := strings.ToLower(src.Sheet)
+		for _, name := range sheetList {
+			matched, err := path.Match(pattern, strings.ToLower(name))
+			if err != nil {
+				return nil, fmt.Errorf("invalid sheet pattern %q: %w", src.Sheet, err)
+			}
+			if matched {
+				regions = append(regions, sheetRegion{sheet: name, headerRow: headerRow, firstCol: 1})
 			}
-			headerMap[reqHeader] = idx
 		}
+	}
+	return regions, nil
+}
+
+// findTableRegion locates a named Excel Table (ListObject) and returns its cell range.
+func findTableRegion(f *excelize.File, sheetList []string, src SheetSource) (sheetRegion, error) {
+	sheets := sheetList
+	if src.Sheet != "" {
+		sheets = []string{src.Sheet}
+	}
 
-		for idx, h := range headers {
-			if _, required := headerMap[h]; !required {
-				headerMap[h] = idx
+	for _, sheet := range sheets {
+		tables, err := f.GetTables(sheet)
+		if err != nil {
+			return sheetRegion{}, fmt.Errorf("failed to read tables of sheet %s: %w", sheet, err)
+		}
+		for _, table := range tables {
+			if !strings.EqualFold(table.Name, src.Table) {
+				continue
 			}
+			cells := strings.Split(strings.ReplaceAll(table.Range, "$", ""), ":")
+			if len(cells) != 2 {
+				return sheetRegion{}, fmt.Errorf("table %s has unexpected range %q", table.Name, table.Range)
+			}
+			firstCol, firstRow, err := excelize.CellNameToCoordinates(cells[0])
+			if err != nil {
+				return sheetRegion{}, fmt.Errorf("table %s has invalid range %q: %w", table.Name, table.Range, err)
+			}
+			lastCol, lastRow, err := excelize.CellNameToCoordinates(cells[1])
+			if err != nil {
+				return sheetRegion{}, fmt.Errorf("table %s has invalid range %q: %w", table.Name, table.Range, err)
+			}
+			return sheetRegion{sheet: sheet, headerRow: firstRow, lastRow: lastRow, firstCol: firstCol, lastCol: lastCol}, nil
+		}
+	}
+	return sheetRegion{}, fmt.Errorf("named table %q not found", src.Table)
+}
+
+// clip returns the cells of a row that fall within the region's columns.
+func (r sheetRegion) clip(row []string) []string {
+	if r.firstCol > 1 {
+		if r.firstCol-1 >= len(row) {
+			return nil
 		}
+		row = row[r.firstCol-1:]
+	}
+	if r.lastCol > 0 && r.lastCol-r.firstCol+1 < len(row) {
+		row = row[:r.lastCol-r.firstCol+1]
+	}
+	return row
+}
 
-		if !foundRequired {
-			continue
+// streamSheet iterates the rows of a sheet region, resolving the header row to canonical fields and passing each data row to process.
+// Row indexes passed to process are 1-based, matching the row numbers shown in Excel.
+// A region that is missing a required field is skipped with an error log; read errors are returned to the caller.
+func streamSheet(
+	ctx context.Context,
+	wb workbook,
+	region sheetRegion,
+	aliases columnAliases,
+	requiredFields []string,
+	process func(rowIndex int, row []string, cols columnIndex),
+) error {
+	l := ctxzap.Extract(ctx)
+	sheetName := region.sheet
+
+	rows, err := wb.rows(sheetName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			l.Error("failed to close sheet row iterator", zap.Error(err), zap.String("sheet", sheetName))
+		}
+	}()
+
+	rowIndex := 0
+	for rowIndex < region.headerRow && rows.Next() {
+		rowIndex++
+	}
+	if rowIndex < region.headerRow {
+		l.Warn("Sheet has no data rows, skipping.", zap.String("sheet", sheetName))
+		return rows.Error()
+	}
+	headers, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	cols := buildColumnIndex(region.clip(headers), aliases)
+	for _, reqField := range requiredFields {
+		if _, ok := cols[reqField]; !ok {
+			l.Error("Required column missing in sheet, skipping.", zap.String("sheet", sheetName), zap.String("missing_field", reqField))
+			return nil
 		}
+	}
 
-		err = config.process(sheetName, rows, headerMap)
+	dataRows := 0
+	for rows.Next() {
+		rowIndex++
+		if region.lastRow > 0 && rowIndex > region.lastRow {
+			break
+		}
+		row, err := rows.Columns()
 		if err != nil {
-			if l != nil {
-				l.Error("Error processing sheet data", zap.String("sheet", sheetName), zap.Error(err))
-			}
+			return fmt.Errorf("failed to read row %d: %w", rowIndex, err)
+		}
+		row = region.clip(row)
+		if !isBlankRow(row) {
+			process(rowIndex, row, cols)
+		}
+
+		dataRows++
+		if dataRows%excelProgressInterval == 0 {
+			l.Info("Loading sheet rows", zap.String("sheet", sheetName), zap.Int("rows_processed", dataRows))
 		}
 	}
+	if err := rows.Error(); err != nil {
+		return err
+	}
 
-	if l != nil {
-		l.Info("Finished loading data from file",
-			zap.Int("user_count", len(loadedData.Users)),
-			zap.Int("resource_count", len(loadedData.Resources)),
-			zap.Int("entitlement_count", len(loadedData.Entitlements)),
-			zap.Int("grant_count", len(loadedData.Grants)),
-		)
+	if dataRows == 0 {
+		l.Warn("Sheet has no data rows, skipping.", zap.String("sheet", sheetName))
+	} else {
+		l.Debug("Finished loading sheet", zap.String("sheet", sheetName), zap.Int("rows_processed", dataRows))
 	}
-	return loadedData, nil
+	return nil
 }
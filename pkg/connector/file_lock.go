@@ -0,0 +1,144 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	// fileLockTimeout bounds how long a change waits for another process to release the lock of the input file.
+	fileLockTimeout = 30 * time.Second
+	// fileLockRetryInterval is the delay between two attempts at taking the lock.
+	fileLockRetryInterval = 100 * time.Millisecond
+	// fileLockStaleAfter is the age after which a lock is considered left behind by a process that died while writing.
+	// Writing a change takes seconds, so a lock this old is never held by a live writer.
+	fileLockStaleAfter = 10 * time.Minute
+)
+
+// The fileLock struct is an advisory lock on the input file, held while a change is written back.
+// It is a lock file next to the input, created exclusively, so connector processes sharing the file never write at the same time.
+// People editing the file do not take it; their edits are detected through the file's content hash instead.
+type fileLock struct {
+	path  string
+	owner []byte
+}
+
+// The fileLockOwner struct is the content of a lock file, identifying the process holding it for whoever finds it left behind.
+type fileLockOwner struct {
+	PID      int    `json:"pid"`
+	Hostname string `json:"hostname"`
+	Acquired string `json:"acquired"`
+}
+
+// fileLockPath returns the path of the lock file of an input file.
+func fileLockPath(filePath string) string {
+	return filePath + ".lock"
+}
+
+// acquireFileLock takes the lock of an input file, waiting up to fileLockTimeout while another process holds it.
+// A lock older than fileLockStaleAfter is removed and taken over.
+func acquireFileLock(ctx context.Context, filePath string) (*fileLock, error) {
+	l := ctxzap.Extract(ctx)
+
+	hostname, _ := os.Hostname()
+	owner, err := json.Marshal(fileLockOwner{PID: os.Getpid(), Hostname: hostname, Acquired: time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return nil, err
+	}
+	lock := &fileLock{path: fileLockPath(filePath), owner: owner}
+
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		created, err := lock.tryCreate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", lock.path, err)
+		}
+		if created {
+			return lock, nil
+		}
+
+		holder, err := os.ReadFile(lock.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Released meanwhile
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock file %s: %w", lock.path, err)
+		}
+		if info, err := os.Stat(lock.path); err == nil && time.Since(info.ModTime()) > fileLockStaleAfter {
+			l.Warn("Removing stale lock file", zap.String("path", lock.path), zap.ByteString("owner", holder))
+			if err := os.Remove(lock.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to remove stale lock file %s: %w", lock.path, err)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another process (%s); remove %s if no connector is writing to it",
+				filePath, holder, filepath.Base(lock.path))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for lock file %s: %w", lock.path, ctx.Err())
+		case <-time.After(fileLockRetryInterval):
+		}
+	}
+}
+
+// tryCreate creates the lock file if it does not exist, and reports whether it did.
+func (fl *fileLock) tryCreate() (bool, error) {
+	f, err := os.OpenFile(fl.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(fl.owner); err != nil {
+		f.Close()
+		os.Remove(fl.path)
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(fl.path)
+		return false, err
+	}
+	return true, nil
+}
+
+// release removes the lock file, unless it was taken over as stale meanwhile. Failures are logged, as the change is already written.
+func (fl *fileLock) release(ctx context.Context) {
+	l := ctxzap.Extract(ctx)
+
+	if content, err := os.ReadFile(fl.path); err != nil || string(content) != string(fl.owner) {
+		l.Warn("Lock file was taken over by another process", zap.String("path", fl.path))
+		return
+	}
+	if err := os.Remove(fl.path); err != nil {
+		l.Warn("Failed to remove lock file", zap.String("path", fl.path), zap.Error(err))
+	}
+}
+
+// officeOwnerFile returns the path of the owner file a spreadsheet application keeps next to a workbook while it is open,
+// or "" when there is none. A workbook open in Excel or LibreOffice would be overwritten the next time it is saved there.
+func officeOwnerFile(filePath string) string {
+	dir, name := filepath.Split(filePath)
+	candidates := []string{
+		filepath.Join(dir, "~$"+name),          // Excel
+		filepath.Join(dir, ".~lock."+name+"#"), // LibreOffice
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
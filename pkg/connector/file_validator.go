@@ -0,0 +1,160 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Severities of a validationIssue.
+const (
+	severityError   = "error"   // The record is skipped or the reference is dropped during a sync
+	severityWarning = "warning" // The record is synced, but a value is ignored or replaced by a default
+)
+
+// The validationIssue struct describes one problem found in the records of the input file.
+type validationIssue struct {
+	Severity string `json:"severity"` // severityError or severityWarning
+	Section  string `json:"section"`  // Section holding the record, e.g. "resources"
+	Record   string `json:"record"`   // Name of the record, or its entitlement or grant key
+	Message  string `json:"message"`
+}
+
+// The validationReport struct summarizes the records of the input file and the problems a sync would run into.
+// It applies the same rules as the caches built for a sync, which only log these problems.
+type validationReport struct {
+	Users        int               `json:"users"`
+	Resources    int               `json:"resources"`
+	Entitlements int               `json:"entitlements"`
+	Grants       int               `json:"grants"`
+	Errors       int               `json:"errors"`
+	Warnings     int               `json:"warnings"`
+	Issues       []validationIssue `json:"issues"`
+}
+
+// add records an issue and updates the counters.
+func (r *validationReport) add(severity, section, record, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, validationIssue{Severity: severity, Section: section, Record: record, Message: fmt.Sprintf(format, args...)})
+	if severity == severityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+// validateLoadedData checks the records of the input file for duplicate names, dangling references and values
+// that cannot be parsed, in the order a sync processes them.
+func validateLoadedData(data *LoadedData) *validationReport {
+	report := &validationReport{
+		Users:        len(data.Users),
+		Resources:    len(data.Resources),
+		Entitlements: len(data.Entitlements),
+		Grants:       len(data.Grants),
+		Issues:       []validationIssue{},
+	}
+
+	names := make(map[string]string) // Record name to the section defining it
+	secrets := make(map[string]bool)
+
+	for i, u := range data.Users {
+		if u.Name == "" {
+			report.add(severityError, sectionUsers, fmt.Sprintf("#%d", i+1), "user has no name")
+			continue
+		}
+		if _, exists := names[u.Name]; exists {
+			report.add(severityError, sectionUsers, u.Name, "name is already used by another user")
+			continue
+		}
+		names[u.Name] = sectionUsers
+
+		switch strings.ToLower(strings.TrimSpace(u.Status)) {
+		case "", "enabled", "active", "disabled", "inactive", "suspended":
+		default:
+			report.add(severityWarning, sectionUsers, u.Name, "unrecognized status %q, the user is synced as enabled", u.Status)
+		}
+		switch strings.ToLower(strings.TrimSpace(u.Type)) {
+		case "", "service", "system", "bot", "machine", "human", "user", "person":
+		default:
+			report.add(severityWarning, sectionUsers, u.Name, "unrecognized type %q, the user is synced as human", u.Type)
+		}
+		if u.LastLogin != "" {
+			if _, err := time.Parse("01/02/2006", u.LastLogin); err != nil {
+				report.add(severityWarning, sectionUsers, u.Name, "last_login %q is not in MM/DD/YYYY format and is ignored", u.LastLogin)
+			}
+		}
+	}
+
+	for i, r := range data.Resources {
+		if r.Name == "" || r.ResourceType == "" || r.ResourceFunction == "" {
+			record := r.Name
+			if record == "" {
+				record = fmt.Sprintf("#%d", i+1)
+			}
+			report.add(severityError, sectionResources, record, "resource_type, resource_function and name are required")
+			continue
+		}
+		if section, exists := names[r.Name]; exists {
+			report.add(severityError, sectionResources, r.Name, "name is already used by a record of the %s section", section)
+			continue
+		}
+		names[r.Name] = sectionResources
+
+		if _, known := TraitMap[strings.ToLower(r.ResourceFunction)]; !known {
+			report.add(severityWarning, sectionResources, r.Name, "unrecognized resource_function %q, the resource type has no trait", r.ResourceFunction)
+		}
+		secrets[r.Name] = strings.EqualFold(r.ResourceFunction, "secret")
+		if r.RotatedAt != "" {
+			if _, err := time.Parse(time.RFC3339, r.RotatedAt); err != nil {
+				report.add(severityWarning, sectionResources, r.Name, "rotated_at %q is not an RFC 3339 time and is ignored", r.RotatedAt)
+			}
+		}
+	}
+
+	for _, r := range data.Resources {
+		if names[r.Name] != sectionResources {
+			continue
+		}
+		if r.ParentResource != "" && names[r.ParentResource] == "" {
+			report.add(severityError, sectionResources, r.Name, "parent resource %q not found", r.ParentResource)
+		}
+		if r.Owner != "" {
+			switch {
+			case !secrets[r.Name]:
+				report.add(severityWarning, sectionResources, r.Name, "owner is ignored, as the resource is not a secret")
+			case names[r.Owner] == "":
+				report.add(severityError, sectionResources, r.Name, "owner %q not found", r.Owner)
+			}
+		}
+	}
+
+	entitlementKeys := make(map[string]bool)
+	for i, e := range data.Entitlements {
+		if e.ResourceName == "" || e.Entitlement == "" {
+			report.add(severityError, sectionEntitlements, fmt.Sprintf("#%d", i+1), "resource_name and entitlement are required")
+			continue
+		}
+		key := e.ResourceName + ":" + e.Entitlement
+		if entitlementKeys[key] {
+			report.add(severityError, sectionEntitlements, key, "entitlement is defined more than once")
+			continue
+		}
+		if names[e.ResourceName] == "" {
+			report.add(severityError, sectionEntitlements, key, "resource %q not found", e.ResourceName)
+			continue
+		}
+		entitlementKeys[key] = true
+	}
+
+	for _, g := range data.Grants {
+		key := g.Principal + " -> " + g.EntitlementId
+		if names[g.Principal] == "" && !entitlementKeys[g.Principal] {
+			report.add(severityError, sectionGrants, key, "principal %q is neither a user, a resource nor an entitlement", g.Principal)
+			continue
+		}
+		if !entitlementKeys[g.EntitlementId] {
+			report.add(severityError, sectionGrants, key, "entitlement %q not found", g.EntitlementId)
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,347 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/fsnotify/fsnotify"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultWatchDebounce is how long the input file must go without changes before a change is picked up.
+	defaultWatchDebounce = 2 * time.Second
+	// maxWatchEvents is the number of events kept for ListEvents; older events are dropped and only reach ConductorOne through a full sync.
+	maxWatchEvents = 10000
+)
+
+// The WatchOptions struct configures watch mode, where changes to the input file are reported through the event feed.
+type WatchOptions struct {
+	// Debounce is how long the input file must go without changes before a change is picked up, so a burst of writes
+	// is read once. Zero uses defaultWatchDebounce.
+	Debounce time.Duration
+}
+
+// The watchEvent struct is an event kept for ListEvents, with its position in the feed.
+type watchEvent struct {
+	seq   uint64
+	event *v2.Event
+}
+
+// The fileWatcher struct watches the input file and turns its changes into grant and revoke events.
+// Changes are debounced, then the file is loaded and validated through the data store; content that fails to load
+// or has validation errors the last accepted version did not have is reported in the log and produces no events until
+// it is fixed. Errors the file already had, e.g. a duplicate entitlement, do not hold back its later changes.
+// Events compare the grants of the file against those of the last accepted version.
+type fileWatcher struct {
+	store    *dataStore
+	opts     WatchOptions
+	lifetime context.Context // Watching stops once it is done
+
+	startMu sync.Mutex
+	started bool
+
+	mu      sync.Mutex
+	feedID  string // Random prefix of event IDs, so IDs differ between connector processes
+	events  []watchEvent
+	lastSeq uint64
+	grants  map[string]*v2.Grant    // Grants of the last accepted version of the file, by ID
+	errors  map[validationIssue]int // Validation errors of the last accepted version of the file, with their number
+	dirs    map[string]bool         // Directories being watched
+}
+
+// newFileWatcher creates a fileWatcher for the input file of a data store. It watches nothing until started,
+// and stops watching once lifetime is done.
+func newFileWatcher(lifetime context.Context, store *dataStore, opts WatchOptions) *fileWatcher {
+	if opts.Debounce <= 0 {
+		opts.Debounce = defaultWatchDebounce
+	}
+	return &fileWatcher{store: store, opts: opts, lifetime: lifetime, dirs: make(map[string]bool)}
+}
+
+// The start method loads the input file as the baseline for events and starts watching it, unless it already started.
+// When the file cannot be loaded the error is returned and the next call tries again, so the feed recovers once the file is fixed.
+func (fw *fileWatcher) start(ctx context.Context) error {
+	fw.startMu.Lock()
+	defer fw.startMu.Unlock()
+
+	if fw.started {
+		return nil
+	}
+	if err := fw.lifetime.Err(); err != nil {
+		return fmt.Errorf("the connector is shutting down: %w", err)
+	}
+	if err := fw.run(ctx); err != nil {
+		return err
+	}
+	fw.started = true
+	return nil
+}
+
+// run loads the baseline, sets up the fsnotify watcher and starts the goroutine handling its events,
+// which runs until the watcher's lifetime is done.
+func (fw *fileWatcher) run(ctx context.Context) error {
+	l := ctxzap.Extract(ctx)
+
+	snapshot, err := fw.store.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load input file to watch: %w", err)
+	}
+	feedID, err := newRequestID()
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch input file: %w", err)
+	}
+	fw.dirs = make(map[string]bool)
+	if err := fw.watchFiles(watcher, snapshot.loadedData.includedFiles); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	fw.mu.Lock()
+	fw.feedID = feedID
+	fw.grants = snapshotGrants(snapshot)
+	fw.errors = validationErrors(validateLoadedData(snapshot.loadedData))
+	fw.mu.Unlock()
+
+	l.Info("Watching input file for changes", zap.String("file", fw.store.filePath), zap.Duration("debounce", fw.opts.Debounce))
+	go fw.loop(fw.lifetime, watcher)
+	return nil
+}
+
+// watchFiles watches the directories holding the input file and the files it includes. A directory input is watched
+// itself. Directories rather than files are watched, since a watch on a file is lost when an editor or the connector
+// replaces it by renaming a new file over it.
+func (fw *fileWatcher) watchFiles(watcher *fsnotify.Watcher, includedFiles []string) error {
+	inputDir := filepath.Dir(fw.store.filePath)
+	if info, err := os.Stat(fw.store.filePath); err == nil && info.IsDir() {
+		inputDir = fw.store.filePath
+	}
+	for _, path := range append([]string{inputDir}, includedFiles...) {
+		dir := path
+		if path != inputDir {
+			dir = filepath.Dir(path)
+		}
+		dir = filepath.Clean(dir)
+		if fw.dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		fw.dirs[dir] = true
+	}
+	return nil
+}
+
+// loop handles the watcher's events until ctx is done. Every relevant event restarts the debounce timer,
+// and the file is read once the timer fires.
+func (fw *fileWatcher) loop(ctx context.Context, watcher *fsnotify.Watcher) {
+	l := ctxzap.Extract(ctx)
+	defer watcher.Close()
+
+	timer := time.NewTimer(fw.opts.Debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if fw.relevant(event.Name) && !event.Has(fsnotify.Chmod) {
+				timer.Reset(fw.opts.Debounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			l.Warn("Error watching input file", zap.String("file", fw.store.filePath), zap.Error(err))
+		case <-timer.C:
+			fw.refresh(ctx, watcher)
+		}
+	}
+}
+
+// relevant reports whether a changed path is the input file, a file it includes, or a file in a directory input.
+// Temporary files, lock files and the change journal next to the input file are not.
+func (fw *fileWatcher) relevant(path string) bool {
+	path = filepath.Clean(path)
+	input := filepath.Clean(fw.store.filePath)
+	if path == input || filepath.Dir(path) == input {
+		return true
+	}
+	fw.store.mu.Lock()
+	defer fw.store.mu.Unlock()
+	for included := range fw.store.versions {
+		if filepath.Clean(included) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh loads and validates the changed input file, and records events for the grants added and removed since the
+// last accepted version. The change is rejected when it adds validation errors to those of that version.
+func (fw *fileWatcher) refresh(ctx context.Context, watcher *fsnotify.Watcher) {
+	l := ctxzap.Extract(ctx)
+
+	snapshot, err := fw.store.load(ctx)
+	if err != nil {
+		l.Warn("Input file changed but could not be loaded; waiting for the next change", zap.String("file", fw.store.filePath), zap.Error(err))
+		return
+	}
+	report := validateLoadedData(snapshot.loadedData)
+	errorCounts := validationErrors(report)
+	fw.mu.Lock()
+	var added []validationIssue
+	for _, issue := range report.Issues {
+		if errorCounts[issue] > fw.errors[issue] && !slices.Contains(added, issue) {
+			added = append(added, issue)
+		}
+	}
+	fw.mu.Unlock()
+	if len(added) > 0 {
+		for _, issue := range added {
+			l.Warn("New validation error in changed input file",
+				zap.String("section", issue.Section),
+				zap.String("record", issue.Record),
+				zap.String("message", issue.Message))
+		}
+		l.Warn("Input file changed but has new validation errors; waiting for the next change",
+			zap.String("file", fw.store.filePath),
+			zap.Int("new_errors", len(added)),
+			zap.Int("errors", report.Errors))
+		return
+	}
+	if err := fw.watchFiles(watcher, snapshot.loadedData.includedFiles); err != nil {
+		l.Warn("Failed to watch files included by the input file", zap.Error(err))
+	}
+
+	grants := snapshotGrants(snapshot)
+	now := timestamppb.Now()
+	var events []*v2.Event
+	for _, id := range sortedKeys(grants) {
+		if _, ok := fw.grants[id]; !ok {
+			events = append(events, &v2.Event{
+				OccurredAt: now,
+				Event:      &v2.Event_GrantEvent{GrantEvent: &v2.GrantEvent{Grant: grants[id]}},
+			})
+		}
+	}
+	for _, id := range sortedKeys(fw.grants) {
+		if _, ok := grants[id]; !ok {
+			g := fw.grants[id]
+			events = append(events, &v2.Event{
+				OccurredAt: now,
+				Event:      &v2.Event_RevokeEvent{RevokeEvent: &v2.RevokeEvent{Entitlement: g.Entitlement, Principal: g.Principal}},
+			})
+		}
+	}
+
+	fw.mu.Lock()
+	fw.grants = grants
+	fw.errors = errorCounts
+	for _, event := range events {
+		fw.lastSeq++
+		event.Id = fmt.Sprintf("%s-%d", fw.feedID, fw.lastSeq)
+		fw.events = append(fw.events, watchEvent{seq: fw.lastSeq, event: event})
+	}
+	if len(fw.events) > maxWatchEvents {
+		fw.events = append([]watchEvent(nil), fw.events[len(fw.events)-maxWatchEvents:]...)
+	}
+	fw.mu.Unlock()
+
+	l.Info("Input file changed",
+		zap.String("file", fw.store.filePath),
+		zap.Int("events", len(events)),
+		zap.Int("errors", report.Errors),
+		zap.Int("warnings", report.Warnings))
+}
+
+// list returns up to size events after the cursor, skipping events that occurred before earliest when it is set.
+// It returns the cursor to continue from and whether more events follow. Cursors are "<feed ID>:<position>";
+// a cursor from another connector process starts from the first event kept.
+func (fw *fileWatcher) list(earliest *timestamppb.Timestamp, cursor string, size int) ([]*v2.Event, string, bool, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	var after uint64
+	if feedID, position, ok := strings.Cut(cursor, ":"); ok && feedID == fw.feedID {
+		var err error
+		if after, err = strconv.ParseUint(position, 10, 64); err != nil {
+			return nil, "", false, fmt.Errorf("invalid event cursor %q: %w", cursor, err)
+		}
+	}
+
+	var events []*v2.Event
+	for _, e := range fw.events {
+		if e.seq <= after {
+			continue
+		}
+		if len(events) == size {
+			return events, fw.cursor(after), true, nil
+		}
+		after = e.seq
+		if earliest != nil && e.event.OccurredAt.AsTime().Before(earliest.AsTime()) {
+			continue
+		}
+		events = append(events, e.event)
+	}
+	return events, fw.cursor(fw.lastSeq), false, nil
+}
+
+// cursor returns the cursor for the events after a position. The caller must hold fw.mu.
+func (fw *fileWatcher) cursor(position uint64) string {
+	return fw.feedID + ":" + strconv.FormatUint(position, 10)
+}
+
+// snapshotGrants returns the grants of a snapshot by ID. Grants are indexed under both their principal and their resource,
+// so each grant is only kept once.
+func snapshotGrants(snapshot *dataSnapshot) map[string]*v2.Grant {
+	grants := make(map[string]*v2.Grant)
+	for _, list := range snapshot.grantsByRes {
+		for _, g := range list {
+			grants[g.Id] = g
+		}
+	}
+	return grants
+}
+
+// validationErrors counts the errors of a validation report by issue, for comparing the errors of two versions of the file.
+func validationErrors(report *validationReport) map[validationIssue]int {
+	counts := make(map[validationIssue]int)
+	for _, issue := range report.Issues {
+		if issue.Severity == severityError {
+			counts[issue]++
+		}
+	}
+	return counts
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
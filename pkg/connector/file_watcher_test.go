@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// watchedModel returns a YAML input file granting eng:member to the given principals. The entitlement is defined
+// twice, a validation error every version of the file has.
+func watchedModel(principals ...string) string {
+	content := `users:
+  - name: alice
+  - name: bob
+  - name: carol
+  - name: dave
+resources:
+  - resource_type: team
+    resource_function: group
+    name: eng
+entitlements:
+  - resource_name: eng
+    entitlement: member
+  - resource_name: eng
+    entitlement: member
+grants:
+`
+	for _, p := range principals {
+		content += fmt.Sprintf("  - principal: %s\n    entitlement_id: eng:member\n", p)
+	}
+	return content
+}
+
+// describeEvents returns "grant <principal>" or "revoke <principal>" for each event.
+func describeEvents(events []*v2.Event) []string {
+	var rv []string
+	for _, e := range events {
+		switch {
+		case e.GetGrantEvent() != nil:
+			rv = append(rv, "grant "+e.GetGrantEvent().GetGrant().GetPrincipal().GetId().GetResource())
+		case e.GetRevokeEvent() != nil:
+			rv = append(rv, "revoke "+e.GetRevokeEvent().GetPrincipal().GetId().GetResource())
+		}
+	}
+	return rv
+}
+
+func TestFileWatcherDebouncesAndDiffsGrants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const debounce = 200 * time.Millisecond
+
+	filePath := filepath.Join(t.TempDir(), "access.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(watchedModel("alice"))
+
+	fw := newFileWatcher(ctx, newDataStore(filePath), WatchOptions{Debounce: debounce})
+	if err := fw.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cursor := ""
+	// waitForEvents returns the events recorded after the cursor once there are some, or none after the timeout
+	waitForEvents := func(timeout time.Duration) []string {
+		t.Helper()
+		deadline := time.Now().Add(timeout)
+		for {
+			events, next, _, err := fw.list(nil, cursor, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) > 0 || time.Now().After(deadline) {
+				cursor = next
+				return describeEvents(events)
+			}
+			time.Sleep(debounce / 10)
+		}
+	}
+
+	// Versions written within the debounce delay are read once, so dave's grant, added then removed, is never reported
+	write(watchedModel("alice", "dave"))
+	time.Sleep(debounce / 4)
+	write(watchedModel("bob", "carol"))
+	if got, want := waitForEvents(20*debounce), []string{"grant bob", "grant carol", "revoke alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+
+	// A version adding a validation error is held back until it is fixed
+	write(watchedModel("bob", "carol", "erin"))
+	if got := waitForEvents(5 * debounce); len(got) > 0 {
+		t.Errorf("events for a version with a new validation error = %q, want none", got)
+	}
+	write(watchedModel("carol", "dave"))
+	if got, want := waitForEvents(20*debounce), []string{"grant dave", "revoke bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
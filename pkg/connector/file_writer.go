@@ -0,0 +1,562 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// writableFormats lists the input formats that changes can be written back to.
+var writableFormats = map[InputFormat]bool{
+	InputFormatExcel: true,
+	InputFormatYAML:  true,
+	InputFormatJSON:  true,
+}
+
+// The writable method reports whether changes can be written back to the input file, based on its format.
+// Syncers of read-only inputs do not advertise provisioning, see FileConnector.ResourceSyncers.
+func (ds *dataStore) writable() bool {
+	format, err := resolveInputFormat(ds.filePath, ds.loadOptions.format())
+	return err == nil && writableFormats[format]
+}
+
+// sheetHeaders lists the header written for each canonical field when an Excel workbook is regenerated.
+var sheetHeaders = map[string][]string{
+	sectionUsers:        {"Name", "Display Name", "Email", "Status", "Last Login", "Type"},
+	sectionResources:    {"Resource Type", "Resource Function", "Name", "Display Name", "Description", "Parent Resource", "Owner", "Rotated At", "Credential Hash"},
+	sectionEntitlements: {"Resource Name", "Entitlement", "Display Name", "Description"},
+	sectionGrants:       {"Principal", "Entitlement ID"},
+}
+
+// errNoChange is returned by a fileChange's apply function when the records already reflect the change.
+// The update then succeeds without rewriting the file, which keeps retried requests idempotent.
+var errNoChange = errors.New("no change")
+
+// errConflict is returned when a change no longer applies to the input file because it was edited since the connector
+// last read it, e.g. a resource to create was added by hand. Syncing again and retrying the request resolves it.
+var errConflict = errors.New("conflict with edits made to the input file")
+
+// errFileChanged is returned when the input file is changed by someone else while a change is being written to it.
+var errFileChanged = errors.New("the input file was changed while the change was written")
+
+// maxWriteAttempts bounds the number of times a change is applied again after the input file changed while it was written.
+const maxWriteAttempts = 3
+
+// The fileChange struct is one edit of the input file's records, applied by dataStore.update.
+// The principal, resource, entitlement and ticket, when known, are recorded in the change journal
+// and in the commit of the change in git-backed mode.
+type fileChange struct {
+	operation   string                       // Short description of the edit, used in logs and errors, e.g. "create resource"
+	requestID   string                       // ID of the request making the change, generated when empty
+	principal   string                       // User or identity the change is about, as "<resource type>:<name>"
+	resource    string                       // Resource the change is about, as "<resource type>:<name>"
+	entitlement string                       // Entitlement the change is about, as "<resource name>:<entitlement>"
+	ticket      string                       // ID of the ticket requesting the change
+	apply       func(data *LoadedData) error // Edits the records in place; an error aborts the change without writing
+}
+
+// commitMessage returns the message of the commit recording the change in git-backed mode.
+// The subject names the operation; the principal, resource, entitlement and ticket follow as git trailers.
+func (c fileChange) commitMessage() string {
+	var sb strings.Builder
+	sb.WriteString("baton-file: " + c.operation + "\n")
+	trailers := []struct{ key, value string }{
+		{"Principal", c.principal},
+		{"Resource", c.resource},
+		{"Entitlement", c.entitlement},
+		{"Ticket", c.ticket},
+	}
+	separated := false
+	for _, t := range trailers {
+		if t.value == "" {
+			continue
+		}
+		if !separated {
+			sb.WriteString("\n")
+			separated = true
+		}
+		sb.WriteString(t.key + ": " + t.value + "\n")
+	}
+	return sb.String()
+}
+
+// The update method applies a change to the current content of the input file and writes the result back.
+// The file is re-read first, so the change applies to its latest content rather than to the cached snapshot,
+// and it is replaced atomically, so a concurrent reader sees either the old or the new version.
+// Only YAML, JSON and Excel files can be written, and the file must not include other files. They are edited in place,
+// see editWorkbook, editYamlFile and editJSONFile.
+// Each change written is appended to the change journal next to the input file, see appendJournalEntry.
+// In git-backed mode each change is also committed on its own, together with the journal and its baseline. Changes to the
+// checked-out branch require the file to have no uncommitted edits, so the commit holds nothing else; changes to a separate
+// branch apply to that branch's version of the file and leave the working tree untouched, and are journaled in the branch's journal.
+//
+// Changes are planned against the content the connector last read or wrote, and the file may be edited by people meanwhile.
+// When its content hash no longer matches, the change is re-applied to the current records, and fails with errConflict
+// if it no longer applies. A file edited while the change is being written is re-read and the change applied again,
+// up to maxWriteAttempts times. The advisory lock file keeps other connector processes from writing at the same time.
+func (ds *dataStore) update(ctx context.Context, change fileChange) error {
+	l := ctxzap.Extract(ctx)
+
+	ds.writeMu.Lock()
+	defer ds.writeMu.Unlock()
+
+	format, err := resolveInputFormat(ds.filePath, ds.loadOptions.format())
+	if err != nil {
+		return err
+	}
+	if !writableFormats[format] {
+		return fmt.Errorf("%s: writing changes back to %s input is not supported", change.operation, format)
+	}
+	if change.requestID == "" {
+		if change.requestID, err = newRequestID(); err != nil {
+			return fmt.Errorf("%s: %w", change.operation, err)
+		}
+	}
+
+	lock, err := acquireFileLock(ctx, ds.filePath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", change.operation, err)
+	}
+	defer lock.release(ctx)
+
+	if format == InputFormatExcel {
+		if owner := officeOwnerFile(ds.filePath); owner != "" {
+			return fmt.Errorf("%s: %w: %s is open in a spreadsheet application (%s exists); close it before writing changes back",
+				change.operation, errConflict, ds.filePath, filepath.Base(owner))
+		}
+	}
+
+	ds.mu.Lock()
+	plannedHash := ds.contentHash
+	ds.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		err := ds.writeChange(ctx, change, format, plannedHash)
+		if !errors.Is(err, errFileChanged) || attempt == maxWriteAttempts {
+			return err
+		}
+		l.Warn("Input file changed while a change was written; applying it again",
+			zap.String("operation", change.operation),
+			zap.Int("attempt", attempt))
+	}
+}
+
+// writeChange makes one attempt at applying a change to the input file, for update.
+// plannedHash is the hash of the content the change was planned against, or "" when the file was never read.
+// It returns errFileChanged when the file is changed by someone else while the new content is written.
+func (ds *dataStore) writeChange(ctx context.Context, change fileChange, format InputFormat, plannedHash string) error {
+	l := ctxzap.Extract(ctx)
+
+	sourcePath := ds.filePath
+	if ds.git != nil {
+		if ds.git.branch != "" {
+			tmpDir, err := os.MkdirTemp("", "baton-file-*")
+			if err != nil {
+				return fmt.Errorf("%s: %w", change.operation, err)
+			}
+			defer os.RemoveAll(tmpDir)
+			ref, err := ds.git.baseRef(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", change.operation, err)
+			}
+			if sourcePath, err = ds.git.readFile(ctx, ref, tmpDir); err != nil {
+				return fmt.Errorf("%s: %w", change.operation, err)
+			}
+			// The branch keeps its own journal next to its version of the file, and the change is journaled there
+			for _, repoPath := range []string{journalPath(ds.git.path), journalBaselinePath(ds.git.path)} {
+				if _, _, err := ds.git.copyFile(ctx, ref, repoPath, tmpDir); err != nil {
+					return fmt.Errorf("%s: %w", change.operation, err)
+				}
+			}
+			// The branch is guarded by commitToBranch instead: it only advances from the commit that was read
+			plannedHash = ""
+		} else {
+			state, err := ds.git.state(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", change.operation, err)
+			}
+			if state.dirty {
+				return fmt.Errorf("%s: %s has uncommitted changes; commit or discard them before writing changes back", change.operation, ds.git.path)
+			}
+		}
+	}
+
+	contentBefore, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("%s: failed to read %s: %w", change.operation, ds.filePath, err)
+	}
+	hashBefore := hashFileContent(contentBefore)
+	conflicted := plannedHash != "" && hashBefore != plannedHash
+	if conflicted {
+		l.Warn("Input file changed since the connector last read it; applying change to its current content",
+			zap.String("operation", change.operation),
+			zap.String("planned_hash", plannedHash),
+			zap.String("current_hash", hashBefore))
+	}
+
+	data, err := LoadFileData(ctx, sourcePath, ds.loadOptions)
+	if err != nil {
+		return fmt.Errorf("%s: failed to load data file: %w", change.operation, err)
+	}
+	if len(data.includedFiles) > 0 {
+		return fmt.Errorf("%s: writing changes back to a file that includes other files is not supported", change.operation)
+	}
+	recordsBefore, err := journalRecords(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", change.operation, err)
+	}
+
+	if err := change.apply(data); err != nil {
+		if errors.Is(err, errNoChange) {
+			l.Debug("Input file already reflects change", zap.String("operation", change.operation))
+			return nil
+		}
+		if conflicted {
+			return fmt.Errorf("%s: %w: %w", change.operation, errConflict, err)
+		}
+		return fmt.Errorf("%s: %w", change.operation, err)
+	}
+
+	unchanged := func() error {
+		content, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return err
+		}
+		if hashFileContent(content) != hashBefore {
+			return errFileChanged
+		}
+		return nil
+	}
+	recordsPlanned, err := journalRecords(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", change.operation, err)
+	}
+	changes := diffJournalRecords(recordsBefore, recordsPlanned)
+	switch format {
+	case InputFormatExcel:
+		err = editWorkbook(ctx, sourcePath, ds.loadOptions, changes, unchanged)
+	case InputFormatYAML:
+		err = editYamlFile(sourcePath, ds.loadOptions, changes, unchanged)
+	case InputFormatJSON:
+		err = editJSONFile(sourcePath, ds.loadOptions, changes, unchanged)
+	default:
+		err = fmt.Errorf("writing %s files is not supported", format)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to write %s: %w", change.operation, ds.filePath, err)
+	}
+
+	contentAfter, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("%s: failed to read back %s: %w", change.operation, ds.filePath, err)
+	}
+	if sourcePath == ds.filePath {
+		ds.mu.Lock()
+		ds.snapshot = nil
+		ds.contentHash = hashFileContent(contentAfter)
+		ds.mu.Unlock()
+	}
+
+	// The journal is written before the commit in git-backed mode, so the commit holds it too
+	toBranch := ds.git != nil && ds.git.branch != ""
+	journalFile := ds.filePath
+	if toBranch {
+		journalFile = sourcePath
+	}
+	var journalBefore journalState
+	if ds.git != nil && !toBranch {
+		if journalBefore, err = readJournalState(ds.filePath); err != nil {
+			return fmt.Errorf("%s: failed to read the journal: %w", change.operation, err)
+		}
+	}
+	// undo takes back a change to the checked-out branch that cannot be committed: left in place, the uncommitted change
+	// would make every later write fail on a dirty working tree
+	undo := func() {
+		if ds.git == nil || toBranch {
+			return
+		}
+		if err := ds.restore(contentBefore, contentAfter); err != nil {
+			l.Error("Failed to restore input file after a change could not be committed",
+				zap.String("operation", change.operation), zap.String("file", ds.filePath), zap.Error(err))
+		}
+		if err := journalBefore.restore(ds.filePath); err != nil {
+			l.Error("Failed to restore the journal after a change could not be committed",
+				zap.String("operation", change.operation), zap.String("file", journalPath(ds.filePath)), zap.Error(err))
+		}
+	}
+
+	if err := ds.journal(ctx, change, journalFile, sourcePath, contentBefore, contentAfter, recordsBefore); err != nil {
+		if ds.git == nil {
+			return fmt.Errorf("%s: the change was written but could not be journaled: %w", change.operation, err)
+		}
+		undo()
+		return fmt.Errorf("%s: failed to journal the change: %w", change.operation, err)
+	}
+
+	if ds.git != nil {
+		var commit string
+		if toBranch {
+			commit, err = ds.git.commitToBranch(ctx, branchFiles(ds.git.path, sourcePath), change.commitMessage())
+		} else {
+			commit, err = ds.git.commitWorkTree(ctx, workTreeFiles(ds.git.path, ds.filePath), change.commitMessage())
+		}
+		if err != nil {
+			undo()
+			return fmt.Errorf("%s: failed to commit %s: %w", change.operation, ds.git.path, err)
+		}
+		l.Info("Committed change to input file",
+			zap.String("operation", change.operation),
+			zap.String("file", ds.git.path),
+			zap.String("branch", ds.git.branch),
+			zap.String("commit", commit))
+	} else {
+		l.Info("Wrote change to input file", zap.String("operation", change.operation), zap.String("file", ds.filePath))
+	}
+	return nil
+}
+
+// branchFiles returns the files committed to the branch for a change in git-backed mode with a branch, as taken by
+// commitToBranch: the input file written at sourcePath and the journal and journal baseline next to it.
+// The journal is committed with the change, so verifying the journal of a checkout of the branch checks the branch's file.
+func branchFiles(repoPath string, sourcePath string) map[string]string {
+	files := map[string]string{repoPath: sourcePath}
+	if _, err := os.Stat(journalPath(sourcePath)); err == nil {
+		files[journalPath(repoPath)] = journalPath(sourcePath)
+	}
+	if _, err := os.Stat(journalBaselinePath(sourcePath)); err == nil {
+		files[journalBaselinePath(repoPath)] = journalBaselinePath(sourcePath)
+	}
+	return files
+}
+
+// workTreeFiles returns the files committed to the checked-out branch for a change in git-backed mode without a branch,
+// as taken by commitWorkTree: the input file and the journal and journal baseline next to it, written at filePath.
+func workTreeFiles(repoPath string, filePath string) []string {
+	files := []string{repoPath}
+	if _, err := os.Stat(journalPath(filePath)); err == nil {
+		files = append(files, journalPath(repoPath))
+	}
+	if _, err := os.Stat(journalBaselinePath(filePath)); err == nil {
+		files = append(files, journalBaselinePath(repoPath))
+	}
+	return files
+}
+
+// restore puts back the content of the input file from before a change whose commit failed, unless the file was edited
+// since the change was written. The cached snapshot is dropped and the content hash reset, as the change never happened.
+func (ds *dataStore) restore(contentBefore, contentAfter []byte) error {
+	written := hashFileContent(contentAfter)
+	err := atomicWriteFile(ds.filePath, func(w io.Writer) error {
+		_, err := w.Write(contentBefore)
+		return err
+	}, func() error {
+		content, err := os.ReadFile(ds.filePath)
+		if err != nil {
+			return err
+		}
+		if hashFileContent(content) != written {
+			return errFileChanged
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ds.mu.Lock()
+	ds.snapshot = nil
+	ds.contentHash = hashFileContent(contentBefore)
+	ds.mu.Unlock()
+	return nil
+}
+
+// journal appends a change written to sourcePath to the journal of the input file at journalFile, reading the written
+// file back for the records after the change. The journal is kept next to the input file, or next to the copy of the
+// branch's version of it in git-backed mode with a branch.
+func (ds *dataStore) journal(ctx context.Context, change fileChange, journalFile string, sourcePath string, contentBefore, contentAfter []byte, recordsBefore map[string][]journalRecord) error {
+	written, err := LoadFileData(ctx, sourcePath, ds.loadOptions)
+	if err != nil {
+		return err
+	}
+	recordsAfter, err := journalRecords(written)
+	if err != nil {
+		return err
+	}
+	return appendJournalEntry(journalFile, contentBefore, newJournalEntry(change, contentBefore, contentAfter, recordsBefore, recordsAfter))
+}
+
+// WriteFileData writes records to a new YAML, JSON or Excel file, in the format matching the file extension.
+// An existing file is replaced.
+func WriteFileData(filePath string, data *LoadedData) error {
+	format, err := resolveInputFormat(filePath, InputFormatAuto)
+	if err != nil {
+		return err
+	}
+	if !writableFormats[format] {
+		return fmt.Errorf("writing %s files is not supported", format)
+	}
+	return writeFileData(filePath, format, data, nil)
+}
+
+// writeFileData serializes the records in the given format and atomically replaces the file with the result.
+// The optional check runs right before the file is replaced, see atomicWriteFile.
+func writeFileData(filePath string, format InputFormat, data *LoadedData, check func() error) error {
+	switch format {
+	case InputFormatYAML:
+		return atomicWriteFile(filePath, func(w io.Writer) error {
+			encoder := yaml.NewEncoder(w)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(data); err != nil {
+				return err
+			}
+			return encoder.Close()
+		}, check)
+	case InputFormatJSON:
+		return atomicWriteFile(filePath, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(data)
+		}, check)
+	case InputFormatExcel:
+		f, err := buildWorkbook(data)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return atomicWriteFile(filePath, func(w io.Writer) error {
+			_, err := f.WriteTo(w)
+			return err
+		}, check)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// atomicWriteFile writes a file through a temporary file in the same directory, which is then renamed over the original.
+// The original file's permissions are kept. When check is set, it runs once the new content is written and the original
+// is only replaced if it returns nil, which keeps the window for overwriting concurrent edits as small as possible.
+func atomicWriteFile(filePath string, write func(w io.Writer) error, check func() error) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once the rename succeeded
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// buildWorkbook creates an Excel workbook holding the records in the default sheet layout.
+// Profile attributes are written as "Profile: <key>" columns, one per key used by any user.
+func buildWorkbook(data *LoadedData) (*excelize.File, error) {
+	f := excelize.NewFile()
+
+	profileKeys := make(map[string]struct{})
+	for _, u := range data.Users {
+		for key := range u.Profile {
+			profileKeys[key] = struct{}{}
+		}
+	}
+	sortedProfileKeys := make([]string, 0, len(profileKeys))
+	for key := range profileKeys {
+		sortedProfileKeys = append(sortedProfileKeys, key)
+	}
+	sort.Strings(sortedProfileKeys)
+
+	rows := map[string][][]interface{}{}
+	usersHeader := headerRow(sectionUsers)
+	for _, key := range sortedProfileKeys {
+		usersHeader = append(usersHeader, "Profile: "+key)
+	}
+	rows[sectionUsers] = [][]interface{}{usersHeader}
+	for _, u := range data.Users {
+		row := []interface{}{u.Name, u.DisplayName, u.Email, u.Status, u.LastLogin, u.Type}
+		for _, key := range sortedProfileKeys {
+			value := ""
+			if v, ok := u.Profile[key]; ok && v != nil {
+				value = fmt.Sprint(v)
+			}
+			row = append(row, value)
+		}
+		rows[sectionUsers] = append(rows[sectionUsers], row)
+	}
+
+	rows[sectionResources] = [][]interface{}{headerRow(sectionResources)}
+	for _, r := range data.Resources {
+		rows[sectionResources] = append(rows[sectionResources],
+			[]interface{}{r.ResourceType, r.ResourceFunction, r.Name, r.DisplayName, r.Description, r.ParentResource, r.Owner, r.RotatedAt, r.CredentialHash})
+	}
+	rows[sectionEntitlements] = [][]interface{}{headerRow(sectionEntitlements)}
+	for _, e := range data.Entitlements {
+		rows[sectionEntitlements] = append(rows[sectionEntitlements],
+			[]interface{}{e.ResourceName, e.Entitlement, e.DisplayName, e.Description})
+	}
+	rows[sectionGrants] = [][]interface{}{headerRow(sectionGrants)}
+	for _, g := range data.Grants {
+		rows[sectionGrants] = append(rows[sectionGrants], []interface{}{g.Principal, g.EntitlementId})
+	}
+
+	for i, section := range sectionNames {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), section); err != nil {
+				return nil, err
+			}
+		} else if _, err := f.NewSheet(section); err != nil {
+			return nil, err
+		}
+		for r, row := range rows[section] {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := f.SetSheetRow(section, cell, &row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// headerRow returns the header written for a section as a row of cell values.
+func headerRow(section string) []interface{} {
+	headers := sheetHeaders[section]
+	row := make([]interface{}, len(headers))
+	for i, h := range headers {
+		row[i] = h
+	}
+	return row
+}
@@ -0,0 +1,265 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Identity used for commits when the repository has no user.name or user.email configured.
+const (
+	gitFallbackName  = "baton-file"
+	gitFallbackEmail = "baton-file@localhost"
+)
+
+// The GitOptions struct configures git-backed mode, where the input file lives in a git working tree.
+// Each sync records the commit it read, and each change written back becomes its own commit.
+type GitOptions struct {
+	// Branch receives the commits of written-back changes instead of the checked-out branch.
+	// The working tree is left untouched, so changes only reach syncs once the branch is merged.
+	// It is created from HEAD when it does not exist.
+	Branch string
+}
+
+// The gitRepo struct runs git commands against the working tree holding the input file.
+// It shells out to the git executable, so hooks, filters and configuration behave as they do for people working on the repository.
+type gitRepo struct {
+	workTree string // Top-level directory of the working tree
+	path     string // Input file path relative to workTree, slash separated
+	branch   string // Branch receiving commits, or "" to commit to the checked-out branch
+}
+
+// The gitState struct records the commit a version of the input file was read from.
+type gitState struct {
+	commit string // Commit checked out in the working tree, or "" when the repository has no commits yet
+	dirty  bool   // Whether the input file differs from that commit
+}
+
+// openGitRepo locates the git working tree holding the input file.
+// It fails when the file is outside a working tree, or when the branch to commit to is the one checked out.
+func openGitRepo(ctx context.Context, filePath string, opts GitOptions) (*gitRepo, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input file path: %w", err)
+	}
+	absPath, err = filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input file path: %w", err)
+	}
+
+	g := &gitRepo{workTree: filepath.Dir(absPath), branch: opts.Branch}
+	topLevel, err := g.run(ctx, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("input file %s is not in a git working tree: %w", filePath, err)
+	}
+	g.workTree = topLevel
+	relPath, err := filepath.Rel(topLevel, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input file path in %s: %w", topLevel, err)
+	}
+	g.path = filepath.ToSlash(relPath)
+
+	if g.branch != "" {
+		if _, err := g.run(ctx, nil, "check-ref-format", "--branch", g.branch); err != nil {
+			return nil, fmt.Errorf("invalid git branch name %q: %w", g.branch, err)
+		}
+		current, _ := g.run(ctx, nil, "symbolic-ref", "--quiet", "--short", "HEAD")
+		if current == g.branch {
+			return nil, fmt.Errorf("git branch %s is checked out; commit to the checked-out branch by not setting a branch", g.branch)
+		}
+	}
+	return g, nil
+}
+
+// state returns the commit checked out in the working tree and whether the input file has uncommitted changes.
+func (g *gitRepo) state(ctx context.Context) (gitState, error) {
+	commit, _ := g.run(ctx, nil, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	if commit == "" {
+		// A repository without commits has no HEAD yet; its files are all uncommitted
+		return gitState{dirty: true}, nil
+	}
+	status, err := g.run(ctx, nil, "status", "--porcelain", "--", g.path)
+	if err != nil {
+		return gitState{}, err
+	}
+	return gitState{commit: commit, dirty: status != ""}, nil
+}
+
+// baseRef returns the ref changes apply to: the branch to commit to when it exists, or HEAD.
+func (g *gitRepo) baseRef(ctx context.Context) (string, error) {
+	if g.branch != "" {
+		if _, err := g.run(ctx, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+g.branch); err == nil {
+			return "refs/heads/" + g.branch, nil
+		}
+	}
+	if _, err := g.run(ctx, nil, "rev-parse", "--verify", "--quiet", "HEAD^{commit}"); err != nil {
+		return "", fmt.Errorf("the git repository has no commits")
+	}
+	return "HEAD", nil
+}
+
+// readFile copies the committed version of the input file at ref to a new file in dir, keeping its base name
+// so its format is detected the same way, and returns the copy's path.
+func (g *gitRepo) readFile(ctx context.Context, ref string, dir string) (string, error) {
+	copyPath, ok, err := g.copyFile(ctx, ref, g.path, dir)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("failed to read %s at %s: the file does not exist", g.path, ref)
+	}
+	return copyPath, nil
+}
+
+// copyFile copies the committed version of a file at ref, given by its slash-separated path in the repository,
+// to a new file in dir with the same base name. It reports false, without an error, when the file does not exist at ref.
+func (g *gitRepo) copyFile(ctx context.Context, ref string, repoPath string, dir string) (string, bool, error) {
+	if _, err := g.run(ctx, nil, "cat-file", "-e", ref+":"+repoPath); err != nil {
+		return "", false, nil
+	}
+	content, err := g.output(ctx, nil, "show", ref+":"+repoPath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s at %s: %w", repoPath, ref, err)
+	}
+	copyPath := filepath.Join(dir, path.Base(repoPath))
+	if err := os.WriteFile(copyPath, content, 0o600); err != nil {
+		return "", false, err
+	}
+	return copyPath, true, nil
+}
+
+// commitWorkTree commits files as written in the working tree, and nothing else, to the checked-out branch.
+// Files are given by their slash-separated path in the repository, and are added even when git ignores them,
+// as commitToBranch does. When the commit fails, the files are left unstaged.
+func (g *gitRepo) commitWorkTree(ctx context.Context, repoPaths []string, message string) (string, error) {
+	env := g.identityEnv(ctx)
+	if _, err := g.run(ctx, env, append([]string{"add", "--force", "--"}, repoPaths...)...); err != nil {
+		return "", err
+	}
+	if _, err := g.run(ctx, env, append([]string{"commit", "--quiet", "--only", "--message", message, "--"}, repoPaths...)...); err != nil {
+		// The files are unstaged again, so the caller can restore their committed content and leave the working tree clean
+		_, _ = g.run(ctx, nil, append([]string{"reset", "--quiet", "--"}, repoPaths...)...)
+		return "", err
+	}
+	return g.run(ctx, nil, "rev-parse", "HEAD")
+}
+
+// commitToBranch commits new content for files of the repository on the configured branch. Files are given as a map
+// from their slash-separated path in the repository to the path of the file holding their new content.
+// The commit is built with a temporary index, so neither the working tree nor the checked-out branch change.
+// The branch is updated only if it still points at the parent commit, so concurrent commits are never overwritten.
+func (g *gitRepo) commitToBranch(ctx context.Context, files map[string]string, message string) (string, error) {
+	branchRef := "refs/heads/" + g.branch
+	oldValue, err := g.run(ctx, nil, "rev-parse", "--verify", "--quiet", branchRef)
+	if err != nil {
+		oldValue = "" // The branch is created, and must not exist when it is
+	}
+	parent := oldValue
+	if parent == "" {
+		if parent, err = g.run(ctx, nil, "rev-parse", "--verify", "HEAD^{commit}"); err != nil {
+			return "", fmt.Errorf("the git repository has no commits: %w", err)
+		}
+	}
+
+	indexDir, err := os.MkdirTemp("", "baton-file-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(indexDir)
+	indexEnv := append(g.identityEnv(ctx), "GIT_INDEX_FILE="+filepath.Join(indexDir, "index"))
+
+	if _, err := g.run(ctx, indexEnv, "read-tree", parent); err != nil {
+		return "", err
+	}
+	repoPaths := make([]string, 0, len(files))
+	for repoPath := range files {
+		repoPaths = append(repoPaths, repoPath)
+	}
+	sort.Strings(repoPaths)
+	for _, repoPath := range repoPaths {
+		blob, err := g.run(ctx, nil, "hash-object", "-w", "--path", repoPath, "--", files[repoPath])
+		if err != nil {
+			return "", err
+		}
+		mode := "100644"
+		if entry, err := g.run(ctx, nil, "ls-tree", parent, "--", repoPath); err == nil && strings.HasPrefix(entry, "100755 ") {
+			mode = "100755"
+		}
+		if _, err := g.run(ctx, indexEnv, "update-index", "--add", "--cacheinfo", mode+","+blob+","+repoPath); err != nil {
+			return "", err
+		}
+	}
+	tree, err := g.run(ctx, indexEnv, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commit, err := g.run(ctx, indexEnv, "commit-tree", tree, "-p", parent, "-m", message)
+	if err != nil {
+		return "", err
+	}
+	if _, err := g.run(ctx, nil, "update-ref", "-m", "baton-file: "+strings.SplitN(message, "\n", 2)[0], branchRef, commit, oldValue); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// identityEnv returns the environment setting a fallback commit identity when the repository configures none,
+// so commits made by a service account still succeed.
+func (g *gitRepo) identityEnv(ctx context.Context) []string {
+	var env []string
+	if name, _ := g.run(ctx, nil, "config", "user.name"); name == "" && os.Getenv("GIT_AUTHOR_NAME") == "" {
+		env = append(env, "GIT_AUTHOR_NAME="+gitFallbackName, "GIT_COMMITTER_NAME="+gitFallbackName)
+	}
+	if email, _ := g.run(ctx, nil, "config", "user.email"); email == "" && os.Getenv("GIT_AUTHOR_EMAIL") == "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+gitFallbackEmail, "GIT_COMMITTER_EMAIL="+gitFallbackEmail)
+	}
+	return env
+}
+
+// run runs a git command in the working tree and returns its output without the trailing newline.
+func (g *gitRepo) run(ctx context.Context, env []string, args ...string) (string, error) {
+	out, err := g.output(ctx, env, args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// output runs a git command in the working tree and returns its raw output.
+// Extra environment variables are added to the connector's environment.
+func (g *gitRepo) output(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.workTree}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return out, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return out, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// annotations returns the sync annotations recording the git state: a struct with the commit, the input file path
+// in the repository and whether the file had uncommitted changes.
+func (s gitState) annotations(path string) (annotations.Annotations, error) {
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"git_commit": s.commit,
+		"git_path":   path,
+		"git_dirty":  s.dirty,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build git sync annotation: %w", err)
+	}
+	return annotations.New(details), nil
+}
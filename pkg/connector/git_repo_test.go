@@ -0,0 +1,206 @@
+package connector
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/dotc1z"
+	sdkSync "github.com/conductorone/baton-sdk/pkg/sync"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// initTestRepo creates a git repository in a temporary directory holding one committed file and returns the file's path.
+func initTestRepo(t *testing.T, name string, content string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, name)
+	if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gitTest(t, dir, "init", "--quiet", "--initial-branch=main")
+	gitTest(t, dir, "config", "user.name", "Test")
+	gitTest(t, dir, "config", "user.email", "test@example.com")
+	gitTest(t, dir, "add", name)
+	gitTest(t, dir, "commit", "--quiet", "-m", "Add "+name)
+	return filePath
+}
+
+// gitTest runs a git command in dir and returns its output without the trailing newline.
+func gitTest(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+
+func TestCreateAccountOnGitBranch(t *testing.T) {
+	ctx := context.Background()
+	content := "users:\n  - name: alice\n    display_name: Alice\n"
+	filePath := initTestRepo(t, "access.yaml", content)
+
+	fc, err := NewFileConnector(ctx, filePath, WithGit(GitOptions{Branch: "changes"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := fc.store.load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us := newUserSyncer(snapshot.resourceTypes[userResourceTypeId], fc.store, fc.pageSizes)
+
+	// The retry finds the account already on the branch and must return it rather than fail
+	for attempt := 1; attempt <= 2; attempt++ {
+		resp, _, _, err := us.CreateAccount(ctx, &v2.AccountInfo{Login: "bob"}, &v2.CredentialOptions{})
+		if err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+		created := resp.(*v2.CreateAccountResponse_SuccessResult).GetResource()
+		if created.GetId().GetResource() != "bob" {
+			t.Errorf("attempt %d: created resource = %v, want bob", attempt, created.GetId())
+		}
+	}
+
+	if got, err := os.ReadFile(filePath); err != nil || string(got) != content {
+		t.Errorf("working tree file = %q (%v), want it unchanged", got, err)
+	}
+	onBranch := gitTest(t, filepath.Dir(filePath), "show", "changes:access.yaml")
+	if strings.Count(onBranch, "- name: bob") != 1 {
+		t.Errorf("file on branch = %q, want bob added once", onBranch)
+	}
+}
+
+// The testConnectorClient struct is a connector client for the services a sync calls, served over a gRPC connection.
+type testConnectorClient struct {
+	v2.ResourceTypesServiceClient
+	v2.ResourcesServiceClient
+	v2.EntitlementsServiceClient
+	v2.GrantsServiceClient
+	v2.ConnectorServiceClient
+	v2.AssetServiceClient
+	v2.GrantManagerServiceClient
+	v2.ResourceManagerServiceClient
+	v2.ResourceDeleterServiceClient
+	v2.AccountManagerServiceClient
+	v2.CredentialManagerServiceClient
+	v2.EventServiceClient
+	v2.TicketsServiceClient
+	v2.ActionServiceClient
+}
+
+// syncToC1Z runs a full sync of a connector server into a new c1z file and returns the file's path.
+func syncToC1Z(t *testing.T, ctx context.Context, server types.ConnectorServer) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	v2.RegisterConnectorServiceServer(s, server)
+	v2.RegisterResourceTypesServiceServer(s, server)
+	v2.RegisterResourcesServiceServer(s, server)
+	v2.RegisterEntitlementsServiceServer(s, server)
+	v2.RegisterGrantsServiceServer(s, server)
+	v2.RegisterAssetServiceServer(s, server)
+	go func() { _ = s.Serve(listener) }()
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := &testConnectorClient{
+		ResourceTypesServiceClient:     v2.NewResourceTypesServiceClient(conn),
+		ResourcesServiceClient:         v2.NewResourcesServiceClient(conn),
+		EntitlementsServiceClient:      v2.NewEntitlementsServiceClient(conn),
+		GrantsServiceClient:            v2.NewGrantsServiceClient(conn),
+		ConnectorServiceClient:         v2.NewConnectorServiceClient(conn),
+		AssetServiceClient:             v2.NewAssetServiceClient(conn),
+		GrantManagerServiceClient:      v2.NewGrantManagerServiceClient(conn),
+		ResourceManagerServiceClient:   v2.NewResourceManagerServiceClient(conn),
+		ResourceDeleterServiceClient:   v2.NewResourceDeleterServiceClient(conn),
+		AccountManagerServiceClient:    v2.NewAccountManagerServiceClient(conn),
+		CredentialManagerServiceClient: v2.NewCredentialManagerServiceClient(conn),
+		EventServiceClient:             v2.NewEventServiceClient(conn),
+		TicketsServiceClient:           v2.NewTicketsServiceClient(conn),
+		ActionServiceClient:            v2.NewActionServiceClient(conn),
+	}
+
+	c1zPath := filepath.Join(t.TempDir(), "sync.c1z")
+	syncer, err := sdkSync.NewSyncer(ctx, client, sdkSync.WithC1ZPath(c1zPath), sdkSync.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return c1zPath
+}
+
+func TestSyncRecordsGitCommitInC1Z(t *testing.T) {
+	ctx := context.Background()
+	filePath := initTestRepo(t, "access.yaml", `users:
+  - name: alice
+resources:
+  - resource_type: team
+    resource_function: group
+    name: eng
+entitlements:
+  - resource_name: eng
+    entitlement: member
+    members: [alice]
+`)
+	head := gitTest(t, filepath.Dir(filePath), "rev-parse", "HEAD")
+
+	fc, err := NewFileConnector(ctx, filePath, WithGit(GitOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewConnectorServer(ctx, fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1zPath := syncToC1Z(t, ctx, server)
+
+	store, err := dotc1z.NewC1ZFile(ctx, c1zPath, dotc1z.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	resp, err := store.ListResourceTypes(ctx, &v2.ResourceTypesServiceListResourceTypesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetList()) != 2 {
+		t.Fatalf("c1z holds %d resource types, want 2", len(resp.GetList()))
+	}
+	for _, rt := range resp.GetList() {
+		var commit interface{}
+		for _, a := range rt.GetAnnotations() {
+			details := &structpb.Struct{}
+			if a.UnmarshalTo(details) == nil {
+				commit = details.AsMap()["git_commit"]
+			}
+		}
+		if commit != head {
+			t.Errorf("git_commit of resource type %s = %v, want %s", rt.GetId(), commit, head)
+		}
+	}
+}
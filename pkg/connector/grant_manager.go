@@ -0,0 +1,127 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// The Grant method adds a grant of an entitlement of the syncer's type to a principal in the input file.
+// It implements the Grant method, required by the connectorbuilder.ResourceProvisioner interface.
+// The grant names the principal directly, so a group principal receives the entitlement itself rather than its members.
+// The principal and the entitlement must be defined in the file. Granting an entitlement the principal already has succeeds.
+func (fs *fileSyncer) Grant(ctx context.Context, principal *v2.Resource, ent *v2.Entitlement) (annotations.Annotations, error) {
+	entitlementID, err := fs.entitlementKey(ent)
+	if err != nil {
+		return nil, fmt.Errorf("Grant: %w", err)
+	}
+	principalName := principal.GetId().GetResource()
+
+	change := fileChange{
+		operation:   "grant " + entitlementID + " to " + principalName,
+		principal:   principal.GetId().GetResourceType() + ":" + principalName,
+		resource:    fs.resourceType.Id + ":" + ent.GetResource().GetId().GetResource(),
+		entitlement: entitlementID,
+		apply: func(data *LoadedData) error {
+			if !entitlementExists(data, entitlementID) {
+				return fmt.Errorf("entitlement %q is not defined", entitlementID)
+			}
+			if !recordExists(data, principalName) {
+				return fmt.Errorf("principal %q is not defined", principalName)
+			}
+			for _, g := range data.Grants {
+				if g.Principal == principalName && g.EntitlementId == entitlementID {
+					return errNoChange
+				}
+			}
+			data.Grants = append(data.Grants, GrantData{Principal: principalName, EntitlementId: entitlementID})
+			return nil
+		},
+	}
+	if err := fs.store.update(ctx, change); err != nil {
+		return nil, fmt.Errorf("Grant: %w", err)
+	}
+	return nil, nil
+}
+
+// The Revoke method removes a grant of an entitlement of the syncer's type from the input file.
+// It implements the Revoke method, required by the connectorbuilder.ResourceProvisioner interface.
+// Every grant entry resolving to the grant is removed: the one naming the principal and those naming one of the
+// principal's entitlements, which all sync as the same grant. Revoking a missing grant succeeds.
+func (fs *fileSyncer) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	entitlementID, err := fs.entitlementKey(grant.GetEntitlement())
+	if err != nil {
+		return nil, fmt.Errorf("Revoke: %w", err)
+	}
+	principalName := grant.GetPrincipal().GetId().GetResource()
+
+	found := false
+	change := fileChange{
+		operation:   "revoke " + entitlementID + " from " + principalName,
+		principal:   grant.GetPrincipal().GetId().GetResourceType() + ":" + principalName,
+		resource:    fs.resourceType.Id + ":" + grant.GetEntitlement().GetResource().GetId().GetResource(),
+		entitlement: entitlementID,
+		apply: func(data *LoadedData) error {
+			principalKeys := map[string]bool{principalName: true}
+			for _, e := range data.Entitlements {
+				if e.ResourceName == principalName {
+					principalKeys[e.ResourceName+":"+e.Entitlement] = true
+				}
+			}
+			kept := data.Grants[:0]
+			for _, g := range data.Grants {
+				if g.EntitlementId == entitlementID && principalKeys[g.Principal] {
+					found = true
+					continue
+				}
+				kept = append(kept, g)
+			}
+			if !found {
+				return errNoChange
+			}
+			data.Grants = kept
+			return nil
+		},
+	}
+	if err := fs.store.update(ctx, change); err != nil {
+		return nil, fmt.Errorf("Revoke: %w", err)
+	}
+	if !found {
+		l.Info("Grant to revoke was not found in the file", zap.String("entitlement", entitlementID), zap.String("principal", principalName))
+	}
+	return nil, nil
+}
+
+// entitlementKey returns the "<resource name>:<entitlement>" key an entitlement of the syncer's type has in the input
+// file. The slug is taken from the entitlement ID when the request does not carry it.
+func (fs *fileSyncer) entitlementKey(ent *v2.Entitlement) (string, error) {
+	resourceID := ent.GetResource().GetId()
+	if resourceID.GetResourceType() != fs.resourceType.Id {
+		return "", fmt.Errorf("resource type %s does not match syncer type %s", resourceID.GetResourceType(), fs.resourceType.Id)
+	}
+	slug := ent.GetSlug()
+	if slug == "" {
+		slug = strings.TrimPrefix(ent.GetId(), resourceID.GetResourceType()+":"+resourceID.GetResource()+":")
+	}
+	if slug == "" || slug == ent.GetId() {
+		return "", fmt.Errorf("entitlement %q has no slug", ent.GetId())
+	}
+	return resourceID.GetResource() + ":" + slug, nil
+}
+
+// entitlementExists reports whether the entitlement with the given "<resource name>:<entitlement>" key is defined.
+func entitlementExists(data *LoadedData, key string) bool {
+	for _, e := range data.Entitlements {
+		if e.ResourceName+":"+e.Entitlement == key {
+			return true
+		}
+	}
+	return false
+}
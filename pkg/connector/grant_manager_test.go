@@ -0,0 +1,88 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+func TestGrantAndRevokeCommitEntitlement(t *testing.T) {
+	ctx := context.Background()
+	filePath := initTestRepo(t, "access.yaml", `users:
+  - name: alice
+  - name: bob
+resources:
+  - resource_type: team
+    resource_function: group
+    name: eng
+entitlements:
+  - resource_name: eng
+    entitlement: member
+    members: [alice, bob]
+`)
+	dir := filepath.Dir(filePath)
+
+	fc, err := NewFileConnector(ctx, filePath, WithGit(GitOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := fc.store.load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newFileSyncer(snapshot.resourceTypes["team"], fc.store, fc.pageSizes)
+	ent := snapshot.entitlements["eng:member"]
+	alice := snapshot.resources["alice"]
+
+	revoked := &v2.Grant{Entitlement: ent, Principal: snapshot.resources["bob"]}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := fs.Revoke(ctx, revoked); err != nil {
+			t.Fatalf("revoke attempt %d: %v", attempt, err)
+		}
+	}
+	if got := gitTest(t, dir, "log", "-1", "--format=%(trailers:key=Entitlement,valueonly)"); got != "eng:member" {
+		t.Errorf("Entitlement trailer of revoke commit = %q, want %q", got, "eng:member")
+	}
+
+	if _, err := fs.Grant(ctx, alice, ent); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitTest(t, dir, "rev-list", "--count", "HEAD"); got != "2" {
+		t.Errorf("commits = %s, want 2: granting a member again must not write", got)
+	}
+	if _, err := fs.Grant(ctx, snapshot.resources["bob"], ent); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitTest(t, dir, "log", "-1", "--format=%s%n%(trailers)"); got != "baton-file: grant eng:member to bob\nPrincipal: user:bob\nResource: team:eng\nEntitlement: eng:member" {
+		t.Errorf("grant commit message = %q", got)
+	}
+	if _, err := fs.Grant(ctx, alice, &v2.Entitlement{Id: "team:eng:admin", Resource: ent.GetResource()}); err == nil {
+		t.Error("granting an undefined entitlement succeeded")
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `users:
+  - name: alice
+  - name: bob
+resources:
+  - resource_type: team
+    resource_function: group
+    name: eng
+entitlements:
+  - resource_name: eng
+    entitlement: member
+    members: [alice]
+grants:
+  - principal: bob
+    entitlement_id: eng:member
+`
+	if string(content) != want {
+		t.Errorf("got\n%s\nwant\n%s", content, want)
+	}
+}
@@ -0,0 +1,656 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Resource types and entitlement slugs produced for host snapshots.
+const (
+	hostResourceType       = "host"
+	hostGroupResourceType  = "host_group"
+	hostAccountEntitlement = "account"
+	hostMemberEntitlement  = "member"
+)
+
+// Files making up the snapshot of one host.
+const (
+	hostPasswdFile   = "passwd"
+	hostGroupFile    = "group"
+	hostSudoersFile  = "sudoers"
+	hostSudoersDir   = "sudoers.d"
+	hostHostnameFile = "hostname"
+)
+
+// hostSystemUIDLimit is the first UID assigned to regular accounts; accounts below it are treated as service accounts.
+const hostSystemUIDLimit = 1000
+
+// hostNoLoginShells lists login shells that prevent interactive logins; accounts using them are treated as service accounts.
+var hostNoLoginShells = []string{"/sbin/nologin", "/usr/sbin/nologin", "/bin/false", "/usr/bin/false"}
+
+// The hostAccount struct holds one /etc/passwd entry.
+type hostAccount struct {
+	name  string
+	uid   string
+	gid   string
+	gecos string
+	home  string
+	shell string
+}
+
+// The hostGroup struct holds one /etc/group entry.
+type hostGroup struct {
+	name    string
+	gid     string
+	members []string
+}
+
+// The sudoRule struct holds one sudoers user specification after alias expansion of its user and host lists.
+type sudoRule struct {
+	users    []string
+	hosts    []string
+	commands string
+	line     int
+	file     string
+}
+
+// Host Snapshot Loading Logic
+// loadHostSnapshotData handles the specific logic for reading passwd/group/sudoers snapshots of Unix hosts.
+// The input is a directory holding either the files of a single host, or one subdirectory per host.
+// Each host becomes an "host" app resource with an "account" entitlement granted to its local accounts; accounts become users
+// named "<host>/<user>", groups become "host_group" child resources named "<host>/%<group>" with a "member" entitlement,
+// and every distinct sudoers rule becomes an entitlement on the host granted to the users and groups it lists.
+func loadHostSnapshotData(ctx context.Context, inputPath string) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat host snapshot input %s: %w", inputPath, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("host snapshot input %s must be a directory", inputPath)
+	}
+
+	hostDirs, err := hostSnapshotDirs(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+	for _, dir := range hostDirs {
+		files, err := loadHostSnapshot(ctx, dir, loadedData)
+		if err != nil {
+			return nil, err
+		}
+		loadedData.includedFiles = append(loadedData.includedFiles, files...)
+	}
+
+	l.Debug("Loaded host snapshots",
+		zap.String("input", inputPath),
+		zap.Int("hosts", len(hostDirs)),
+		zap.Int("accounts", len(loadedData.Users)),
+	)
+	return loadedData, nil
+}
+
+// hostSnapshotDirs returns the directories holding one host snapshot each.
+// The input directory is a single host when it contains a passwd file; otherwise every subdirectory with a passwd file is a host.
+func hostSnapshotDirs(inputPath string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(inputPath, hostPasswdFile)); err == nil {
+		return []string{inputPath}, nil
+	}
+
+	entries, err := os.ReadDir(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host snapshot directory %s: %w", inputPath, err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(inputPath, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, hostPasswdFile)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no host snapshots found in %s (expected a passwd file in the directory or its subdirectories)", inputPath)
+	}
+	return dirs, nil
+}
+
+// loadHostSnapshot reads the files of one host and appends its resources, entitlements and grants to loadedData.
+// It returns the files that were read.
+func loadHostSnapshot(ctx context.Context, dir string, loadedData *LoadedData) ([]string, error) {
+	l := ctxzap.Extract(ctx)
+
+	host := filepath.Base(dir)
+	files := []string{}
+	if data, err := os.ReadFile(filepath.Join(dir, hostHostnameFile)); err == nil {
+		files = append(files, filepath.Join(dir, hostHostnameFile))
+		if name := strings.TrimSpace(string(data)); name != "" {
+			host = name
+		}
+	}
+
+	accounts, err := readPasswdFile(filepath.Join(dir, hostPasswdFile))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(dir, hostPasswdFile))
+
+	var groups []hostGroup
+	groupPath := filepath.Join(dir, hostGroupFile)
+	if _, err := os.Stat(groupPath); err == nil {
+		groups, err = readGroupFile(groupPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, groupPath)
+	}
+
+	sudoersFiles := hostSudoersFiles(dir)
+	var rules []sudoRule
+	for _, path := range sudoersFiles {
+		fileRules, err := readSudoersFile(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	files = append(files, sudoersFiles...)
+
+	userName := func(account string) string { return host + "/" + account }
+	groupName := func(group string) string { return host + "/%" + group }
+
+	loadedData.Resources = append(loadedData.Resources, ResourceData{
+		ResourceType:     hostResourceType,
+		ResourceFunction: "app",
+		Name:             host,
+		DisplayName:      host,
+		Description:      fmt.Sprintf("Unix host %s", host),
+	})
+	loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+		ResourceName: host,
+		Entitlement:  hostAccountEntitlement,
+		DisplayName:  "Local Account",
+		Description:  fmt.Sprintf("Local account on %s", host),
+	})
+
+	accountsByUID := make(map[string]string)
+	accountNames := make(map[string]struct{})
+	for _, a := range accounts {
+		accountsByUID[a.uid] = a.name
+		accountNames[a.name] = struct{}{}
+
+		displayName, _, _ := strings.Cut(a.gecos, ",")
+		if displayName == "" {
+			displayName = a.name
+		}
+		accountType := "human"
+		if uid, err := strconv.Atoi(a.uid); (err == nil && uid < hostSystemUIDLimit) || isNoLoginShell(a.shell) {
+			accountType = "service"
+		}
+		loadedData.Users = append(loadedData.Users, UserData{
+			Name:        userName(a.name),
+			DisplayName: displayName,
+			Type:        accountType,
+			Profile: map[string]interface{}{
+				"host":     host,
+				"username": a.name,
+				"uid":      a.uid,
+				"gid":      a.gid,
+				"gecos":    a.gecos,
+				"home":     a.home,
+				"shell":    a.shell,
+			},
+		})
+		loadedData.Grants = append(loadedData.Grants, GrantData{Principal: userName(a.name), EntitlementId: host + ":" + hostAccountEntitlement})
+	}
+
+	groupsByGID := make(map[string]string)
+	groupNames := make(map[string]struct{})
+	for _, g := range groups {
+		groupsByGID[g.gid] = g.name
+		groupNames[g.name] = struct{}{}
+
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     hostGroupResourceType,
+			ResourceFunction: "group",
+			Name:             groupName(g.name),
+			DisplayName:      g.name,
+			Description:      fmt.Sprintf("Group %s (gid %s) on %s", g.name, g.gid, host),
+			ParentResource:   host,
+		})
+		loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+			ResourceName: groupName(g.name),
+			Entitlement:  hostMemberEntitlement,
+			DisplayName:  "Member",
+			Description:  fmt.Sprintf("Member of the %s group on %s", g.name, host),
+		})
+	}
+
+	// Group membership comes from the group's member list and from each account's primary group.
+	memberships := make(map[GrantData]struct{})
+	groupMembers := make(map[string][]string)
+	addMembership := func(account string, group string) {
+		grantData := GrantData{Principal: userName(account), EntitlementId: groupName(group) + ":" + hostMemberEntitlement}
+		if _, ok := memberships[grantData]; ok {
+			return
+		}
+		memberships[grantData] = struct{}{}
+		groupMembers[group] = append(groupMembers[group], account)
+		loadedData.Grants = append(loadedData.Grants, grantData)
+	}
+	for _, g := range groups {
+		for _, member := range g.members {
+			addMembership(member, g.name)
+		}
+	}
+	for _, a := range accounts {
+		if group, ok := groupsByGID[a.gid]; ok {
+			addMembership(a.name, group)
+		}
+	}
+
+	// Identical rules are merged into one entitlement so that each distinct privilege appears once per host.
+	ruleGrants := make(map[string]map[string]struct{})
+	for _, rule := range rules {
+		if !sudoRuleAppliesTo(rule, host) {
+			continue
+		}
+		slug := sudoRuleSlug(rule.commands)
+		entitlementId := host + ":" + slug
+		if _, ok := ruleGrants[slug]; !ok {
+			ruleGrants[slug] = make(map[string]struct{})
+			loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+				ResourceName: host,
+				Entitlement:  slug,
+				DisplayName:  "sudo " + rule.commands,
+				Description:  fmt.Sprintf("sudoers rule on %s: %s", host, rule.commands),
+			})
+		}
+
+		// The last item of the user list matching an account decides whether the rule applies to it. A group is granted
+		// as a whole unless one of its members is excluded, in which case its other members are granted one by one.
+		type sudoUserItem struct {
+			group    string
+			accounts []string
+		}
+		var items []sudoUserItem
+		applies := make(map[string]bool)
+		for _, user := range rule.users {
+			name, negated := strings.CutPrefix(user, "!")
+			var item sudoUserItem
+			switch {
+			case name == "ALL":
+				for _, a := range accounts {
+					item.accounts = append(item.accounts, a.name)
+				}
+			case strings.HasPrefix(name, "%#"):
+				item.group = groupsByGID[name[2:]]
+			case strings.HasPrefix(name, "%"):
+				if _, ok := groupNames[name[1:]]; ok {
+					item.group = name[1:]
+				}
+			case strings.HasPrefix(name, "#"):
+				if account, ok := accountsByUID[name[1:]]; ok {
+					item.accounts = []string{account}
+				}
+			default:
+				if _, ok := accountNames[name]; ok {
+					item.accounts = []string{name}
+				}
+			}
+			if item.group != "" {
+				item.accounts = groupMembers[item.group]
+			} else if len(item.accounts) == 0 {
+				l.Warn("Skipping sudoers principal that is not a local account or group",
+					zap.String("host", host), zap.String("principal", user), zap.String("file", rule.file), zap.Int("line", rule.line))
+				continue
+			}
+			for _, account := range item.accounts {
+				applies[account] = !negated
+			}
+			if !negated {
+				items = append(items, item)
+			}
+		}
+
+		addRuleGrant := func(principal string) {
+			if _, ok := ruleGrants[slug][principal]; ok {
+				return
+			}
+			ruleGrants[slug][principal] = struct{}{}
+			loadedData.Grants = append(loadedData.Grants, GrantData{Principal: principal, EntitlementId: entitlementId})
+		}
+		for _, item := range items {
+			whole := item.group != ""
+			for _, account := range item.accounts {
+				whole = whole && applies[account]
+			}
+			if whole {
+				addRuleGrant(groupName(item.group) + ":" + hostMemberEntitlement)
+				continue
+			}
+			for _, account := range item.accounts {
+				if applies[account] {
+					addRuleGrant(userName(account))
+				}
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// readPasswdFile parses an /etc/passwd snapshot.
+func readPasswdFile(path string) ([]hostAccount, error) {
+	var accounts []hostAccount
+	err := readColonFile(path, 7, func(fields []string) {
+		accounts = append(accounts, hostAccount{
+			name:  fields[0],
+			uid:   fields[2],
+			gid:   fields[3],
+			gecos: fields[4],
+			home:  fields[5],
+			shell: fields[6],
+		})
+	})
+	return accounts, err
+}
+
+// readGroupFile parses an /etc/group snapshot.
+func readGroupFile(path string) ([]hostGroup, error) {
+	var groups []hostGroup
+	err := readColonFile(path, 4, func(fields []string) {
+		g := hostGroup{name: fields[0], gid: fields[2]}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				g.members = append(g.members, member)
+			}
+		}
+		groups = append(groups, g)
+	})
+	return groups, err
+}
+
+// readColonFile reads a colon-separated database file such as /etc/passwd, skipping blank lines, comments and NIS "+"/"-" entries.
+func readColonFile(path string, fieldCount int, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < fieldCount {
+			return fmt.Errorf("%s line %d: expected %d colon-separated fields, found %d", path, lineNumber, fieldCount, len(fields))
+		}
+		fn(fields)
+	}
+	return scanner.Err()
+}
+
+// isNoLoginShell reports whether a login shell prevents interactive logins.
+func isNoLoginShell(shell string) bool {
+	for _, s := range hostNoLoginShells {
+		if shell == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hostSudoersFiles returns the sudoers file of a host snapshot followed by the files of its sudoers.d directory.
+// Like sudo, files in sudoers.d whose names end in "~" or contain a "." are ignored.
+func hostSudoersFiles(dir string) []string {
+	var files []string
+	if _, err := os.Stat(filepath.Join(dir, hostSudoersFile)); err == nil {
+		files = append(files, filepath.Join(dir, hostSudoersFile))
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, hostSudoersDir))
+	if err != nil {
+		return files
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, "~") || strings.Contains(name, ".") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, filepath.Join(dir, hostSudoersDir, name))
+	}
+	return files
+}
+
+// readSudoersFile parses the user specifications of a sudoers file.
+// User_Alias and Host_Alias definitions are expanded in the user and host lists; other aliases are kept as written in the command text.
+// Defaults entries and include directives are ignored, since included paths refer to the original host;
+// files from the snapshot's sudoers.d directory are read separately.
+func readSudoersFile(ctx context.Context, path string) ([]sudoRule, error) {
+	l := ctxzap.Extract(ctx)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	userAliases := make(map[string][]string)
+	hostAliases := make(map[string][]string)
+	var rules []sudoRule
+
+	for _, entry := range sudoersLines(string(data)) {
+		line := entry.text
+		keyword := strings.Fields(line)[0]
+		rest := strings.TrimSpace(line[len(keyword):])
+		switch keyword {
+		case "Defaults", "Cmnd_Alias", "Cmd_Alias", "Runas_Alias":
+			continue
+		case "User_Alias", "Host_Alias":
+			aliases := userAliases
+			if keyword == "Host_Alias" {
+				aliases = hostAliases
+			}
+			for _, definition := range strings.Split(rest, ":") {
+				name, members, ok := strings.Cut(definition, "=")
+				if !ok {
+					return nil, fmt.Errorf("%s line %d: invalid %s definition", path, entry.line, keyword)
+				}
+				aliases[strings.TrimSpace(name)] = splitSudoersList(members)
+			}
+			continue
+		}
+		if strings.HasPrefix(keyword, "Defaults") || strings.HasPrefix(keyword, "@include") || strings.HasPrefix(keyword, "#include") {
+			continue
+		}
+
+		// A user specification reads "users hosts = commands"; only the first host specification of a line is used.
+		lhs, commands, ok := strings.Cut(line, "=")
+		if !ok {
+			l.Warn("Skipping unrecognized sudoers line", zap.String("file", path), zap.Int("line", entry.line))
+			continue
+		}
+		userList, hostList, ok := splitSudoersUserSpec(lhs)
+		if !ok {
+			l.Warn("Skipping sudoers rule without a host list", zap.String("file", path), zap.Int("line", entry.line))
+			continue
+		}
+
+		rules = append(rules, sudoRule{
+			users:    expandSudoersAliases(splitSudoersList(userList), userAliases),
+			hosts:    expandSudoersAliases(splitSudoersList(hostList), hostAliases),
+			commands: strings.Join(strings.Fields(commands), " "),
+			line:     entry.line,
+			file:     path,
+		})
+	}
+	return rules, nil
+}
+
+// sudoersLine is one logical sudoers line together with the physical line it starts on.
+type sudoersLine struct {
+	text string
+	line int
+}
+
+// sudoersLines joins backslash-continued lines and strips comments and blank lines.
+// "#include", "#includedir" and "#<uid>" user references are not treated as comments.
+func sudoersLines(data string) []sudoersLine {
+	var lines []sudoersLine
+	var current strings.Builder
+	start := 0
+	for i, raw := range strings.Split(data, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if current.Len() == 0 {
+			start = i + 1
+		}
+		if idx := sudoersCommentIndex(raw); idx >= 0 {
+			raw = raw[:idx]
+		}
+		if strings.HasSuffix(raw, "\\") {
+			current.WriteString(strings.TrimSuffix(raw, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(raw)
+		if text := strings.TrimSpace(current.String()); text != "" {
+			lines = append(lines, sudoersLine{text: text, line: start})
+		}
+		current.Reset()
+	}
+	if text := strings.TrimSpace(current.String()); text != "" {
+		lines = append(lines, sudoersLine{text: text, line: start})
+	}
+	return lines
+}
+
+// sudoersCommentIndex returns the index where a comment starts in a sudoers line, or -1.
+func sudoersCommentIndex(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '#' {
+			continue
+		}
+		rest := line[i+1:]
+		if strings.HasPrefix(rest, "include") {
+			continue
+		}
+		if len(rest) > 0 && rest[0] >= '0' && rest[0] <= '9' {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// splitSudoersList splits a comma-separated sudoers list, trimming each item.
+func splitSudoersList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitSudoersUserSpec splits the part of a user specification left of "=" into its user list and host list.
+// Lists may have spaces around their commas ("alice, bob host1, host2"), so the lists are separated by the first run
+// of whitespace that neither follows nor precedes a comma. It reports false when there is no host list.
+func splitSudoersUserSpec(lhs string) (string, string, bool) {
+	lhs = strings.TrimSpace(lhs)
+	for i := 0; i < len(lhs); i++ {
+		if !unicode.IsSpace(rune(lhs[i])) {
+			continue
+		}
+		end := i
+		for end < len(lhs) && unicode.IsSpace(rune(lhs[end])) {
+			end++
+		}
+		if lhs[i-1] != ',' && lhs[end] != ',' {
+			return lhs[:i], lhs[end:], true
+		}
+		i = end - 1
+	}
+	return "", "", false
+}
+
+// expandSudoersAliases replaces alias names in a list with their members, recursively.
+// Negated items ("!name") are kept; the members of a negated alias are negated in turn, so "!ADMINS" excludes each admin.
+func expandSudoersAliases(items []string, aliases map[string][]string) []string {
+	var expanded []string
+	expanding := make(map[string]struct{})
+	var expand func(items []string, negated bool)
+	expand = func(items []string, negated bool) {
+		for _, item := range items {
+			itemNegated := negated
+			for strings.HasPrefix(item, "!") {
+				item = strings.TrimSpace(item[1:])
+				itemNegated = !itemNegated
+			}
+			if members, ok := aliases[item]; ok {
+				if _, cycle := expanding[item]; cycle {
+					continue
+				}
+				expanding[item] = struct{}{}
+				expand(members, itemNegated)
+				delete(expanding, item)
+				continue
+			}
+			if itemNegated {
+				item = "!" + item
+			}
+			expanded = append(expanded, item)
+		}
+	}
+	expand(items, false)
+	return expanded
+}
+
+// sudoRuleAppliesTo reports whether a rule's host list matches the host (ALL, the host name or its short name).
+// As in sudoers, the last matching item decides, so "ALL, !db1" excludes db1.
+func sudoRuleAppliesTo(rule sudoRule, host string) bool {
+	shortName, _, _ := strings.Cut(host, ".")
+	applies := false
+	for _, h := range rule.hosts {
+		name, negated := strings.CutPrefix(h, "!")
+		if name == "ALL" || strings.EqualFold(name, host) || strings.EqualFold(name, shortName) {
+			applies = !negated
+		}
+	}
+	return applies
+}
+
+// sudoRuleSlug derives a stable entitlement slug from the command part of a sudoers rule.
+func sudoRuleSlug(commands string) string {
+	sum := sha256.Sum256([]byte(commands))
+	return "sudo-" + hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSudoersFileUserAndHostLists(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		users []string
+		hosts []string
+	}{
+		{
+			name:  "single user and host",
+			line:  "alice host1 = ALL",
+			users: []string{"alice"},
+			hosts: []string{"host1"},
+		},
+		{
+			name:  "lists without spaces",
+			line:  "alice,bob host1,host2 = ALL",
+			users: []string{"alice", "bob"},
+			hosts: []string{"host1", "host2"},
+		},
+		{
+			name:  "host list with comma and space",
+			line:  "alice host1, host2 = ALL",
+			users: []string{"alice"},
+			hosts: []string{"host1", "host2"},
+		},
+		{
+			name:  "both lists with comma and space",
+			line:  "alice, bob  host1, host2=(root) ALL",
+			users: []string{"alice", "bob"},
+			hosts: []string{"host1", "host2"},
+		},
+		{
+			name:  "space before comma",
+			line:  "alice ,bob host1 , host2 = ALL",
+			users: []string{"alice", "bob"},
+			hosts: []string{"host1", "host2"},
+		},
+		{
+			name:  "aliases",
+			line:  "ADMINS, carol SERVERS = ALL",
+			users: []string{"alice", "bob", "carol"},
+			hosts: []string{"host1", "host2"},
+		},
+		{
+			name:  "negations",
+			line:  "ADMINS, !bob ALL, !SERVERS = ALL",
+			users: []string{"alice", "bob", "!bob"},
+			hosts: []string{"ALL", "!host1", "!host2"},
+		},
+		{
+			name:  "negated alias with a negated member",
+			line:  "ALL, !OPERATORS host1 = ALL",
+			users: []string{"ALL", "!carol", "dave"},
+			hosts: []string{"host1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sudoers")
+			content := "User_Alias ADMINS = alice, bob\nUser_Alias OPERATORS = carol, !dave\nHost_Alias SERVERS = host1, host2\n" + tt.line + "\n"
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			rules, err := readSudoersFile(context.Background(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != 1 {
+				t.Fatalf("got %d rules, want 1", len(rules))
+			}
+			if !reflect.DeepEqual(rules[0].users, tt.users) {
+				t.Errorf("users = %q, want %q", rules[0].users, tt.users)
+			}
+			if !reflect.DeepEqual(rules[0].hosts, tt.hosts) {
+				t.Errorf("hosts = %q, want %q", rules[0].hosts, tt.hosts)
+			}
+		})
+	}
+}
+
+func TestReadSudoersFileSkipsRuleWithoutHostList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sudoers")
+	if err := os.WriteFile(path, []byte("alice, bob = ALL\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := readSudoersFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("got %d rules, want 0", len(rules))
+	}
+}
+
+func TestLoadHostSnapshotSudoersNegations(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db1")
+	files := map[string]string{
+		hostPasswdFile: "alice:x:1001:1001:Alice:/home/alice:/bin/bash\n" +
+			"bob:x:1002:1002:Bob:/home/bob:/bin/bash\n" +
+			"carol:x:1003:1003:Carol:/home/carol:/bin/bash\n",
+		hostGroupFile: "admins:x:2000:alice,bob\nops:x:2001:carol\n",
+		hostSudoersFile: "%admins, !bob ALL = /usr/bin/systemctl\n" +
+			"%ops, !bob ALL = /usr/bin/journalctl\n" +
+			"ALL, !%admins ALL = /usr/bin/uptime\n" +
+			"%ops ALL, !db1 = ALL\n" +
+			"!alice, alice db1 = /usr/bin/top\n",
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := loadHostSnapshotData(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := make(map[string]string)
+	for _, e := range data.Entitlements {
+		if strings.HasPrefix(e.Entitlement, "sudo-") {
+			commands[e.ResourceName+":"+e.Entitlement] = strings.TrimPrefix(e.DisplayName, "sudo ")
+		}
+	}
+	got := make(map[string][]string)
+	for _, g := range data.Grants {
+		if command, ok := commands[g.EntitlementId]; ok {
+			got[command] = append(got[command], g.Principal)
+		}
+	}
+	want := map[string][]string{
+		"/usr/bin/systemctl":  {"db1/alice"},
+		"/usr/bin/journalctl": {"db1/%ops:member"},
+		"/usr/bin/uptime":     {"db1/carol"},
+		"/usr/bin/top":        {"db1/alice"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sudo grants = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package connector
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The InputFormat type names the parser used for the input file.
+// The empty value selects the parser from the file extension.
+type InputFormat string
+
+// Supported input formats.
+const (
+	InputFormatAuto   InputFormat = ""
+	InputFormatExcel  InputFormat = "xlsx"
+	InputFormatODS    InputFormat = "ods"
+	InputFormatXLS    InputFormat = "xls"
+	InputFormatYAML   InputFormat = "yaml"
+	InputFormatJSON   InputFormat = "json"
+	InputFormatNDJSON InputFormat = "ndjson"
+	InputFormatLDIF   InputFormat = "ldif"
+	InputFormatSCIM   InputFormat = "scim"
+	InputFormatHost   InputFormat = "host-snapshot"
+	InputFormatK8s    InputFormat = "kubernetes-rbac"
+	InputFormatAWSIAM InputFormat = "aws-iam"
+	InputFormatSQLite InputFormat = "sqlite"
+)
+
+// inputFormatsByExtension maps lowercase file extensions to the format detected for them.
+var inputFormatsByExtension = map[string]InputFormat{
+	".xlsx":    InputFormatExcel,
+	".ods":     InputFormatODS,
+	".xls":     InputFormatXLS,
+	".yaml":    InputFormatYAML,
+	".yml":     InputFormatYAML,
+	".json":    InputFormatJSON,
+	".ndjson":  InputFormatNDJSON,
+	".jsonl":   InputFormatNDJSON,
+	".ldif":    InputFormatLDIF,
+	".db":      InputFormatSQLite,
+	".sqlite":  InputFormatSQLite,
+	".sqlite3": InputFormatSQLite,
+}
+
+// inputFormats lists every format that can be selected explicitly.
+var inputFormats = []InputFormat{
+	InputFormatExcel,
+	InputFormatODS,
+	InputFormatXLS,
+	InputFormatYAML,
+	InputFormatJSON,
+	InputFormatNDJSON,
+	InputFormatLDIF,
+	InputFormatSCIM,
+	InputFormatHost,
+	InputFormatK8s,
+	InputFormatAWSIAM,
+	InputFormatSQLite,
+}
+
+// ParseInputFormat validates a format name given on the command line or in the mapping file.
+// "auto" and the empty string both select the format from the file extension.
+func ParseInputFormat(name string) (InputFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		return InputFormatAuto, nil
+	}
+	for _, f := range inputFormats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	names := make([]string, 0, len(inputFormats))
+	for _, f := range inputFormats {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown input format %q (expected auto or one of %s)", name, strings.Join(names, ", "))
+}
+
+// resolveInputFormat returns the explicitly configured format, or the one matching the file extension.
+func resolveInputFormat(filePath string, format InputFormat) (InputFormat, error) {
+	if format != InputFormatAuto {
+		return format, nil
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if f, ok := inputFormatsByExtension[ext]; ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported file type: '%s' for file: %s", ext, filePath)
+}
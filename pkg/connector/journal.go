@@ -0,0 +1,468 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The journalEntry struct is one line of the change journal kept next to the input file.
+// It records a change written back to the file: the records it created, modified or deleted,
+// and the hashes of the file before and after, which chain the entries to each other and to the baseline.
+type journalEntry struct {
+	Timestamp   string                `json:"timestamp"` // RFC 3339 time the change was written, in UTC
+	RequestID   string                `json:"request_id"`
+	Operation   string                `json:"operation"`
+	Principal   string                `json:"principal,omitempty"`
+	Resource    string                `json:"resource,omitempty"`
+	Entitlement string                `json:"entitlement,omitempty"`
+	Ticket      string                `json:"ticket,omitempty"`
+	HashBefore  string                `json:"hash_before"` // Hash of the file content the change was applied to, see hashFileContent
+	HashAfter   string                `json:"hash_after"`  // Hash of the file content written
+	Changes     []journalRecordChange `json:"changes"`
+}
+
+// The journalRecordChange struct records one record changed by a journal entry.
+// Before is null for created records and After is null for deleted ones.
+type journalRecordChange struct {
+	Section string          `json:"section"`
+	Key     string          `json:"key"` // Identity of the record within its section, see journalRecords
+	Before  json.RawMessage `json:"before"`
+	After   json.RawMessage `json:"after"`
+}
+
+// journalRecord is a record of the input file in its journaled form.
+type journalRecord struct {
+	key string
+	raw json.RawMessage
+}
+
+// journalPath returns the path of the journal of an input file.
+func journalPath(filePath string) string {
+	return filePath + ".journal.jsonl"
+}
+
+// journalBaselinePath returns the path of the copy of an input file taken before its first journaled change.
+// It keeps the input's extension, so it is read in the same format.
+func journalBaselinePath(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".journal-baseline" + ext
+}
+
+// hashFileContent returns the hash identifying a version of a file in the journal, formatted as "sha256:<hex>".
+func hashFileContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newRequestID returns a random ID for a change that was not given one.
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// journalRecords returns the records of each section in their journaled form, keyed by journalRecordKey.
+func journalRecords(data *LoadedData) (map[string][]journalRecord, error) {
+	sections := make(map[string][]journalRecord, len(sectionNames))
+	var err error
+	if sections[sectionUsers], err = encodeJournalRecords(data.Users); err != nil {
+		return nil, err
+	}
+	if sections[sectionResources], err = encodeJournalRecords(data.Resources); err != nil {
+		return nil, err
+	}
+	if sections[sectionEntitlements], err = encodeJournalRecords(data.Entitlements); err != nil {
+		return nil, err
+	}
+	if sections[sectionGrants], err = encodeJournalRecords(data.Grants); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// journalRecordKey returns the identity of a record within its section: its name for users and resources,
+// "<resource name>:<entitlement>" for entitlements and "<principal> -> <entitlement ID>" for grants.
+func journalRecordKey(record interface{}) string {
+	switch r := record.(type) {
+	case UserData:
+		return r.Name
+	case ResourceData:
+		return r.Name
+	case EntitlementData:
+		return r.ResourceName + ":" + r.Entitlement
+	case GrantData:
+		return r.Principal + " -> " + r.EntitlementId
+	default:
+		return ""
+	}
+}
+
+// The journalKeyCounter type numbers repeated record keys of a section, so every record has a distinct key:
+// repeated keys get a "#<n>" suffix from their second occurrence.
+type journalKeyCounter map[string]int
+
+// next returns the distinct key of the next record with the given key.
+func (c journalKeyCounter) next(key string) string {
+	c[key]++
+	if n := c[key]; n > 1 {
+		return fmt.Sprintf("%s#%d", key, n)
+	}
+	return key
+}
+
+// encodeJournalRecords encodes the records of one section.
+func encodeJournalRecords[T any](items []T) ([]journalRecord, error) {
+	keys := make(journalKeyCounter, len(items))
+	records := make([]journalRecord, 0, len(items))
+	for _, item := range items {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, journalRecord{key: keys.next(journalRecordKey(item)), raw: raw})
+	}
+	return records, nil
+}
+
+// decodeJournalRecords decodes the records of one section.
+func decodeJournalRecords[T any](records []journalRecord) ([]T, error) {
+	items := make([]T, 0, len(records))
+	for _, r := range records {
+		var item T
+		if err := json.Unmarshal(r.raw, &item); err != nil {
+			return nil, fmt.Errorf("invalid record %q: %w", r.key, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// setJournalRecords replaces the records of the data with the journaled ones.
+func setJournalRecords(data *LoadedData, sections map[string][]journalRecord) error {
+	var err error
+	if data.Users, err = decodeJournalRecords[UserData](sections[sectionUsers]); err != nil {
+		return err
+	}
+	if data.Resources, err = decodeJournalRecords[ResourceData](sections[sectionResources]); err != nil {
+		return err
+	}
+	if data.Entitlements, err = decodeJournalRecords[EntitlementData](sections[sectionEntitlements]); err != nil {
+		return err
+	}
+	if data.Grants, err = decodeJournalRecords[GrantData](sections[sectionGrants]); err != nil {
+		return err
+	}
+	return nil
+}
+
+// diffJournalRecords returns the record changes between two versions of the records:
+// modified and deleted records in their original order, then created records in their new order.
+func diffJournalRecords(before, after map[string][]journalRecord) []journalRecordChange {
+	var changes []journalRecordChange
+	for _, section := range sectionNames {
+		afterByKey := make(map[string]json.RawMessage, len(after[section]))
+		for _, r := range after[section] {
+			afterByKey[r.key] = r.raw
+		}
+		beforeKeys := make(map[string]bool, len(before[section]))
+		for _, r := range before[section] {
+			beforeKeys[r.key] = true
+			newRaw, ok := afterByKey[r.key]
+			switch {
+			case !ok:
+				changes = append(changes, journalRecordChange{Section: section, Key: r.key, Before: r.raw})
+			case !bytes.Equal(newRaw, r.raw):
+				changes = append(changes, journalRecordChange{Section: section, Key: r.key, Before: r.raw, After: newRaw})
+			}
+		}
+		for _, r := range after[section] {
+			if !beforeKeys[r.key] {
+				changes = append(changes, journalRecordChange{Section: section, Key: r.key, After: r.raw})
+			}
+		}
+	}
+	return changes
+}
+
+// applyJournalChanges applies the record changes of a journal entry to the records, the way diffJournalRecords computed them.
+// Every modified or deleted record must match the entry's before value, so an entry only applies to the version it was recorded on.
+func applyJournalChanges(sections map[string][]journalRecord, changes []journalRecordChange) error {
+	for _, c := range changes {
+		records, ok := sections[c.Section]
+		if !ok {
+			return fmt.Errorf("unknown section %q", c.Section)
+		}
+		if isJSONNull(c.Before) {
+			records = append(records, journalRecord{key: c.Key, raw: c.After})
+			sections[c.Section] = records
+			continue
+		}
+
+		index := -1
+		for i, r := range records {
+			if r.key == c.Key {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("%s record %q not found", c.Section, c.Key)
+		}
+		if !jsonEqual(records[index].raw, c.Before) {
+			return fmt.Errorf("%s record %q does not match its journaled previous value", c.Section, c.Key)
+		}
+		if isJSONNull(c.After) {
+			sections[c.Section] = append(records[:index], records[index+1:]...)
+		} else {
+			records[index].raw = c.After
+		}
+	}
+	return nil
+}
+
+// isJSONNull reports whether a journaled record value is absent.
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(bytes.TrimSpace(raw)) == "null"
+}
+
+// jsonEqual reports whether two JSON documents hold the same value, whatever their formatting.
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, errA := json.Marshal(va)
+	cb, errB := json.Marshal(vb)
+	return errA == nil && errB == nil && bytes.Equal(ca, cb)
+}
+
+// appendJournalEntry appends an entry to the journal of an input file and syncs it to disk.
+// The content the first journaled change applies to is saved as the journal's baseline.
+func appendJournalEntry(filePath string, contentBefore []byte, entry journalEntry) error {
+	path := journalPath(filePath)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		baselinePath := journalBaselinePath(filePath)
+		if _, err := os.Stat(baselinePath); errors.Is(err, fs.ErrNotExist) {
+			err := atomicWriteFile(baselinePath, func(w io.Writer) error {
+				_, err := w.Write(contentBefore)
+				return err
+			}, nil)
+			if err != nil {
+				return fmt.Errorf("failed to save journal baseline: %w", err)
+			}
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// The journalState struct records the size of the journal of an input file and whether its baseline exists,
+// so a journaled change can be taken back, see restore.
+type journalState struct {
+	size     int64 // Size of the journal, or -1 when it does not exist
+	baseline bool
+}
+
+// readJournalState returns the current state of the journal of an input file.
+func readJournalState(filePath string) (journalState, error) {
+	state := journalState{size: -1}
+	if info, err := os.Stat(journalPath(filePath)); err == nil {
+		state.size = info.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return state, err
+	}
+	if _, err := os.Stat(journalBaselinePath(filePath)); err == nil {
+		state.baseline = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return state, err
+	}
+	return state, nil
+}
+
+// restore takes back the journal entries appended since the state was read, and the baseline saved meanwhile.
+func (s journalState) restore(filePath string) error {
+	path := journalPath(filePath)
+	if s.size < 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	} else if err := os.Truncate(path, s.size); err != nil {
+		return err
+	}
+	if !s.baseline {
+		if err := os.Remove(journalBaselinePath(filePath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJournal returns the entries of the journal of an input file, oldest first.
+func readJournal(filePath string) ([]journalEntry, error) {
+	f, err := os.Open(journalPath(filePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("invalid journal entry on line %d: %w", lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// The JournalReplay struct holds the result of replaying the journal of an input file over its baseline.
+type JournalReplay struct {
+	Data         *LoadedData // Records of the baseline with every journaled change applied
+	Entries      int         // Number of journal entries replayed
+	BaselineHash string      // Hash of the baseline file
+	FinalHash    string      // Hash of the file content written by the last entry, or the baseline hash without entries
+}
+
+// ReplayJournal applies the changes recorded in the journal of an input file to the baseline saved before the first one.
+// It fails when the hashes do not chain, i.e. when the file was changed outside the connector between two journaled changes,
+// or when an entry does not apply to the records it was recorded on.
+func ReplayJournal(ctx context.Context, filePath string, opts *LoadOptions) (*JournalReplay, error) {
+	entries, err := readJournal(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	baselinePath := journalBaselinePath(filePath)
+	baseline, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal baseline: %w", err)
+	}
+	data, err := LoadFileData(ctx, baselinePath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load journal baseline %s: %w", baselinePath, err)
+	}
+	sections, err := journalRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &JournalReplay{Entries: len(entries), BaselineHash: hashFileContent(baseline)}
+	replay.FinalHash = replay.BaselineHash
+	for i, entry := range entries {
+		if entry.HashBefore != replay.FinalHash {
+			if i == 0 {
+				return nil, fmt.Errorf("journal entry 1 (%s) was not recorded on the baseline", entry.Operation)
+			}
+			return nil, fmt.Errorf("journal entry %d (%s) was not recorded on the file written by entry %d: the file was changed outside the connector",
+				i+1, entry.Operation, i)
+		}
+		if err := applyJournalChanges(sections, entry.Changes); err != nil {
+			return nil, fmt.Errorf("journal entry %d (%s) does not apply: %w", i+1, entry.Operation, err)
+		}
+		// Deleting a record renumbers the repeated keys following it, as they are when the next change is recorded
+		if err := setJournalRecords(data, sections); err != nil {
+			return nil, fmt.Errorf("journal entry %d (%s) does not apply: %w", i+1, entry.Operation, err)
+		}
+		if sections, err = journalRecords(data); err != nil {
+			return nil, err
+		}
+		replay.FinalHash = entry.HashAfter
+	}
+
+	replay.Data = data
+	return replay, nil
+}
+
+// VerifyJournal checks that the input file equals its journal baseline with every journaled change applied:
+// the journal must replay cleanly, the file must be the one written by the last entry, and its records must match the replayed ones.
+// It returns the number of verified entries.
+func VerifyJournal(ctx context.Context, filePath string, opts *LoadOptions) (int, error) {
+	replay, err := ReplayJournal(ctx, filePath, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	if hash := hashFileContent(content); hash != replay.FinalHash {
+		return 0, fmt.Errorf("%s (%s) is not the file written by the last journal entry (%s): it was changed outside the connector", filePath, hash, replay.FinalHash)
+	}
+
+	current, err := LoadFileData(ctx, filePath, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load %s: %w", filePath, err)
+	}
+	currentRecords, err := journalRecords(current)
+	if err != nil {
+		return 0, err
+	}
+	replayedRecords, err := journalRecords(replay.Data)
+	if err != nil {
+		return 0, err
+	}
+	if changes := diffJournalRecords(replayedRecords, currentRecords); len(changes) > 0 {
+		c := changes[0]
+		return 0, fmt.Errorf("records of %s differ from the replayed journal: %d difference(s), starting with %s record %q", filePath, len(changes), c.Section, c.Key)
+	}
+	return replay.Entries, nil
+}
+
+// newJournalEntry builds the journal entry of a change from the file content and records before and after it.
+// The records must be read back from the file, not taken from the data that was written: writing normalizes some values,
+// and each entry must start from the records the previous one ended with.
+func newJournalEntry(change fileChange, contentBefore, contentAfter []byte, recordsBefore, recordsAfter map[string][]journalRecord) journalEntry {
+	return journalEntry{
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		RequestID:   change.requestID,
+		Operation:   change.operation,
+		Principal:   change.principal,
+		Resource:    change.resource,
+		Entitlement: change.entitlement,
+		Ticket:      change.ticket,
+		HashBefore:  hashFileContent(contentBefore),
+		HashAfter:   hashFileContent(contentAfter),
+		Changes:     diffJournalRecords(recordsBefore, recordsAfter),
+	}
+}
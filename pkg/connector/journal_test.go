@@ -0,0 +1,151 @@
+package connector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// journalTestModel defines the eng:member entitlement twice, so its journal keys are "eng:member" and "eng:member#2".
+const journalTestModel = `users:
+  - name: alice
+  - name: bob
+resources:
+  - resource_type: team
+    resource_function: group
+    name: eng
+entitlements:
+  - resource_name: eng
+    entitlement: member
+    description: First
+  - resource_name: eng
+    entitlement: member
+    description: Second
+grants:
+  - principal: alice
+    entitlement_id: eng:member
+`
+
+// updateTestFile applies a change to the input file of a data store, failing the test on error.
+func updateTestFile(t *testing.T, ds *dataStore, operation string, apply func(data *LoadedData)) {
+	t.Helper()
+	err := ds.update(context.Background(), fileChange{operation: operation, apply: func(data *LoadedData) error {
+		apply(data)
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayAndVerifyJournal(t *testing.T) {
+	ctx := context.Background()
+	filePath := writeTestFile(t, "access.yaml", journalTestModel)
+	ds := newDataStore(filePath)
+
+	// Deleting the first definition renumbers the second one to "eng:member", which the next changes then refer to
+	updateTestFile(t, ds, "delete first definition", func(data *LoadedData) {
+		data.Entitlements = data.Entitlements[1:]
+	})
+	updateTestFile(t, ds, "describe remaining definition", func(data *LoadedData) {
+		data.Entitlements[0].Description = "Only"
+	})
+	updateTestFile(t, ds, "define again and grant", func(data *LoadedData) {
+		data.Entitlements = append(data.Entitlements, EntitlementData{ResourceName: "eng", Entitlement: "member", Description: "Again"})
+		data.Grants = append(data.Grants, GrantData{Principal: "bob", EntitlementId: "eng:member"})
+	})
+
+	entries, err := readJournal(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, entry := range entries {
+		for _, c := range entry.Changes {
+			keys = append(keys, c.Key)
+		}
+	}
+	wantKeys := []string{"eng:member", "eng:member#2", "eng:member", "eng:member#2", "bob -> eng:member"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("journaled keys = %q, want %q", keys, wantKeys)
+	}
+
+	replay, err := ReplayJournal(ctx, filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := LoadFileData(ctx, filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replay.Data.Entitlements, current.Entitlements) || !reflect.DeepEqual(replay.Data.Grants, current.Grants) {
+		t.Errorf("replayed entitlements %+v and grants %+v, want %+v and %+v", replay.Data.Entitlements, replay.Data.Grants, current.Entitlements, current.Grants)
+	}
+	if n, err := VerifyJournal(ctx, filePath, nil); err != nil || n != 3 {
+		t.Errorf("VerifyJournal() = %d, %v, want 3 entries", n, err)
+	}
+
+	// An edit made outside the connector breaks verification, and the next change breaks the chain for replays
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(strings.Replace(string(content), "description: Only", "description: Edited", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyJournal(ctx, filePath, nil); err == nil || !strings.Contains(err.Error(), "changed outside the connector") {
+		t.Errorf("VerifyJournal() after an outside edit = %v, want an error", err)
+	}
+	updateTestFile(t, ds, "grant after outside edit", func(data *LoadedData) {
+		data.Grants = data.Grants[:1]
+	})
+	if _, err := ReplayJournal(ctx, filePath, nil); err == nil || !strings.Contains(err.Error(), "journal entry 4") {
+		t.Errorf("ReplayJournal() after an outside edit = %v, want an error on entry 4", err)
+	}
+}
+
+func TestJournalCommittedWithChange(t *testing.T) {
+	ctx := context.Background()
+	filePath := initTestRepo(t, "access.yaml", journalTestModel)
+	dir := filepath.Dir(filePath)
+
+	fc, err := NewFileConnector(ctx, filePath, WithGit(GitOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateTestFile(t, fc.store, "grant bob", func(data *LoadedData) {
+		data.Grants = append(data.Grants, GrantData{Principal: "bob", EntitlementId: "eng:member"})
+	})
+
+	if got := gitTest(t, dir, "show", "--name-only", "--format=", "HEAD"); got != "access.journal-baseline.yaml\naccess.yaml\naccess.yaml.journal.jsonl" {
+		t.Errorf("files of the change's commit = %q, want the file, its journal and baseline", got)
+	}
+	if status := gitTest(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree status = %q, want it clean", status)
+	}
+	if n, err := VerifyJournal(ctx, filePath, nil); err != nil || n != 1 {
+		t.Errorf("VerifyJournal() = %d, %v, want 1 entry", n, err)
+	}
+
+	// A change whose commit is rejected is neither kept nor journaled
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	err = fc.store.update(ctx, fileChange{operation: "revoke alice", apply: func(data *LoadedData) error {
+		data.Grants = data.Grants[1:]
+		return nil
+	}})
+	if err == nil {
+		t.Fatal("update succeeded although the commit was rejected")
+	}
+	if status := gitTest(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree status after a rejected commit = %q, want it clean", status)
+	}
+	if n, err := VerifyJournal(ctx, filePath, nil); err != nil || n != 1 {
+		t.Errorf("VerifyJournal() after a rejected commit = %d, %v, want 1 entry", n, err)
+	}
+}
@@ -0,0 +1,183 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// editJSONFile applies record changes, as computed by diffJournalRecords, to the JSON file at filePath in place.
+// The file is parsed as YAML, of which JSON is a subset, and edited like a YAML file, see editYamlFile, so key order,
+// number formatting and keys the connector does not know survive. It is written back with the file's indentation.
+// The check runs before the file is replaced, see atomicWriteFile.
+func editJSONFile(filePath string, opts *LoadOptions, changes []journalRecordChange, check func() error) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(unescapeJSONSolidus(content), &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	e := newYamlEditor(opts)
+	e.jsonFile = true
+	e.docs = []*yaml.Node{&doc}
+	// The loader decodes objects into maps, so it reads their keys sorted
+	sortJSONKeys(copyYamlNode(&doc, e.resolved))
+	if err := e.applyChanges(changes); err != nil {
+		return err
+	}
+
+	var compact, out bytes.Buffer
+	if err := writeJSONNode(&compact, &doc); err != nil {
+		return err
+	}
+	if indent, multiline := jsonIndent(content); multiline {
+		if err := json.Indent(&out, compact.Bytes(), "", indent); err != nil {
+			return err
+		}
+	} else {
+		out.Write(compact.Bytes())
+	}
+	if bytes.HasSuffix(content, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return atomicWriteFile(filePath, func(w io.Writer) error {
+		_, err := w.Write(out.Bytes())
+		return err
+	}, check)
+}
+
+// unescapeJSONSolidus replaces the "\/" escapes of JSON strings, which YAML does not accept, with "/".
+func unescapeJSONSolidus(content []byte) []byte {
+	if !bytes.Contains(content, []byte(`\/`)) {
+		return content
+	}
+	out := make([]byte, 0, len(content))
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\\' && i+1 < len(content) {
+			i++
+			if content[i] != '/' {
+				out = append(out, '\\')
+			}
+		}
+		out = append(out, content[i])
+	}
+	return out
+}
+
+// sortJSONKeys sorts the keys of every mapping in a node tree in the order yaml.v3 encodes the keys of a map.
+func sortJSONKeys(n *yaml.Node) {
+	for _, c := range n.Content {
+		sortJSONKeys(c)
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	keys := make(map[string]bool, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys[n.Content[i].Value] = true
+	}
+	var sorted yaml.Node
+	if err := sorted.Encode(keys); err != nil {
+		return
+	}
+	position := make(map[string]int, len(keys))
+	for i := 0; i+1 < len(sorted.Content); i += 2 {
+		position[sorted.Content[i].Value] = i
+	}
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return position[pairs[i][0].Value] < position[pairs[j][0].Value] })
+	for i, pair := range pairs {
+		n.Content[2*i], n.Content[2*i+1] = pair[0], pair[1]
+	}
+}
+
+// writeJSONNode writes a node tree as compact JSON, keeping the order of mapping keys.
+// Unquoted scalars holding a JSON number, boolean or null are written as they are, and other scalars as strings.
+func writeJSONNode(buf *bytes.Buffer, n *yaml.Node) error {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSONNode(buf, n.Content[0])
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONString(buf, n.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSONNode(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		quoted := n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0
+		if !quoted && isJSONLiteral(n.Value) {
+			buf.WriteString(n.Value)
+			return nil
+		}
+		return writeJSONString(buf, n.Value)
+	default:
+		return fmt.Errorf("line %d: unsupported node in a JSON file", n.Line)
+	}
+	return nil
+}
+
+// writeJSONString writes a JSON string, without escaping HTML characters.
+func writeJSONString(buf *bytes.Buffer, value string) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode ends the value with a newline
+	return nil
+}
+
+// isJSONLiteral reports whether a scalar's text is a JSON number, boolean or null.
+func isJSONLiteral(value string) bool {
+	return value != "" && value == strings.TrimSpace(value) && !strings.ContainsAny(value[:1], `"[{`) && json.Valid([]byte(value))
+}
+
+// jsonIndent returns the indentation of a JSON file, the leading whitespace of its least indented line, and whether the
+// file spans several lines; a file holding its value on one line is written back compact.
+func jsonIndent(content []byte) (string, bool) {
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	indent := ""
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if prefix := line[:len(line)-len(trimmed)]; prefix != "" && trimmed != "" && (indent == "" || len(prefix) < len(indent)) {
+			indent = prefix
+		}
+	}
+	return indent, len(lines) > 1
+}
@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEditJSONFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mutate  func(t *testing.T, data *LoadedData)
+	}{
+		{
+			name: "aliased and unknown keys",
+			content: `{
+  "users": [
+    {"Username": "alice", "Full Name": "Alice", "employee_id": 7, "profile": {"department": "R&D", "level": 3}},
+    {"Username": "bob", "Full Name": "Bob"}
+  ],
+  "grants": [
+    {"Principal Receiving Grant": "alice", "Entitlement": "eng:member", "note": "onboarding"}
+  ]
+}
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users[1].DisplayName = "Robert"
+				data.Grants = append(data.Grants, GrantData{Principal: "bob", EntitlementId: "eng:member"})
+			},
+		},
+		{
+			name: "multi-value cells and members",
+			content: `{
+	"entitlements": [
+		{"resource_name": "eng", "entitlement": "member", "members": ["alice", "bob; carol"]}
+	],
+	"grants": [
+		{"principal": "dave; erin", "entitlement_id": "eng:member"}
+	]
+}
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "erin -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "carol -> eng:member")
+			},
+		},
+		{
+			name: "nested entitlements",
+			content: `{
+  "resources": [
+    {
+      "resource_type": "folder",
+      "name": "shared",
+      "resources": [
+        {"resource_type": "folder", "name": "shared", "entitlements": [{"entitlement": "reader", "members": ["bob"]}]}
+      ],
+      "entitlements": [{"entitlement": "reader", "grants": ["alice"]}]
+    }
+  ]
+}
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Entitlements[0].Description = "Outer folder readers"
+				data.Grants = removeRecord(t, data.Grants, "alice -> shared:reader")
+			},
+		},
+		{
+			name:    "duplicate keys next to creates",
+			content: `{"grants":[{"principal":"alice","entitlement_id":"eng:member"},{"principal":"alice; bob","entitlement_id":"eng:member"}]}`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "carol", EntitlementId: "eng:member"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := writeTestFile(t, "access.json", tt.content)
+			assertEditRoundTrip(t, filePath, nil, func(changes []journalRecordChange) error {
+				return editJSONFile(filePath, nil, changes, nil)
+			}, tt.mutate)
+		})
+	}
+}
+
+func TestEditJSONFileKeepsUnchangedContent(t *testing.T) {
+	filePath := writeTestFile(t, "access.json", `{
+    "users": [
+        {
+            "name": "alice",
+            "display_name": "Alice",
+            "links": "https:\/\/example.com\/alice?a=1&b=2",
+            "score": 1.50
+        }
+    ],
+    "grants": []
+}
+`)
+
+	err := editJSONFile(filePath, nil, []journalRecordChange{{
+		Section: sectionGrants,
+		Key:     "alice -> eng:member",
+		After:   []byte(`{"principal":"alice","entitlement_id":"eng:member"}`),
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+    "users": [
+        {
+            "name": "alice",
+            "display_name": "Alice",
+            "links": "https://example.com/alice?a=1&b=2",
+            "score": 1.50
+        }
+    ],
+    "grants": [
+        {
+            "principal": "alice",
+            "entitlement_id": "eng:member"
+        }
+    ]
+}
+`
+	if string(content) != want {
+		t.Errorf("got\n%s\nwant\n%s", content, want)
+	}
+}
@@ -0,0 +1,361 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Resource types and entitlement slugs produced for Kubernetes RBAC manifests.
+const (
+	k8sNamespaceResourceType   = "namespace"
+	k8sRoleResourceType        = "role"
+	k8sClusterRoleResourceType = "cluster_role"
+	k8sGroupResourceType       = "k8s_group"
+	k8sBoundEntitlement        = "bound"
+)
+
+// The k8sObject struct holds the fields of Kubernetes RBAC objects used by the Kubernetes loader.
+// "List" objects (as produced by kubectl get -o yaml) carry their objects in Items.
+type k8sObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Rules []struct {
+		APIGroups       []string `yaml:"apiGroups"`
+		Resources       []string `yaml:"resources"`
+		NonResourceURLs []string `yaml:"nonResourceURLs"`
+		Verbs           []string `yaml:"verbs"`
+	} `yaml:"rules"`
+	RoleRef struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"roleRef"`
+	Subjects []struct {
+		Kind      string `yaml:"kind"`
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"subjects"`
+	Items []k8sObject `yaml:"items"`
+}
+
+// Kubernetes RBAC Loading Logic
+// loadKubernetesData handles the specific logic for reading Kubernetes RBAC manifests.
+// The input is a multi-document YAML (or JSON) file, or a directory whose .yaml/.yml/.json files are all read.
+// Namespaces become parent resources of their Roles; Roles and ClusterRoles become role resources with a "bound" entitlement;
+// RoleBindings and ClusterRoleBindings become grants to their User, Group and ServiceAccount subjects.
+// A RoleBinding that references a ClusterRole grants the ClusterRole's "bound-<namespace>" entitlement, keeping the namespace scope.
+// Resources are named after their kind so that names of different kinds cannot collide, e.g. "Role/default/pod-reader".
+func loadKubernetesData(ctx context.Context, inputPath string) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	files, err := kubernetesInputFiles(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []k8sObject
+	for _, file := range files {
+		fileObjects, err := readKubernetesManifest(file)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, fileObjects...)
+	}
+
+	loadedData := convertKubernetesObjects(ctx, objects)
+	if len(files) > 1 || files[0] != inputPath {
+		loadedData.includedFiles = files
+	}
+	l.Debug("Loaded Kubernetes RBAC manifests",
+		zap.String("input", inputPath),
+		zap.Int("files", len(files)),
+		zap.Int("objects", len(objects)),
+	)
+	return loadedData, nil
+}
+
+// kubernetesInputFiles returns the manifest files to read: the input file itself, or the sorted manifests of an input directory.
+func kubernetesInputFiles(inputPath string) ([]string, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat Kubernetes input %s: %w", inputPath, err)
+	}
+	if !info.IsDir() {
+		return []string{inputPath}, nil
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(inputPath, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no manifest files found in Kubernetes input directory %s", inputPath)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readKubernetesManifest decodes every document of a manifest file, flattening List objects.
+func readKubernetesManifest(filePath string) ([]k8sObject, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	var objects []k8sObject
+	var flatten func(obj k8sObject)
+	flatten = func(obj k8sObject) {
+		if strings.HasSuffix(obj.Kind, "List") {
+			for _, item := range obj.Items {
+				flatten(item)
+			}
+			return
+		}
+		objects = append(objects, obj)
+	}
+
+	decoder := yaml.NewDecoder(f)
+	for {
+		var obj k8sObject
+		err := decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Kubernetes manifest %s: %w", filePath, err)
+		}
+		flatten(obj)
+	}
+}
+
+// convertKubernetesObjects maps Namespaces, Roles, ClusterRoles and their bindings onto resources, entitlements and grants.
+// Namespaces and ClusterRoles that are referenced but not defined in the input are created implicitly.
+func convertKubernetesObjects(ctx context.Context, objects []k8sObject) *LoadedData {
+	l := ctxzap.Extract(ctx)
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+
+	resources := make(map[string]struct{})
+	entitlements := make(map[string]struct{})
+	grants := make(map[GrantData]struct{})
+
+	addResource := func(r ResourceData) {
+		if _, ok := resources[r.Name]; ok {
+			return
+		}
+		resources[r.Name] = struct{}{}
+		loadedData.Resources = append(loadedData.Resources, r)
+	}
+	addEntitlement := func(e EntitlementData) string {
+		id := e.ResourceName + ":" + e.Entitlement
+		if _, ok := entitlements[id]; !ok {
+			entitlements[id] = struct{}{}
+			loadedData.Entitlements = append(loadedData.Entitlements, e)
+		}
+		return id
+	}
+	addGrant := func(principal string, entitlementId string) {
+		g := GrantData{Principal: principal, EntitlementId: entitlementId}
+		if _, ok := grants[g]; ok {
+			return
+		}
+		grants[g] = struct{}{}
+		loadedData.Grants = append(loadedData.Grants, g)
+	}
+
+	addNamespace := func(namespace string) string {
+		name := "Namespace/" + namespace
+		addResource(ResourceData{
+			ResourceType:     k8sNamespaceResourceType,
+			ResourceFunction: "group",
+			Name:             name,
+			DisplayName:      namespace,
+			Description:      fmt.Sprintf("Kubernetes namespace %s", namespace),
+		})
+		return name
+	}
+	addRole := func(kind string, namespace string, roleName string, description string) string {
+		if kind == "ClusterRole" {
+			name := "ClusterRole/" + roleName
+			if description == "" {
+				description = "ClusterRole referenced by bindings but not defined in the input"
+			}
+			addResource(ResourceData{
+				ResourceType:     k8sClusterRoleResourceType,
+				ResourceFunction: "role",
+				Name:             name,
+				DisplayName:      roleName,
+				Description:      description,
+			})
+			return name
+		}
+		name := "Role/" + namespace + "/" + roleName
+		if description == "" {
+			description = "Role referenced by bindings but not defined in the input"
+		}
+		addResource(ResourceData{
+			ResourceType:     k8sRoleResourceType,
+			ResourceFunction: "role",
+			Name:             name,
+			DisplayName:      roleName,
+			Description:      description,
+			ParentResource:   addNamespace(namespace),
+		})
+		return name
+	}
+
+	users := make(map[string]struct{})
+	addUser := func(name string, userData UserData) {
+		if _, ok := users[name]; ok {
+			return
+		}
+		users[name] = struct{}{}
+		loadedData.Users = append(loadedData.Users, userData)
+	}
+
+	// Namespaces and roles are defined first so that resources keep their own descriptions when bindings reference them.
+	for _, obj := range objects {
+		switch obj.Kind {
+		case "Namespace":
+			addNamespace(obj.Metadata.Name)
+		case "Role", "ClusterRole":
+			namespace := obj.Metadata.Namespace
+			if obj.Kind == "Role" && namespace == "" {
+				namespace = "default"
+			}
+			name := addRole(obj.Kind, namespace, obj.Metadata.Name, summarizeK8sRules(obj))
+			addEntitlement(EntitlementData{
+				ResourceName: name,
+				Entitlement:  k8sBoundEntitlement,
+				DisplayName:  "Bound",
+				Description:  fmt.Sprintf("Bound to the %s %s", obj.Kind, obj.Metadata.Name),
+			})
+		}
+	}
+
+	for _, obj := range objects {
+		if obj.Kind != "RoleBinding" && obj.Kind != "ClusterRoleBinding" {
+			continue
+		}
+		namespace := obj.Metadata.Namespace
+		if obj.Kind == "RoleBinding" && namespace == "" {
+			namespace = "default"
+		}
+
+		var entitlementId string
+		switch {
+		case obj.RoleRef.Kind == "ClusterRole" && obj.Kind == "RoleBinding":
+			// A RoleBinding grants a ClusterRole's permissions within the binding's namespace only.
+			roleName := addRole("ClusterRole", "", obj.RoleRef.Name, "")
+			addNamespace(namespace)
+			entitlementId = addEntitlement(EntitlementData{
+				ResourceName: roleName,
+				Entitlement:  k8sBoundEntitlement + "-" + namespace,
+				DisplayName:  fmt.Sprintf("Bound in %s", namespace),
+				Description:  fmt.Sprintf("Bound to the ClusterRole %s within the %s namespace", obj.RoleRef.Name, namespace),
+			})
+		case obj.RoleRef.Kind == "ClusterRole" || obj.RoleRef.Kind == "Role":
+			roleName := addRole(obj.RoleRef.Kind, namespace, obj.RoleRef.Name, "")
+			entitlementId = addEntitlement(EntitlementData{
+				ResourceName: roleName,
+				Entitlement:  k8sBoundEntitlement,
+				DisplayName:  "Bound",
+				Description:  fmt.Sprintf("Bound to the %s %s", obj.RoleRef.Kind, obj.RoleRef.Name),
+			})
+		default:
+			l.Warn("Skipping Kubernetes binding with unsupported roleRef kind",
+				zap.String("binding", obj.Metadata.Name), zap.String("role_ref_kind", obj.RoleRef.Kind))
+			continue
+		}
+
+		for _, subject := range obj.Subjects {
+			switch subject.Kind {
+			case "User":
+				name := "User/" + subject.Name
+				addUser(name, UserData{
+					Name:        name,
+					DisplayName: subject.Name,
+					Type:        "human",
+					Profile:     map[string]interface{}{"kind": "User", "name": subject.Name},
+				})
+				addGrant(name, entitlementId)
+			case "ServiceAccount":
+				saNamespace := subject.Namespace
+				if saNamespace == "" {
+					saNamespace = namespace
+				}
+				name := "ServiceAccount/" + saNamespace + "/" + subject.Name
+				addUser(name, UserData{
+					Name:        name,
+					DisplayName: subject.Name,
+					Type:        "service",
+					Profile:     map[string]interface{}{"kind": "ServiceAccount", "name": subject.Name, "namespace": saNamespace},
+				})
+				addGrant(name, entitlementId)
+			case "Group":
+				name := "Group/" + subject.Name
+				addResource(ResourceData{
+					ResourceType:     k8sGroupResourceType,
+					ResourceFunction: "group",
+					Name:             name,
+					DisplayName:      subject.Name,
+					Description:      fmt.Sprintf("Kubernetes group %s", subject.Name),
+				})
+				addGrant(name, entitlementId)
+			default:
+				l.Warn("Skipping Kubernetes binding subject with unsupported kind",
+					zap.String("binding", obj.Metadata.Name), zap.String("subject_kind", subject.Kind))
+			}
+		}
+	}
+
+	return loadedData
+}
+
+// summarizeK8sRules describes the rules of a Role or ClusterRole, e.g. "get, list pods; * deployments.apps".
+func summarizeK8sRules(obj k8sObject) string {
+	parts := make([]string, 0, len(obj.Rules))
+	for _, rule := range obj.Rules {
+		var targets []string
+		for _, resource := range rule.Resources {
+			for _, group := range rule.APIGroups {
+				if group == "" {
+					targets = append(targets, resource)
+				} else {
+					targets = append(targets, resource+"."+group)
+				}
+			}
+			if len(rule.APIGroups) == 0 {
+				targets = append(targets, resource)
+			}
+		}
+		targets = append(targets, rule.NonResourceURLs...)
+		parts = append(parts, strings.Join(rule.Verbs, ", ")+" "+strings.Join(targets, ", "))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%s %s", obj.Kind, obj.Metadata.Name)
+	}
+	return strings.Join(parts, "; ")
+}
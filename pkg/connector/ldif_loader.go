@@ -0,0 +1,475 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Resource types and the entitlement slug produced for LDIF directory entries.
+const (
+	ldifOUResourceType    = "ou"
+	ldifGroupResourceType = "group"
+	ldifMemberEntitlement = "member"
+)
+
+// LDAP object classes (lowercase) that identify users, groups and organizational units.
+var (
+	ldifUserClasses  = []string{"inetorgperson", "posixaccount", "organizationalperson", "person"}
+	ldifGroupClasses = []string{"groupofnames", "groupofuniquenames", "posixgroup"}
+	ldifOUClasses    = []string{"organizationalunit"}
+)
+
+// ldifProfileAttributes lists the user attributes copied into the user profile under their usual LDAP spelling.
+var ldifProfileAttributes = []string{
+	"uidNumber", "gidNumber", "homeDirectory", "loginShell", "employeeNumber", "employeeType",
+	"title", "departmentNumber", "givenName", "sn", "telephoneNumber", "o", "ou",
+}
+
+// The ldifEntry struct holds one entry of an LDIF file.
+// Attribute names are stored lowercase; values keep their order of appearance.
+type ldifEntry struct {
+	dn     string
+	normDN string
+	line   int
+	attrs  map[string][]string
+}
+
+// first returns the first value of an attribute, or an empty string if it is absent.
+func (e *ldifEntry) first(attr string) string {
+	if values := e.attrs[strings.ToLower(attr)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// hasObjectClass reports whether the entry has any of the given (lowercase) object classes.
+func (e *ldifEntry) hasObjectClass(classes []string) bool {
+	for _, oc := range e.attrs["objectclass"] {
+		oc = strings.ToLower(strings.TrimSpace(oc))
+		for _, c := range classes {
+			if oc == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LDIF Loading Logic
+// loadLdifData handles the specific logic for reading and parsing .ldif directory exports.
+// inetOrgPerson/posixAccount entries become users, groupOfNames/groupOfUniqueNames/posixGroup entries become group
+// resources with a "member" entitlement, and organizationalUnit entries become "ou" resources.
+// Groups and OUs get the nearest enclosing OU as their parent resource.
+// member/uniqueMember DNs and memberUid values are turned into grants of the group's member entitlement;
+// a nested group is granted through its own member entitlement so that its members are expanded.
+func loadLdifData(ctx context.Context, filePath string) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			l.Error("failed to close file", zap.Error(err), zap.String("file", filePath))
+		}
+	}()
+
+	entries, err := parseLdif(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse LDIF file %s: %w", filePath, err)
+	}
+
+	loadedData := convertLdifEntries(ctx, entries)
+	l.Debug("Loaded LDIF file",
+		zap.String("file", filePath),
+		zap.Int("entries", len(entries)),
+		zap.Int("users", len(loadedData.Users)),
+		zap.Int("resources", len(loadedData.Resources)),
+	)
+	return loadedData, nil
+}
+
+// parseLdif reads the content records of an LDIF stream (RFC 2849).
+// Folded lines, comments and base64-encoded values ("attr:: ...") are supported.
+// Change records other than "changetype: add" and values referenced by URL ("attr:< ...") are skipped with a warning.
+func parseLdif(ctx context.Context, r io.Reader) ([]*ldifEntry, error) {
+	l := ctxzap.Extract(ctx)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var entries []*ldifEntry
+	var lines []string
+	startLine := 0
+	lineNumber := 0
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry, err := parseLdifRecord(ctx, lines, startLine)
+		lines = lines[:0]
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		if changeType := strings.ToLower(entry.first("changetype")); changeType != "" && changeType != "add" {
+			l.Warn("Skipping LDIF change record", zap.String("dn", entry.dn), zap.String("changetype", changeType), zap.Int("line", entry.line))
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, " "):
+			// A line starting with a single space continues the previous line.
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("line %d: continuation line without a preceding attribute", lineNumber)
+			}
+			lines[len(lines)-1] += line[1:]
+		case strings.HasPrefix(line, "#"):
+			// Comments may be folded as well; keep a placeholder so that continuations attach to it.
+			lines = append(lines, "#")
+		default:
+			if len(lines) == 0 {
+				startLine = lineNumber
+			}
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseLdifRecord parses the unfolded lines of one LDIF record.
+// It returns nil for records without a DN, such as a lone "version: 1" line.
+func parseLdifRecord(ctx context.Context, lines []string, startLine int) (*ldifEntry, error) {
+	l := ctxzap.Extract(ctx)
+
+	entry := &ldifEntry{line: startLine, attrs: make(map[string][]string)}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid LDIF line %q in record", startLine, line)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch {
+		case strings.HasPrefix(value, ":"):
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid base64 value for %s: %w", startLine, name, err)
+			}
+			value = string(decoded)
+		case strings.HasPrefix(value, "<"):
+			l.Warn("Skipping LDIF attribute with URL value", zap.String("attribute", name), zap.Int("line", startLine))
+			continue
+		default:
+			value = strings.TrimSpace(value)
+		}
+
+		if name == "dn" {
+			entry.dn = value
+			continue
+		}
+		if name == "version" && entry.dn == "" {
+			continue
+		}
+		// Attribute options such as ";lang-en" or ";binary" are dropped.
+		name, _, _ = strings.Cut(name, ";")
+		entry.attrs[name] = append(entry.attrs[name], value)
+	}
+
+	if entry.dn == "" {
+		if len(entry.attrs) > 0 {
+			return nil, fmt.Errorf("line %d: LDIF record has no dn", startLine)
+		}
+		return nil, nil
+	}
+	entry.normDN = normalizeDN(entry.dn)
+	return entry, nil
+}
+
+// convertLdifEntries maps directory entries onto users, resources, entitlements and grants.
+// Entries are named after their uid (users) or RDN value (groups and OUs); when several entries would get the same name,
+// all of them are named after their DN instead so that names stay unique.
+func convertLdifEntries(ctx context.Context, entries []*ldifEntry) *LoadedData {
+	l := ctxzap.Extract(ctx)
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+
+	type node struct {
+		entry *ldifEntry
+		kind  string
+		name  string
+	}
+
+	var users, groups, ous []*node
+	byDN := make(map[string]*node)
+	nameCount := make(map[string]int)
+	for _, entry := range entries {
+		n := &node{entry: entry}
+		switch {
+		case entry.hasObjectClass(ldifUserClasses):
+			n.kind = sectionUsers
+			n.name = entry.first("uid")
+		case entry.hasObjectClass(ldifGroupClasses):
+			n.kind = ldifGroupResourceType
+		case entry.hasObjectClass(ldifOUClasses):
+			n.kind = ldifOUResourceType
+		default:
+			continue
+		}
+		if _, exists := byDN[entry.normDN]; exists {
+			l.Warn("Skipping duplicate LDIF entry", zap.String("dn", entry.dn), zap.Int("line", entry.line))
+			continue
+		}
+		if n.name == "" {
+			n.name = rdnValue(entry.dn)
+		}
+		byDN[entry.normDN] = n
+		nameCount[n.name]++
+
+		switch n.kind {
+		case sectionUsers:
+			users = append(users, n)
+		case ldifGroupResourceType:
+			groups = append(groups, n)
+		case ldifOUResourceType:
+			ous = append(ous, n)
+		}
+	}
+	for _, n := range byDN {
+		if nameCount[n.name] > 1 {
+			n.name = n.entry.dn
+		}
+	}
+
+	seenGrants := make(map[GrantData]struct{})
+	addGrant := func(principal string, entitlementId string) {
+		grantData := GrantData{Principal: principal, EntitlementId: entitlementId}
+		if _, ok := seenGrants[grantData]; ok {
+			return
+		}
+		seenGrants[grantData] = struct{}{}
+		loadedData.Grants = append(loadedData.Grants, grantData)
+	}
+
+	// nearestOU returns the name of the closest enclosing OU present in the file.
+	nearestOU := func(normDN string) string {
+		for dn := parentDN(normDN); dn != ""; dn = parentDN(dn) {
+			if n, ok := byDN[dn]; ok && n.kind == ldifOUResourceType {
+				return n.name
+			}
+		}
+		return ""
+	}
+
+	usersByUid := make(map[string]string)
+	for _, n := range users {
+		for _, uid := range n.entry.attrs["uid"] {
+			usersByUid[strings.ToLower(uid)] = n.name
+		}
+
+		displayName := n.entry.first("displayName")
+		if displayName == "" {
+			displayName = n.entry.first("cn")
+		}
+		if displayName == "" {
+			displayName = n.name
+		}
+		status := "enabled"
+		if strings.EqualFold(n.entry.first("nsAccountLock"), "true") || n.entry.first("pwdAccountLockedTime") != "" {
+			status = "disabled"
+		}
+		profile := map[string]interface{}{"dn": n.entry.dn}
+		for _, attr := range ldifProfileAttributes {
+			if value := n.entry.first(attr); value != "" {
+				profile[attr] = value
+			}
+		}
+
+		loadedData.Users = append(loadedData.Users, UserData{
+			Name:        n.name,
+			DisplayName: displayName,
+			Email:       n.entry.first("mail"),
+			Status:      status,
+			Type:        "human",
+			Profile:     profile,
+		})
+	}
+
+	for _, n := range ous {
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     ldifOUResourceType,
+			ResourceFunction: "group",
+			Name:             n.name,
+			DisplayName:      rdnValue(n.entry.dn),
+			Description:      n.entry.first("description"),
+			ParentResource:   nearestOU(n.entry.normDN),
+		})
+	}
+
+	groupsByGid := make(map[string]string)
+	for _, n := range groups {
+		if gid := n.entry.first("gidNumber"); gid != "" {
+			groupsByGid[gid] = n.name
+		}
+
+		displayName := n.entry.first("cn")
+		if displayName == "" {
+			displayName = n.name
+		}
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     ldifGroupResourceType,
+			ResourceFunction: "group",
+			Name:             n.name,
+			DisplayName:      displayName,
+			Description:      n.entry.first("description"),
+			ParentResource:   nearestOU(n.entry.normDN),
+		})
+		loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+			ResourceName: n.name,
+			Entitlement:  ldifMemberEntitlement,
+			DisplayName:  "Member",
+			Description:  fmt.Sprintf("Member of the %s group", displayName),
+		})
+	}
+
+	for _, n := range groups {
+		entitlementId := n.name + ":" + ldifMemberEntitlement
+
+		memberDNs := append(append([]string{}, n.entry.attrs["member"]...), n.entry.attrs["uniquemember"]...)
+		for _, memberDN := range memberDNs {
+			// uniqueMember values may carry an optional "#'<bits>'B" unique identifier.
+			if idx := strings.LastIndex(memberDN, "#"); idx > 0 && strings.HasSuffix(memberDN, "B") {
+				memberDN = memberDN[:idx]
+			}
+			normMember := normalizeDN(memberDN)
+			if normMember == n.entry.normDN {
+				continue
+			}
+			member, ok := byDN[normMember]
+			switch {
+			case !ok:
+				// Referenced entries outside the export are reported when grants are resolved.
+				addGrant(memberDN, entitlementId)
+			case member.kind == ldifGroupResourceType:
+				addGrant(member.name+":"+ldifMemberEntitlement, entitlementId)
+			case member.kind == sectionUsers:
+				addGrant(member.name, entitlementId)
+			default:
+				l.Warn("Skipping LDIF group member that is neither a user nor a group",
+					zap.String("group", n.entry.dn), zap.String("member", memberDN))
+			}
+		}
+
+		for _, uid := range n.entry.attrs["memberuid"] {
+			if name, ok := usersByUid[strings.ToLower(uid)]; ok {
+				addGrant(name, entitlementId)
+				continue
+			}
+			addGrant(uid, entitlementId)
+		}
+	}
+
+	// posixAccount users are also members of the posixGroup matching their primary gidNumber.
+	for _, n := range users {
+		if !n.entry.hasObjectClass([]string{"posixaccount"}) {
+			continue
+		}
+		if groupName, ok := groupsByGid[n.entry.first("gidNumber")]; ok {
+			addGrant(n.name, groupName+":"+ldifMemberEntitlement)
+		}
+	}
+
+	return loadedData
+}
+
+// splitDN splits a DN into its RDNs, honoring backslash-escaped commas.
+func splitDN(dn string) []string {
+	var rdns []string
+	var sb strings.Builder
+	escaped := false
+	for _, r := range dn {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+			escaped = false
+			continue
+		case r == '\\':
+			escaped = true
+		case r == ',' || r == ';':
+			rdns = append(rdns, sb.String())
+			sb.Reset()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() > 0 || len(rdns) > 0 {
+		rdns = append(rdns, sb.String())
+	}
+	return rdns
+}
+
+// normalizeDN returns a canonical form of a DN for comparisons: lowercase, with spaces around separators removed.
+func normalizeDN(dn string) string {
+	rdns := splitDN(dn)
+	for i, rdn := range rdns {
+		attr, value, _ := strings.Cut(rdn, "=")
+		rdns[i] = strings.ToLower(strings.TrimSpace(attr)) + "=" + strings.ToLower(strings.TrimSpace(value))
+	}
+	return strings.Join(rdns, ",")
+}
+
+// parentDN returns the DN of the parent entry of a normalized DN, or an empty string for a top-level entry.
+func parentDN(normDN string) string {
+	rdns := splitDN(normDN)
+	if len(rdns) <= 1 {
+		return ""
+	}
+	return normalizeDN(strings.Join(rdns[1:], ","))
+}
+
+// rdnValue returns the value of the first RDN of a DN, e.g. "engineering" for "cn=engineering,ou=groups,dc=example,dc=com".
+func rdnValue(dn string) string {
+	rdns := splitDN(dn)
+	if len(rdns) == 0 {
+		return ""
+	}
+	_, value, _ := strings.Cut(rdns[0], "=")
+	value = strings.ReplaceAll(strings.TrimSpace(value), `\`, "")
+	return value
+}
@@ -0,0 +1,150 @@
+package connector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Section names used by the input file and the mapping file.
+const (
+	sectionUsers        = "users"
+	sectionResources    = "resources"
+	sectionEntitlements = "entitlements"
+	sectionGrants       = "grants"
+)
+
+// sectionNames lists the data sections in the order they are loaded.
+var sectionNames = []string{sectionUsers, sectionResources, sectionEntitlements, sectionGrants}
+
+// The LoadOptions struct controls how the input file is read.
+// It is typically read from the YAML mapping file passed with --mapping-file; the zero value reproduces the default layout.
+type LoadOptions struct {
+	// Sheets maps a section name (users, resources, entitlements, grants) to the spreadsheet sheets or named tables holding its rows.
+	// Sections without an entry are read from the sheet with the same name as the section.
+	Sheets map[string][]SheetSource `yaml:"sheets" json:"sheets"`
+
+	// Columns maps a section name to a mapping of source headers (Excel) or keys (YAML/JSON) to canonical fields,
+	// e.g. "Login ID": name. Profile attributes are addressed as "profile.<key>". Matching is case-insensitive.
+	Columns map[string]map[string]string `yaml:"columns" json:"columns"`
+
+	// Delimiter separates multiple values in a grant's principal or entitlement_id, or in an entitlement's members (default ";").
+	Delimiter string `yaml:"delimiter" json:"delimiter"`
+
+	// Queries maps a section name to the SQL query whose result rows hold the section when the input is a SQLite database.
+	// Sections without an entry are read from the table with the same name as the section.
+	Queries map[string]string `yaml:"queries" json:"queries"`
+
+	// Format selects the parser for the input file (e.g. "scim"). When empty the format is detected from the file extension.
+	Format InputFormat `yaml:"format" json:"format"`
+}
+
+// defaultDelimiter separates multiple values in a single cell when no delimiter is configured.
+const defaultDelimiter = ";"
+
+// The SheetSource struct points a section at one sheet or named table in a spreadsheet (.xlsx, .ods or .xls).
+type SheetSource struct {
+	Sheet     string `yaml:"sheet" json:"sheet"`           // Sheet name; may be a glob pattern such as "grants_*"
+	Table     string `yaml:"table" json:"table"`           // Name of an Excel Table (ListObject), .xlsx only; its first row is the header
+	HeaderRow int    `yaml:"header_row" json:"header_row"` // 1-based row holding the headers; rows above it are ignored (default 1)
+}
+
+// LoadMappingFile reads LoadOptions from a YAML (or JSON) mapping file and validates them.
+func LoadMappingFile(path string) (*LoadOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file %s: %w", path, err)
+	}
+
+	opts := &LoadOptions{}
+	err = yaml.Unmarshal(data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping file %s: %w", path, err)
+	}
+
+	err = opts.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", path, err)
+	}
+	return opts, nil
+}
+
+// validate checks that the options only reference known sections and that every sheet source is usable.
+func (o *LoadOptions) validate() error {
+	for section, sources := range o.Sheets {
+		if !isSectionName(section) {
+			return fmt.Errorf("unknown section %q in sheets (expected one of %s)", section, strings.Join(sectionNames, ", "))
+		}
+		for i, src := range sources {
+			if src.Sheet == "" && src.Table == "" {
+				return fmt.Errorf("sheets.%s[%d]: either sheet or table must be set", section, i)
+			}
+			if src.HeaderRow < 0 {
+				return fmt.Errorf("sheets.%s[%d]: header_row must be positive", section, i)
+			}
+		}
+	}
+	for section, query := range o.Queries {
+		if !isSectionName(section) {
+			return fmt.Errorf("unknown section %q in queries (expected one of %s)", section, strings.Join(sectionNames, ", "))
+		}
+		if strings.TrimSpace(query) == "" {
+			return fmt.Errorf("queries.%s: query must not be empty", section)
+		}
+	}
+	format, err := ParseInputFormat(string(o.Format))
+	if err != nil {
+		return err
+	}
+	o.Format = format
+	if strings.Contains(o.Delimiter, ":") {
+		return fmt.Errorf("delimiter %q must not contain ':', which separates resource names from entitlement slugs", o.Delimiter)
+	}
+	return validateColumnMapping(o.Columns)
+}
+
+// delimiter returns the configured multi-value delimiter or the default.
+func (o *LoadOptions) delimiter() string {
+	if o == nil || o.Delimiter == "" {
+		return defaultDelimiter
+	}
+	return o.Delimiter
+}
+
+// format returns the configured input format, or InputFormatAuto when none is set.
+func (o *LoadOptions) format() InputFormat {
+	if o == nil {
+		return InputFormatAuto
+	}
+	return o.Format
+}
+
+// sheetSources returns the configured sheet sources for a section, defaulting to a sheet named after the section.
+func (o *LoadOptions) sheetSources(section string) []SheetSource {
+	if o != nil {
+		if sources, ok := o.Sheets[section]; ok && len(sources) > 0 {
+			return sources
+		}
+	}
+	return []SheetSource{{Sheet: section}}
+}
+
+// query returns the configured SQL query for a section, or "" when the section is read from its table.
+func (o *LoadOptions) query(section string) string {
+	if o == nil {
+		return ""
+	}
+	return o.Queries[section]
+}
+
+// isSectionName reports whether name is one of the known data sections.
+func isSectionName(name string) bool {
+	for _, s := range sectionNames {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
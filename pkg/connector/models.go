@@ -9,11 +9,43 @@ import (
 
 // The FileConnector struct is the main implementation of the Baton connector for file processing.
 // It is required by the connectorbuilder.Connector interface for defining connector behavior.
-// It holds the path to the input data file.
+// It holds the path to the input data file, the shared data store and the configured page sizes.
 // The structure provides the context (file path) needed for loading data during sync operations.
 // Instances are created by NewFileConnector.
 type FileConnector struct {
 	inputFilePath string
+	store         *dataStore
+	pageSizes     PageSizes
+	gitOptions    *GitOptions
+}
+
+// defaultPageSize is the number of items returned per page when no page size is configured.
+const defaultPageSize = 50
+
+// PageSizes holds the number of items returned per page by each syncer method.
+type PageSizes struct {
+	Resources    int
+	Entitlements int
+	Grants       int
+}
+
+// Option configures optional FileConnector behavior.
+type Option func(*FileConnector)
+
+// WithPageSizes sets the page sizes used by the List, Entitlements and Grants syncer methods.
+// Zero values keep the default page size.
+func WithPageSizes(pageSizes PageSizes) Option {
+	return func(fc *FileConnector) {
+		if pageSizes.Resources > 0 {
+			fc.pageSizes.Resources = pageSizes.Resources
+		}
+		if pageSizes.Entitlements > 0 {
+			fc.pageSizes.Entitlements = pageSizes.Entitlements
+		}
+		if pageSizes.Grants > 0 {
+			fc.pageSizes.Grants = pageSizes.Grants
+		}
+	}
 }
 
 // LoadedData holds all the data parsed from the input file.
@@ -23,6 +55,9 @@ type LoadedData struct {
 	Resources    []ResourceData    `yaml:"resources" json:"resources"`
 	Entitlements []EntitlementData `yaml:"entitlements" json:"entitlements"`
 	Grants       []GrantData       `yaml:"grants" json:"grants"`
+
+	// includedFiles lists the files read in addition to the input file (e.g. YAML includes), so changes to them trigger a reload.
+	includedFiles []string
 }
 
 // The UserData struct holds raw data corresponding to a row in the 'users' tab.
@@ -41,7 +76,9 @@ type UserData struct {
 
 // The ResourceData struct holds raw data corresponding to a row in the 'resources' tab.
 // It is defined for parsing data into an intermediary Go representation.
-// It holds fields ResourceType (e.g., "role"), ResourceFunction (trait string like "group"), Name, DisplayName, Description, ParentResource.
+// It holds fields ResourceType (e.g., "role"), ResourceFunction (trait string like "group"), Name, DisplayName, Description, ParentResource,
+// and an optional Owner, the user or resource a secret belongs to.
+// Secret resources also keep RotatedAt and CredentialHash, written back when their credential is rotated.
 // The structure represents a single resource definition before conversion to an SDK Resource object.
 type ResourceData struct {
 	ResourceType     string `yaml:"resource_type" json:"resource_type"`         // Resource Type string (e.g., "role", "team", "workspace")
@@ -49,35 +86,82 @@ type ResourceData struct {
 	Name             string `yaml:"name" json:"name"`                           // Unique name/ID of the resource
 	DisplayName      string `yaml:"display_name" json:"display_name"`
 	Description      string `yaml:"description" json:"description"`
-	ParentResource   string `yaml:"parent_resource" json:"parent_resource"` // Name/ID of the parent resource, if any
+	ParentResource   string `yaml:"parent_resource" json:"parent_resource"`                     // Name/ID of the parent resource, if any
+	Owner            string `yaml:"owner,omitempty" json:"owner,omitempty"`                     // Name/ID of the identity owning a secret resource, if any
+	RotatedAt        string `yaml:"rotated_at,omitempty" json:"rotated_at,omitempty"`           // RFC 3339 time of the secret's last credential rotation, if any
+	CredentialHash   string `yaml:"credential_hash,omitempty" json:"credential_hash,omitempty"` // Salted hash of the secret's current credential, see hashCredential
 }
 
 // The EntitlementData struct holds raw data corresponding to a row in the 'entitlements' tab.
 // It is defined for parsing data into an intermediary Go representation.
-// It holds fields ResourceName (the resource it's defined on), Entitlement (acting as the slug), DisplayName, and Description.
+// It holds fields ResourceName (the resource it's defined on), Entitlement (acting as the slug), DisplayName, Description,
+// and an optional compact list of Members that are expanded into GrantData entries during loading.
 // The structure represents a single entitlement definition before conversion to an SDK Entitlement object.
 type EntitlementData struct {
-	ResourceName string `yaml:"resource_name" json:"resource_name"` // Name/ID of the resource this entitlement is defined ON
-	Entitlement  string `yaml:"entitlement" json:"entitlement"`     // The acts as the Slug
-	DisplayName  string `yaml:"display_name" json:"display_name"`
-	Description  string `yaml:"description" json:"description"`
+	ResourceName string   `yaml:"resource_name" json:"resource_name"` // Name/ID of the resource this entitlement is defined ON
+	Entitlement  string   `yaml:"entitlement" json:"entitlement"`     // The acts as the Slug
+	DisplayName  string   `yaml:"display_name" json:"display_name"`
+	Description  string   `yaml:"description" json:"description"`
+	Members      []string `yaml:"members,omitempty" json:"members,omitempty"` // Principals granted this entitlement; expanded into grants
 }
 
 // The GrantData struct holds raw data corresponding to a row in the 'grants' tab.
 // It is defined for parsing data into an intermediary Go representation.
 // It holds fields Principal (type:name[:membership_slug]) and EntitlementId (resource_name:entitlement_slug).
+// Either field may hold several values separated by the configured delimiter; they are expanded into one entry per combination during loading.
 // The structure represents a single grant relationship before conversion to an SDK Grant object.
 type GrantData struct {
 	Principal     string `yaml:"principal" json:"principal"`           // Format: "name" or "entitlement_id"
 	EntitlementId string `yaml:"entitlement_id" json:"entitlement_id"` // Format: "resource_name:entitlement_slug"
 }
 
+// WithLoadOptions sets the options used to read the input file, such as the sheet mapping.
+func WithLoadOptions(loadOptions *LoadOptions) Option {
+	return func(fc *FileConnector) {
+		fc.store.loadOptions = loadOptions
+	}
+}
+
+// WithGit enables git-backed mode: the input file must be in a git working tree, syncs record the commit they read,
+// and every change written back is committed.
+func WithGit(gitOptions GitOptions) Option {
+	return func(fc *FileConnector) {
+		fc.gitOptions = &gitOptions
+	}
+}
+
+// The nestedResourceData struct holds a resource in the nested YAML/JSON document shape.
+// It is defined for parsing documents where a resource declares its own entitlements and child resources.
+// It holds the flat ResourceData fields plus Entitlements (defined on this resource) and Resources (children of this resource).
+// The structure is flattened into ResourceData, EntitlementData and GrantData entries during loading.
+type nestedResourceData struct {
+	ResourceData `yaml:",inline"`
+	Entitlements []nestedEntitlementData `yaml:"entitlements"`
+	Resources    []nestedResourceData    `yaml:"resources"`
+}
+
+// The nestedEntitlementData struct holds an entitlement declared inside a nested resource.
+// Principals may be listed under either Grants or Members; both are expanded into GrantData entries.
+type nestedEntitlementData struct {
+	EntitlementData `yaml:",inline"`
+	Grants          []string `yaml:"grants"`
+}
+
+// The documentData struct is the decoding target for YAML/JSON documents.
+// It accepts both the flat shape and the nested resource shape, and is normalized into LoadedData by flattening its resources.
+type documentData struct {
+	Users        []UserData           `yaml:"users"`
+	Resources    []nestedResourceData `yaml:"resources"`
+	Entitlements []EntitlementData    `yaml:"entitlements"`
+	Grants       []GrantData          `yaml:"grants"`
+}
+
 // NewFileConnector creates a new instance of the FileConnector.
 // The function is the constructor used by the main command to initialize the connector.
 // The main command requires this constructor to instantiate the connector server.
 // Which provides the application entry point with a configured connector instance.
-// The implementation stores the provided file path for use during syncs.
-func NewFileConnector(ctx context.Context, filePath string) (*FileConnector, error) {
+// The implementation stores the provided file path for use during syncs and applies any options.
+func NewFileConnector(ctx context.Context, filePath string, opts ...Option) (*FileConnector, error) {
 	// Basic validation - ensure file path is not empty
 	if filePath == "" {
 		return nil, fmt.Errorf("input file path cannot be empty")
@@ -85,7 +169,26 @@ func NewFileConnector(ctx context.Context, filePath string) (*FileConnector, err
 
 	// Could add more validation here if needed (e.g., check extension initially)
 
-	return &FileConnector{
+	fc := &FileConnector{
 		inputFilePath: filePath,
-	}, nil
+		store:         newDataStore(filePath),
+		pageSizes: PageSizes{
+			Resources:    defaultPageSize,
+			Entitlements: defaultPageSize,
+			Grants:       defaultPageSize,
+		},
+	}
+	for _, opt := range opts {
+		opt(fc)
+	}
+
+	if fc.gitOptions != nil {
+		repo, err := openGitRepo(ctx, filePath, *fc.gitOptions)
+		if err != nil {
+			return nil, err
+		}
+		fc.store.git = repo
+	}
+
+	return fc, nil
 }
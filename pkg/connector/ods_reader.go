@@ -0,0 +1,202 @@
+package connector
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenDocument XML namespaces used in the content.xml of .ods files.
+const (
+	odsOfficeNamespace = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTableNamespace  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNamespace   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// openOdsWorkbook reads an OpenDocument spreadsheet (.ods) into memory.
+// Cells hold their displayed text, except date cells which are rendered as MM/DD/YYYY like other date cells.
+// Repeated rows and columns are expanded, and trailing empty rows and cells are dropped.
+func openOdsWorkbook(filePath string) (*gridWorkbook, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer zr.Close()
+
+	for _, entry := range zr.File {
+		if entry.Name != "content.xml" {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content of %s: %w", filePath, err)
+		}
+		defer rc.Close()
+
+		sheets, err := parseOdsContent(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse content of %s: %w", filePath, err)
+		}
+		return &gridWorkbook{format: ".ods", sheets: sheets}, nil
+	}
+	return nil, fmt.Errorf("file %s is not an OpenDocument spreadsheet: content.xml is missing", filePath)
+}
+
+// odsCell accumulates the content of the table cell being parsed.
+type odsCell struct {
+	repeat     int
+	fallback   string // Value from the cell's office attributes, used when it has no text
+	text       strings.Builder
+	paragraphs int
+}
+
+// parseOdsContent walks the content.xml of an OpenDocument spreadsheet and returns its sheets.
+func parseOdsContent(r io.Reader) ([]gridSheet, error) {
+	decoder := xml.NewDecoder(r)
+
+	var (
+		sheets          []gridSheet
+		sheet           *gridSheet
+		row             []string
+		rowRepeat       int
+		col             int
+		blankRows       int // Empty rows not yet stored, as they may be trailing rows
+		blankCells      int // Empty cells not yet stored, as they may be trailing cells
+		cell            *odsCell
+		paragraphDepth  int
+		annotationDepth int
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return sheets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNamespace && t.Name.Local == "table":
+				sheet = &gridSheet{name: odsAttr(t, odsTableNamespace, "name")}
+				blankRows = 0
+			case sheet == nil:
+			case t.Name.Space == odsTableNamespace && t.Name.Local == "table-row":
+				row = nil
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+				col = 0
+				blankCells = 0
+			case t.Name.Space == odsTableNamespace && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				cell = &odsCell{repeat: odsRepeat(t, "number-columns-repeated"), fallback: odsCellValue(t)}
+			case cell == nil:
+			case t.Name.Space == odsOfficeNamespace && t.Name.Local == "annotation":
+				annotationDepth++
+			case annotationDepth > 0:
+			case t.Name.Space == odsTextNamespace && t.Name.Local == "p":
+				if cell.paragraphs > 0 {
+					cell.text.WriteString("\n")
+				}
+				cell.paragraphs++
+				paragraphDepth++
+			case paragraphDepth == 0:
+			case t.Name.Space == odsTextNamespace && t.Name.Local == "s":
+				count, err := strconv.Atoi(odsAttr(t, odsTextNamespace, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				cell.text.WriteString(strings.Repeat(" ", count))
+			case t.Name.Space == odsTextNamespace && t.Name.Local == "tab":
+				cell.text.WriteString("\t")
+			case t.Name.Space == odsTextNamespace && t.Name.Local == "line-break":
+				cell.text.WriteString("\n")
+			}
+
+		case xml.CharData:
+			if cell != nil && paragraphDepth > 0 && annotationDepth == 0 {
+				cell.text.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case sheet == nil:
+			case t.Name.Space == odsTableNamespace && t.Name.Local == "table":
+				sheets = append(sheets, *sheet)
+				sheet = nil
+			case t.Name.Space == odsTableNamespace && t.Name.Local == "table-row":
+				if isBlankRow(row) {
+					blankRows += rowRepeat
+					continue
+				}
+				for ; blankRows > 0; blankRows-- {
+					sheet.cells = append(sheet.cells, nil)
+				}
+				for i := 0; i < rowRepeat; i++ {
+					sheet.cells = append(sheet.cells, row)
+				}
+			case cell == nil:
+			case t.Name.Space == odsTableNamespace && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				value := cell.text.String()
+				if cell.fallback != "" {
+					value = cell.fallback
+				}
+				if strings.TrimSpace(value) == "" {
+					blankCells += cell.repeat
+				} else {
+					col += blankCells
+					blankCells = 0
+					for i := 0; i < cell.repeat; i++ {
+						row = appendGridCell(row, col, value)
+						col++
+					}
+				}
+				cell = nil
+			case t.Name.Space == odsOfficeNamespace && t.Name.Local == "annotation":
+				annotationDepth--
+			case annotationDepth > 0:
+			case t.Name.Space == odsTextNamespace && t.Name.Local == "p":
+				paragraphDepth--
+			}
+		}
+	}
+}
+
+// odsAttr returns the value of an attribute in the given namespace, or "".
+func odsAttr(t xml.StartElement, space string, local string) string {
+	for _, attr := range t.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns a table repetition attribute, defaulting to 1.
+func odsRepeat(t xml.StartElement, local string) int {
+	repeat, err := strconv.Atoi(odsAttr(t, odsTableNamespace, local))
+	if err != nil || repeat < 1 {
+		return 1
+	}
+	return repeat
+}
+
+// odsCellValue returns the value that replaces a cell's displayed text: dates are rendered as MM/DD/YYYY,
+// so they parse the same whatever the display format of the sheet's locale. Other cells keep their text.
+func odsCellValue(t xml.StartElement) string {
+	if odsAttr(t, odsOfficeNamespace, "value-type") != "date" {
+		return ""
+	}
+	value := odsAttr(t, odsOfficeNamespace, "date-value")
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05", "2006-01-02"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return formatCellTime(date)
+		}
+	}
+	return ""
+}
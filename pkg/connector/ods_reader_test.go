@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenOdsWorkbook(t *testing.T) {
+	// testdata/users.ods holds repeated rows and cells, date cells displayed in other formats, an annotation,
+	// compressed spaces, a multi-paragraph cell, a million trailing blank rows and an empty sheet
+	wb, err := openOdsWorkbook("testdata/users.ods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gridSheet{
+		{name: "users", cells: [][]string{
+			{"Name", "Display Name", "Last Login", "Status"},
+			{"alice", "Alice  Smith", "01/01/2024 12:30:00", "enabled", "enabled"},
+			{"bob", "", "03/15/2023"},
+			{"bob", "", "03/15/2023"},
+			nil,
+			nil,
+			nil,
+			{"carol\nsecond line", "", "", "", "3"},
+		}},
+		{name: "empty"},
+	}
+	if !reflect.DeepEqual(wb.sheets, want) {
+		t.Errorf("sheets = %q, want %q", wb.sheets, want)
+	}
+}
+
+func TestParseOdsContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  [][]string
+	}{
+		{
+			name: "repeated rows",
+			table: `<table:table-row table:number-rows-repeated="3"><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>
+				<table:table-row><table:table-cell><text:p>y</text:p></table:table-cell></table:table-row>`,
+			want: [][]string{{"x"}, {"x"}, {"x"}, {"y"}},
+		},
+		{
+			name: "repeated blank rows between and after rows",
+			table: `<table:table-row><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>
+				<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="5"/></table:table-row>
+				<table:table-row><table:table-cell><text:p>y</text:p></table:table-cell></table:table-row>
+				<table:table-row table:number-rows-repeated="1048573"><table:table-cell/></table:table-row>`,
+			want: [][]string{{"x"}, nil, nil, {"y"}},
+		},
+		{
+			name: "repeated cells",
+			table: `<table:table-row>
+				<table:table-cell table:number-columns-repeated="2"><text:p>x</text:p></table:table-cell>
+				<table:table-cell table:number-columns-repeated="3"/>
+				<table:table-cell><text:p>y</text:p></table:table-cell>
+				<table:table-cell table:number-columns-repeated="16378"/>
+			</table:table-row>`,
+			want: [][]string{{"x", "x", "", "", "", "y"}},
+		},
+		{
+			name: "covered cells of merged ranges",
+			table: `<table:table-row>
+				<table:table-cell table:number-columns-spanned="2"><text:p>x</text:p></table:table-cell>
+				<table:covered-table-cell/>
+				<table:table-cell><text:p>y</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"x", "", "y"}},
+		},
+		{
+			name: "dates",
+			table: `<table:table-row>
+				<table:table-cell office:value-type="date" office:date-value="2024-02-29"><text:p>29.02.24</text:p></table:table-cell>
+				<table:table-cell office:value-type="date" office:date-value="2024-02-29T08:15:00"><text:p>Feb 29</text:p></table:table-cell>
+				<table:table-cell office:value-type="date" office:date-value="2024-02-29T08:15:00.250"><text:p>08:15</text:p></table:table-cell>
+				<table:table-cell office:value-type="date" office:date-value="soon"><text:p>soon</text:p></table:table-cell>
+				<table:table-cell office:value-type="float" office:value="45351"><text:p>45351</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"02/29/2024", "02/29/2024 08:15:00", "02/29/2024 08:15:00", "soon", "45351"}},
+		},
+		{
+			name: "text content",
+			table: `<table:table-row>
+				<table:table-cell><text:p>a<text:s/>b<text:s text:c="3"/>c<text:tab/>d</text:p></table:table-cell>
+				<table:table-cell><text:p>line<text:line-break/>break</text:p><text:p><text:span>second</text:span></text:p></table:table-cell>
+				<table:table-cell><office:annotation><text:p>note</text:p></office:annotation><text:p>kept</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"a b   c\td", "line\nbreak\nsecond", "kept"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `<office:document-content xmlns:office="` + odsOfficeNamespace + `" xmlns:table="` + odsTableNamespace +
+				`" xmlns:text="` + odsTextNamespace + `"><office:body><office:spreadsheet><table:table table:name="s">` +
+				tt.table + `</table:table></office:spreadsheet></office:body></office:document-content>`
+			sheets, err := parseOdsContent(strings.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sheets) != 1 || !reflect.DeepEqual(sheets[0].cells, tt.want) {
+				t.Errorf("sheets = %q, want one sheet with cells %q", sheets, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// The Create method adds a resource of the syncer's type to the input file.
+// It implements the Create method, required by the connectorbuilder.ResourceManager interface.
+// The resource is named after its ID, or its display name when no ID is given, and takes its resource function
+// from the existing resources of the same type. Its parent, if any, must exist in the file.
+// Users are created through account provisioning instead.
+func (fs *fileSyncer) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	if resourceTypeHasTrait(fs.resourceType, v2.ResourceType_TRAIT_USER) {
+		return nil, nil, fmt.Errorf("Create: resources of type %s are users and are created through account provisioning", fs.resourceType.Id)
+	}
+
+	name := strings.TrimSpace(resource.GetId().GetResource())
+	if name == "" {
+		name = strings.TrimSpace(resource.GetDisplayName())
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("Create: resource ID or display name is required")
+	}
+	displayName := resource.GetDisplayName()
+	if displayName == "" {
+		displayName = name
+	}
+	parentName := resource.GetParentResourceId().GetResource()
+
+	var applied *LoadedData
+	change := fileChange{
+		operation: "create resource " + name,
+		resource:  fs.resourceType.Id + ":" + name,
+		apply: func(data *LoadedData) error {
+			applied = data
+			if recordExists(data, name) {
+				return fmt.Errorf("a user or resource named %q already exists", name)
+			}
+			resourceFunction := ""
+			for _, r := range data.Resources {
+				if strings.EqualFold(r.ResourceType, fs.resourceType.Id) {
+					resourceFunction = r.ResourceFunction
+					break
+				}
+			}
+			if resourceFunction == "" {
+				return fmt.Errorf("resource type %s is not defined in the file", fs.resourceType.Id)
+			}
+			if parentName != "" && !recordExists(data, parentName) {
+				return fmt.Errorf("parent resource %q not found", parentName)
+			}
+
+			data.Resources = append(data.Resources, ResourceData{
+				ResourceType:     fs.resourceType.Id,
+				ResourceFunction: resourceFunction,
+				Name:             name,
+				DisplayName:      displayName,
+				Description:      resource.GetDescription(),
+				ParentResource:   parentName,
+			})
+			return nil
+		},
+	}
+	if err := fs.store.update(ctx, change); err != nil {
+		return nil, nil, fmt.Errorf("Create: %w", err)
+	}
+
+	created, err := fs.store.writtenResource(ctx, name, applied)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Create: %w", err)
+	}
+	return created, nil, nil
+}
+
+// The Delete method removes a resource of the syncer's type from the input file.
+// It implements the Delete method, required by the connectorbuilder.ResourceDeleter interface.
+// The resource's entitlements are removed with it, as are the grants it receives and the grants of its entitlements.
+// Resources that are the parent or owner of other resources cannot be deleted. Deleting a missing resource succeeds.
+func (fs *fileSyncer) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	if resourceId.GetResourceType() != fs.resourceType.Id {
+		return nil, fmt.Errorf("Delete: resource type %s does not match syncer type %s", resourceId.GetResourceType(), fs.resourceType.Id)
+	}
+	name := resourceId.GetResource()
+
+	found := false
+	change := fileChange{
+		operation: "delete resource " + name,
+		resource:  fs.resourceType.Id + ":" + name,
+		apply: func(data *LoadedData) error {
+			for _, r := range data.Resources {
+				if r.ParentResource == name {
+					return fmt.Errorf("resource %q is the parent of %q", name, r.Name)
+				}
+				if r.Owner == name {
+					return fmt.Errorf("resource %q is the owner of %q", name, r.Name)
+				}
+			}
+
+			found = deleteRecord(data, fs.resourceType.Id, name)
+			if !found {
+				return errNoChange
+			}
+			return nil
+		},
+	}
+	if err := fs.store.update(ctx, change); err != nil {
+		return nil, fmt.Errorf("Delete: %w", err)
+	}
+	if !found {
+		l.Info("Resource to delete was not found in the file", zap.String("resource_type", fs.resourceType.Id), zap.String("resource", name))
+	}
+	return nil, nil
+}
+
+// recordExists reports whether a user or resource with the given name is defined.
+func recordExists(data *LoadedData, name string) bool {
+	for _, u := range data.Users {
+		if u.Name == name {
+			return true
+		}
+	}
+	for _, r := range data.Resources {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteRecord removes a user or resource of the given type together with its entitlements and every grant referencing it,
+// either as the principal (directly or through one of its entitlements) or as the granted entitlement.
+// It reports whether the record was found.
+func deleteRecord(data *LoadedData, resourceType string, name string) bool {
+	found := false
+	if resourceType == userResourceTypeId {
+		users := data.Users[:0]
+		for _, u := range data.Users {
+			if u.Name == name {
+				found = true
+				continue
+			}
+			users = append(users, u)
+		}
+		data.Users = users
+	} else {
+		resources := data.Resources[:0]
+		for _, r := range data.Resources {
+			if r.Name == name && strings.EqualFold(r.ResourceType, resourceType) {
+				found = true
+				continue
+			}
+			resources = append(resources, r)
+		}
+		data.Resources = resources
+	}
+	if !found {
+		return false
+	}
+
+	// Grants are matched on the exact IDs of the record's entitlements, as other names may start with "<name>:"
+	entitlementIds := make(map[string]struct{})
+	entitlements := data.Entitlements[:0]
+	for _, e := range data.Entitlements {
+		if e.ResourceName == name {
+			entitlementIds[fmt.Sprintf("%s:%s", e.ResourceName, e.Entitlement)] = struct{}{}
+			continue
+		}
+		entitlements = append(entitlements, e)
+	}
+	data.Entitlements = entitlements
+
+	grants := data.Grants[:0]
+	for _, g := range data.Grants {
+		_, principalIsEntitlement := entitlementIds[g.Principal]
+		_, grantsEntitlement := entitlementIds[g.EntitlementId]
+		if g.Principal == name || principalIsEntitlement || grantsEntitlement {
+			continue
+		}
+		grants = append(grants, g)
+	}
+	data.Grants = grants
+	return true
+}
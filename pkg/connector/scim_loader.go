@@ -0,0 +1,366 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// SCIM schema URNs recognized by the SCIM loader.
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema = "urn:ietf:params:scim:schemas:core:2.0:Group"
+)
+
+// Resource type and entitlement slug produced for SCIM groups.
+const (
+	scimGroupResourceType = "group"
+	scimMemberEntitlement = "member"
+)
+
+// The scimResource struct holds the SCIM User and Group attributes used by the SCIM loader.
+type scimResource struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id"`
+	ExternalID string   `json:"externalId"`
+	Meta       struct {
+		ResourceType string `json:"resourceType"`
+	} `json:"meta"`
+
+	// User attributes
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName"`
+	Name        struct {
+		Formatted  string `json:"formatted"`
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	} `json:"name"`
+	Emails []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+	} `json:"emails"`
+	Active     *bool                  `json:"active"`
+	UserType   string                 `json:"userType"`
+	Title      string                 `json:"title"`
+	Enterprise map[string]interface{} `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+
+	// Group attributes
+	Members []struct {
+		Value string `json:"value"`
+		Type  string `json:"type"`
+	} `json:"members"`
+}
+
+// kind classifies a SCIM resource as a user or a group, using its schemas, its meta.resourceType and finally its attributes.
+func (r *scimResource) kind() string {
+	for _, schema := range r.Schemas {
+		switch {
+		case strings.EqualFold(schema, scimUserSchema):
+			return sectionUsers
+		case strings.EqualFold(schema, scimGroupSchema):
+			return scimGroupResourceType
+		}
+	}
+	switch strings.ToLower(r.Meta.ResourceType) {
+	case "user":
+		return sectionUsers
+	case "group":
+		return scimGroupResourceType
+	}
+	if r.UserName != "" {
+		return sectionUsers
+	}
+	if r.DisplayName != "" {
+		return scimGroupResourceType
+	}
+	return ""
+}
+
+// SCIM Loading Logic
+// loadScimData handles the specific logic for reading SCIM 2.0 exports.
+// The input is either one file or a directory whose .json files are all read (e.g. separate /Users and /Groups dumps).
+// Each file may hold a ListResponse, an array of ListResponses or resources, or several such JSON values one after another.
+// Users become users, and Groups become group resources with a "member" entitlement granted to their members.
+func loadScimData(ctx context.Context, inputPath string) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	files, err := scimInputFiles(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*scimResource
+	for _, file := range files {
+		fileResources, err := readScimFile(file)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, fileResources...)
+	}
+
+	loadedData := convertScimResources(ctx, resources)
+	if len(files) > 1 || files[0] != inputPath {
+		loadedData.includedFiles = files
+	}
+	l.Debug("Loaded SCIM export",
+		zap.String("input", inputPath),
+		zap.Int("files", len(files)),
+		zap.Int("users", len(loadedData.Users)),
+		zap.Int("groups", len(loadedData.Resources)),
+	)
+	return loadedData, nil
+}
+
+// scimInputFiles returns the files making up a SCIM export: the input file itself, or the sorted .json files of an input directory.
+func scimInputFiles(inputPath string) ([]string, error) {
+	info, err := os.Stat(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat SCIM input %s: %w", inputPath, err)
+	}
+	if !info.IsDir() {
+		return []string{inputPath}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(inputPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no .json files found in SCIM input directory %s", inputPath)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// readScimFile decodes every JSON value in a SCIM export file and returns the resources they contain.
+func readScimFile(filePath string) ([]*scimResource, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	var resources []*scimResource
+	decoder := json.NewDecoder(f)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal SCIM data from %s: %w", filePath, err)
+		}
+		resources, err = appendScimResources(resources, raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal SCIM data from %s: %w", filePath, err)
+		}
+	}
+}
+
+// appendScimResources appends the resources held by one JSON value: an array, a ListResponse or a single resource.
+func appendScimResources(resources []*scimResource, raw json.RawMessage) ([]*scimResource, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			var err error
+			resources, err = appendScimResources(resources, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return resources, nil
+	}
+
+	var listResponse struct {
+		Resources *[]json.RawMessage `json:"Resources"`
+	}
+	if err := json.Unmarshal(trimmed, &listResponse); err != nil {
+		return nil, err
+	}
+	if listResponse.Resources != nil {
+		for _, item := range *listResponse.Resources {
+			var err error
+			resources, err = appendScimResources(resources, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return resources, nil
+	}
+
+	resource := &scimResource{}
+	if err := json.Unmarshal(trimmed, resource); err != nil {
+		return nil, err
+	}
+	return append(resources, resource), nil
+}
+
+// convertScimResources maps SCIM Users and Groups onto users, group resources, member entitlements and grants.
+// Group members reference users and groups by SCIM id; they are resolved to user names and to the nested group's
+// member entitlement, so that nested group membership is expanded.
+func convertScimResources(ctx context.Context, resources []*scimResource) *LoadedData {
+	l := ctxzap.Extract(ctx)
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+
+	var users, groups []*scimResource
+	for _, r := range resources {
+		switch r.kind() {
+		case sectionUsers:
+			users = append(users, r)
+		case scimGroupResourceType:
+			groups = append(groups, r)
+		default:
+			l.Warn("Skipping SCIM resource that is neither a User nor a Group", zap.String("id", r.ID), zap.Strings("schemas", r.Schemas))
+		}
+	}
+
+	principalsByID := make(map[string]string)
+	for _, u := range users {
+		name := u.UserName
+		if name == "" {
+			name = u.ID
+		}
+		if u.ID != "" {
+			principalsByID[u.ID] = name
+		}
+		loadedData.Users = append(loadedData.Users, scimUserData(name, u))
+	}
+
+	// Groups are named after their displayName unless it is shared by several groups, in which case their id is used.
+	groupNameCount := make(map[string]int)
+	for _, g := range groups {
+		groupNameCount[g.DisplayName]++
+	}
+	groupNames := make([]string, len(groups))
+	for i, g := range groups {
+		name := g.DisplayName
+		if name == "" || groupNameCount[name] > 1 {
+			name = g.ID
+		}
+		groupNames[i] = name
+		if g.ID != "" {
+			principalsByID[g.ID] = name + ":" + scimMemberEntitlement
+		}
+
+		displayName := g.DisplayName
+		if displayName == "" {
+			displayName = name
+		}
+		loadedData.Resources = append(loadedData.Resources, ResourceData{
+			ResourceType:     scimGroupResourceType,
+			ResourceFunction: "group",
+			Name:             name,
+			DisplayName:      displayName,
+		})
+		loadedData.Entitlements = append(loadedData.Entitlements, EntitlementData{
+			ResourceName: name,
+			Entitlement:  scimMemberEntitlement,
+			DisplayName:  "Member",
+			Description:  fmt.Sprintf("Member of the %s group", displayName),
+		})
+	}
+
+	for i, g := range groups {
+		entitlementId := groupNames[i] + ":" + scimMemberEntitlement
+		for _, m := range g.Members {
+			principal, ok := principalsByID[m.Value]
+			if !ok {
+				// Members missing from the export are reported when grants are resolved.
+				principal = m.Value
+			}
+			if principal == "" || principal == entitlementId {
+				continue
+			}
+			loadedData.Grants = append(loadedData.Grants, GrantData{Principal: principal, EntitlementId: entitlementId})
+		}
+	}
+
+	return loadedData
+}
+
+// scimUserData maps a SCIM User onto UserData.
+// The id, externalId, userType, title and all enterprise extension attributes are stored in the profile;
+// the enterprise manager is flattened into manager (its value) and manager_display_name.
+func scimUserData(name string, u *scimResource) UserData {
+	displayName := u.DisplayName
+	if displayName == "" {
+		displayName = u.Name.Formatted
+	}
+	if displayName == "" {
+		displayName = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	if displayName == "" {
+		displayName = name
+	}
+
+	email := ""
+	for _, e := range u.Emails {
+		if e.Primary {
+			email = e.Value
+			break
+		}
+	}
+	if email == "" && len(u.Emails) > 0 {
+		email = u.Emails[0].Value
+	}
+
+	status := ""
+	if u.Active != nil {
+		status = "disabled"
+		if *u.Active {
+			status = "enabled"
+		}
+	}
+
+	profile := make(map[string]interface{})
+	for key, value := range map[string]string{"id": u.ID, "externalId": u.ExternalID, "userType": u.UserType, "title": u.Title} {
+		if value != "" {
+			profile[key] = value
+		}
+	}
+	for key, value := range u.Enterprise {
+		if key != "manager" {
+			profile[key] = value
+			continue
+		}
+		manager, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := manager["value"].(string); ok && v != "" {
+			profile["manager"] = v
+		}
+		if v, ok := manager["displayName"].(string); ok && v != "" {
+			profile["manager_display_name"] = v
+		}
+	}
+
+	return UserData{
+		Name:        name,
+		DisplayName: displayName,
+		Email:       email,
+		Status:      status,
+		Profile:     profile,
+	}
+}
@@ -0,0 +1,198 @@
+package connector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite" // Registers the "sqlite" database/sql driver
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// sqliteDriverName is the database/sql driver registered by github.com/glebarez/go-sqlite.
+const sqliteDriverName = "sqlite"
+
+// SQLite Loading Logic
+// loadSqliteData handles the specific logic for reading SQLite database files.
+// Each section is read from the table (or view) named after it, unless the options configure a SQL query for the section.
+// Result columns are matched like Excel headers, so they carry the YAML key names (or a configured column mapping),
+// and rows are processed by the same sheetConfigs used for Excel workbooks.
+// The database is opened read-only; sections without a table are skipped.
+func loadSqliteData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	dsn, err := sqliteReadOnlyDSN(filePath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", filePath, err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			l.Error("failed to close database", zap.Error(err), zap.String("file", filePath))
+		}
+	}()
+
+	loadedData := &LoadedData{
+		Users:        make([]UserData, 0),
+		Resources:    make([]ResourceData, 0),
+		Entitlements: make([]EntitlementData, 0),
+		Grants:       make([]GrantData, 0),
+	}
+
+	sheetConfigs := newSheetConfigs(ctx, loadedData)
+
+	for _, section := range sectionNames {
+		config := sheetConfigs[section]
+		query := opts.query(section)
+		if query == "" {
+			exists, err := sqliteTableExists(ctx, db, section)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read schema of %s: %w", filePath, err)
+			}
+			if !exists {
+				l.Warn("No table matched section, skipping.", zap.String("section", section))
+				continue
+			}
+			query = fmt.Sprintf("SELECT * FROM %q", section)
+		}
+
+		err := streamQuery(ctx, db, section, query, opts.columnAliases(section), config.fields, config.process)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read section %s from %s: %w", section, filePath, err)
+		}
+	}
+	// Members columns name one principal per row; database values are not split on the multi-value delimiter
+	expandMultiValues(loadedData, "")
+
+	l.Info("Finished loading data from file",
+		zap.Int("user_count", len(loadedData.Users)),
+		zap.Int("resource_count", len(loadedData.Resources)),
+		zap.Int("entitlement_count", len(loadedData.Entitlements)),
+		zap.Int("grant_count", len(loadedData.Grants)),
+	)
+	return loadedData, nil
+}
+
+// sqliteReadOnlyDSN builds a read-only SQLite URI for a database file, so that a missing file is reported instead of created.
+func sqliteReadOnlyDSN(filePath string) (string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", filePath, err)
+	}
+	u := url.URL{Path: filepath.ToSlash(absPath)}
+	return "file:" + u.EscapedPath() + "?mode=ro", nil
+}
+
+// sqliteTableExists reports whether the database has a table or view with the given name (compared case-insensitively, as SQLite does).
+func sqliteTableExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = ? COLLATE NOCASE", name,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// streamQuery runs a section's query and hands each result row to process, mirroring streamSheet.
+// The result column names act as the header row; NULL values become empty cells.
+func streamQuery(
+	ctx context.Context,
+	db *sql.DB,
+	section string,
+	query string,
+	aliases columnAliases,
+	requiredFields []string,
+	process func(rowIndex int, row []string, cols columnIndex),
+) error {
+	l := ctxzap.Extract(ctx)
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			l.Error("failed to close query rows", zap.Error(err), zap.String("section", section))
+		}
+	}()
+
+	headers, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	cols := buildColumnIndex(headers, aliases)
+	for _, reqField := range requiredFields {
+		if _, ok := cols[reqField]; !ok {
+			l.Error("Required column missing in query result, skipping.", zap.String("section", section), zap.String("missing_field", reqField))
+			return nil
+		}
+	}
+
+	values := make([]interface{}, len(headers))
+	scanArgs := make([]interface{}, len(headers))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	dataRows := 0
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return fmt.Errorf("failed to read row %d: %w", dataRows+1, err)
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = sqliteValueString(v)
+		}
+
+		dataRows++
+		if !isBlankRow(row) {
+			process(dataRows, row, cols)
+		}
+		if dataRows%excelProgressInterval == 0 {
+			l.Info("Loading query rows", zap.String("section", section), zap.Int("rows_processed", dataRows))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if dataRows == 0 {
+		l.Warn("Query returned no rows, skipping.", zap.String("section", section))
+	} else {
+		l.Debug("Finished loading query", zap.String("section", section), zap.Int("rows_processed", dataRows))
+	}
+	return nil
+}
+
+// sqliteValueString renders a value returned by the SQLite driver as cell text.
+func sqliteValueString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case time.Time:
+		return formatCellTime(value)
+	default:
+		return strings.TrimSpace(fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,280 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// recordKindKey is the key that tags a streamed record with the section it belongs to.
+const recordKindKey = "kind"
+
+// streamProgressInterval is the number of records between progress log entries while streaming NDJSON or YAML documents.
+const streamProgressInterval = 10000
+
+// recordKinds maps the accepted values of the kind key to their section.
+// Both the singular record name and the section name are accepted.
+var recordKinds = map[string]string{
+	"user":              sectionUsers,
+	sectionUsers:        sectionUsers,
+	"resource":          sectionResources,
+	sectionResources:    sectionResources,
+	"entitlement":       sectionEntitlements,
+	sectionEntitlements: sectionEntitlements,
+	"grant":             sectionGrants,
+	sectionGrants:       sectionGrants,
+}
+
+// The recordDecoder struct decodes individual section-tagged records and appends them to a LoadedData.
+// The column aliases of each section are resolved once and reused for every record.
+type recordDecoder struct {
+	data    *LoadedData
+	aliases map[string]columnAliases
+	count   int
+}
+
+// newRecordDecoder creates a recordDecoder that appends to empty LoadedData.
+func newRecordDecoder(opts *LoadOptions) *recordDecoder {
+	aliases := make(map[string]columnAliases, len(sectionNames))
+	for _, section := range sectionNames {
+		aliases[section] = opts.columnAliases(section)
+	}
+	return &recordDecoder{
+		data: &LoadedData{
+			Users:        make([]UserData, 0),
+			Resources:    make([]ResourceData, 0),
+			Entitlements: make([]EntitlementData, 0),
+			Grants:       make([]GrantData, 0),
+		},
+		aliases: aliases,
+	}
+}
+
+// decode canonicalizes one record mapping node of the given kind and appends it to the LoadedData.
+// The kind key itself must already have been removed from the record.
+// Resource records may use the nested shape, declaring their entitlements and child resources inline.
+func (d *recordDecoder) decode(kind string, record *yaml.Node) error {
+	section, ok := recordKinds[strings.ToLower(strings.TrimSpace(kind))]
+	if !ok {
+		return fmt.Errorf("unknown record kind %q (expected one of user, resource, entitlement, grant)", kind)
+	}
+	if record.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s record must be an object", kind)
+	}
+
+	switch section {
+	case sectionUsers:
+		canonicalizeRecord(record, d.aliases[sectionUsers])
+		var userData UserData
+		if err := record.Decode(&userData); err != nil {
+			return err
+		}
+		d.data.Users = append(d.data.Users, userData)
+
+	case sectionResources:
+		items := &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{record}}
+		canonicalizeResources(items, d.aliases[sectionResources], d.aliases[sectionEntitlements])
+		var resourceData nestedResourceData
+		if err := record.Decode(&resourceData); err != nil {
+			return err
+		}
+		flattenResources(d.data, []nestedResourceData{resourceData}, "")
+
+	case sectionEntitlements:
+		canonicalizeRecord(record, d.aliases[sectionEntitlements])
+		var entitlementData EntitlementData
+		if err := record.Decode(&entitlementData); err != nil {
+			return err
+		}
+		d.data.Entitlements = append(d.data.Entitlements, entitlementData)
+
+	case sectionGrants:
+		canonicalizeRecord(record, d.aliases[sectionGrants])
+		var grantData GrantData
+		if err := record.Decode(&grantData); err != nil {
+			return err
+		}
+		d.data.Grants = append(d.data.Grants, grantData)
+	}
+	d.count++
+	return nil
+}
+
+// appendDocument adds the lists decoded from a whole document to the LoadedData.
+func (d *recordDecoder) appendDocument(docData *LoadedData) {
+	d.data.Users = append(d.data.Users, docData.Users...)
+	d.data.Resources = append(d.data.Resources, docData.Resources...)
+	d.data.Entitlements = append(d.data.Entitlements, docData.Entitlements...)
+	d.data.Grants = append(d.data.Grants, docData.Grants...)
+	d.count += len(docData.Users) + len(docData.Resources) + len(docData.Entitlements) + len(docData.Grants)
+}
+
+// NDJSON Loading Logic
+// loadNdjsonData handles the specific logic for reading and parsing .ndjson or .jsonl files.
+// Every non-blank line holds one JSON object tagged with its section through the "kind" key, e.g. {"kind":"grant", ...}.
+// Lines are decoded one at a time, so only the current line is held in memory in addition to the typed structs produced so far.
+func loadNdjsonData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			l.Error("failed to close file", zap.Error(err), zap.String("file", filePath))
+		}
+	}()
+
+	decoder := newRecordDecoder(opts)
+	reader := bufio.NewReader(f)
+	for lineNumber := 1; ; lineNumber++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return nil, fmt.Errorf("failed to read %s: %w", filePath, readErr)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			err = decodeNdjsonLine(decoder, line)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", filePath, lineNumber, err)
+			}
+			if decoder.count%streamProgressInterval == 0 {
+				l.Info("Streaming records", zap.String("file", filePath), zap.Int("records_read", decoder.count))
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+
+	l.Debug("Finished streaming records", zap.String("file", filePath), zap.Int("records_read", decoder.count))
+	expandMultiValues(decoder.data, opts.delimiter())
+	return decoder.data, nil
+}
+
+// decodeNdjsonLine parses one NDJSON line and passes the record to the decoder.
+// The JSON object is converted to a YAML node so that it shares the key normalization used for YAML and JSON files.
+// Numbers keep their JSON text, so large IDs are not rounded to float64 on the way.
+func decodeNdjsonLine(decoder *recordDecoder, line []byte) error {
+	var record map[string]interface{}
+	jsonDecoder := json.NewDecoder(bytes.NewReader(line))
+	jsonDecoder.UseNumber()
+	err := jsonDecoder.Decode(&record)
+	if err == nil && jsonDecoder.Decode(new(json.RawMessage)) != io.EOF {
+		err = errors.New("unexpected data after the JSON object")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON record: %w", err)
+	}
+	numberNodes(record)
+
+	kind, ok := record[recordKindKey].(string)
+	if !ok || kind == "" {
+		return fmt.Errorf("record is missing the %q key", recordKindKey)
+	}
+	delete(record, recordKindKey)
+
+	var node yaml.Node
+	err = node.Encode(record)
+	if err != nil {
+		return fmt.Errorf("failed to convert JSON record: %w", err)
+	}
+	return decoder.decode(kind, &node)
+}
+
+// numberNodes replaces the json.Number values in a decoded JSON value with YAML scalar nodes holding the same text,
+// tagged as integers or floats, so encoding the value to YAML does not turn them into quoted strings.
+func numberNodes(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = numberNodes(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numberNodes(item)
+		}
+	}
+	return value
+}
+
+// YAML Loading Logic
+// loadYamlData handles the specific logic for reading and parsing .yaml or .yml files.
+// The file may contain several documents separated by "---"; they are decoded one at a time and each adds to the result.
+// A document is either a regular document with users/resources/entitlements/grants keys, or a single record tagged with a "kind" key.
+// Includes (!include tags and $include keys) are resolved relative to the including file and ${ENV_VAR} references are expanded before decoding.
+func loadYamlData(ctx context.Context, filePath string, opts *LoadOptions) (*LoadedData, error) {
+	l := ctxzap.Extract(ctx)
+
+	decoder := newRecordDecoder(opts)
+	preprocessor := newYamlPreprocessor()
+	documents := 0
+	err := preprocessor.parseStream(filePath, func(doc *yaml.Node) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		documents++
+
+		root := doc
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		if kind := mappingValue(root, recordKindKey); root.Kind == yaml.MappingNode && kind != nil {
+			record := &yaml.Node{Kind: yaml.MappingNode, Tag: root.Tag, Line: root.Line}
+			for i := 0; i+1 < len(root.Content); i += 2 {
+				if root.Content[i].Value != recordKindKey {
+					record.Content = append(record.Content, root.Content[i], root.Content[i+1])
+				}
+			}
+			err := decoder.decode(kind.Value, record)
+			if err != nil {
+				return fmt.Errorf("document %d (line %d): %w", documents, root.Line, err)
+			}
+			return nil
+		}
+
+		docData, err := decodeDocument(doc, opts)
+		if err != nil {
+			return fmt.Errorf("failed to decode YAML document %d: %w", documents, err)
+		}
+		decoder.appendDocument(docData)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(preprocessor.unresolved) > 0 {
+		l.Warn("YAML file references unset environment variables without a default; the references are kept as written",
+			zap.String("file", filePath), zap.Strings("variables", preprocessor.unresolved))
+	}
+	if documents > 1 {
+		l.Debug("Loaded multi-document YAML file", zap.String("file", filePath), zap.Int("documents", documents), zap.Int("records_read", decoder.count))
+	}
+	decoder.data.includedFiles = preprocessor.included
+	expandMultiValues(decoder.data, opts.delimiter())
+	return decoder.data, nil
+}
@@ -0,0 +1,35 @@
+package connector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLoadNdjsonKeepsNumbers(t *testing.T) {
+	filePath := writeTestFile(t, "access.ndjson", `{"kind": "user", "name": 9007199254740993, "profile": {"employee_id": 12345678901234567, "level": 2.50, "tags": [1, 1e3]}}
+{"kind": "resource", "resource_type": "team", "resource_function": "group", "name": 1234567890123456789}
+`)
+	data, err := LoadFileData(context.Background(), filePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Users) != 1 || len(data.Resources) != 1 {
+		t.Fatalf("loaded %d users and %d resources, want one of each", len(data.Users), len(data.Resources))
+	}
+	if got := data.Users[0].Name; got != "9007199254740993" {
+		t.Errorf("user name = %q, want the number's exact text", got)
+	}
+	if got := data.Resources[0].Name; got != "1234567890123456789" {
+		t.Errorf("resource name = %q, want the number's exact text", got)
+	}
+	want := map[string]interface{}{"employee_id": 12345678901234567, "level": 2.5, "tags": []interface{}{1, 1000.0}}
+	if got := data.Users[0].Profile; !reflect.DeepEqual(got, want) {
+		t.Errorf("profile = %#v, want %#v", got, want)
+	}
+
+	badPath := writeTestFile(t, "bad.ndjson", `{"kind": "user", "name": "alice"} {"kind": "user"}`+"\n")
+	if _, err := LoadFileData(context.Background(), badPath, nil); err == nil {
+		t.Error("loading a line with two JSON objects succeeded")
+	}
+}
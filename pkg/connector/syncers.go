@@ -3,300 +3,121 @@ package connector
 import (
 	"context"
 	"fmt"
-	"sort"
 	"strconv"
-	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
-	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
 )
 
 // fileSyncer implements the ResourceSyncer interface for a specific resource type.
-// It holds a reference to the resource type it handles and the path to the data file.
-// Data loading and caching is performed within each interface method call (List, Entitlements, Grants).
+// It holds a reference to the resource type it handles, the shared data store and the configured page sizes.
+// Data is loaded through the data store, which only re-parses the file when it changes.
 type fileSyncer struct {
-	resourceType  *v2.ResourceType
-	inputFilePath string
+	resourceType *v2.ResourceType
+	store        *dataStore
+	pageSizes    PageSizes
 }
 
 // newFileSyncer creates a new fileSyncer instance.
-func newFileSyncer(rt *v2.ResourceType, filePath string) *fileSyncer {
+func newFileSyncer(rt *v2.ResourceType, store *dataStore, pageSizes PageSizes) *fileSyncer {
 	return &fileSyncer{
-		resourceType:  rt,
-		inputFilePath: filePath,
+		resourceType: rt,
+		store:        store,
+		pageSizes:    pageSizes,
 	}
 }
 
+// readOnlySyncer exposes only the sync methods of a syncer, for input formats that changes cannot be written back to.
+// The SDK detects grant, resource, account and credential provisioning from the methods a syncer implements, so the methods
+// of fileSyncer that write to the file are hidden rather than failing on every call.
+type readOnlySyncer struct {
+	connectorbuilder.ResourceSyncer
+}
+
 // The ResourceType method returns the resource type definition handled by this syncer.
 // It implements the ResourceType method, required by the connectorbuilder.ResourceSyncer interface.
 // The connectorbuilder.ResourceSyncer interface uses this to associate the syncer with its definition.
 // Which allows the SDK sync engine to know which resource type this syncer manages.
-// The implementation directly returns the stored v2.ResourceType passed during initialization.
+// The implementation returns the stored v2.ResourceType passed during initialization. In git-backed mode it is annotated
+// with the git state of the input file, so the c1z file of each sync records the commit it read.
 func (fs *fileSyncer) ResourceType(ctx context.Context) *v2.ResourceType {
-	return fs.resourceType
+	if fs.store.git == nil {
+		return fs.resourceType
+	}
+	snapshot, err := fs.store.load(ctx)
+	if err != nil {
+		// The sync fails on the same error once it lists resources
+		ctxzap.Extract(ctx).Warn("Failed to load input file to annotate resource type with its git state",
+			zap.String("resource_type", fs.resourceType.Id), zap.Error(err))
+		return fs.resourceType
+	}
+	return snapshot.annotatedResourceType(fs.resourceType)
 }
 
 // The List method retrieves a paginated list of resources for the syncer's type.
 // It implements the List method, required by the connectorbuilder.ResourceSyncer interface.
-// It loads data, builds resource/type caches, filters for the relevant type, and returns paginated results.
+// It loads the data snapshot, looks up the resources of the relevant type under the given parent, and returns paginated results.
 func (fs *fileSyncer) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	loadedData, err := LoadFileData(fs.inputFilePath)
+	snapshot, err := fs.store.load(ctx)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("List: failed to load data file: %w", err)
+		return nil, "", nil, fmt.Errorf("List: %w", err)
 	}
 
-	resourceTypesCache, err := buildResourceTypeCache(ctx, loadedData.Resources, loadedData.Users)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("List: failed to build resource type cache: %w", err)
+	key := listKey{resourceType: fs.resourceType.Id}
+	if parentResourceID != nil {
+		key.parent = newResourceKey(parentResourceID)
 	}
-	resourceCache, err := buildResourceCache(ctx, loadedData.Users, loadedData.Resources, resourceTypesCache)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("List: failed to build resource cache: %w", err)
-	}
-
-	matchingResources := make([]*v2.Resource, 0)
-	for _, res := range resourceCache {
-		if res.Id.ResourceType != fs.resourceType.Id {
-			continue
-		}
-		if parentResourceID != nil {
-			if res.ParentResourceId == nil || res.ParentResourceId.ResourceType != parentResourceID.ResourceType || res.ParentResourceId.Resource != parentResourceID.Resource {
-				continue
-			}
-		} else {
-			if res.ParentResourceId != nil {
-				continue
-			}
-		}
-		matchingResources = append(matchingResources, res)
-	}
-
-	sort.SliceStable(matchingResources, func(i, j int) bool {
-		return matchingResources[i].Id.Resource < matchingResources[j].Id.Resource
-	})
 
-	pageSize := 50
-	bag := &pagination.Bag{}
-	err = bag.Unmarshal(pToken.Token)
+	rv, nextPageToken, err := paginate(snapshot.resourcesByKey[key], fs.pageSizes.Resources, pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to unmarshal pagination token: %w", err)
-	}
-
-	pageToken := bag.PageToken()
-	pageOffset := 0
-	if pageToken != "" {
-		pageOffset, err = strconv.Atoi(pageToken)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to parse page token offset: %w", err)
-		}
+		return nil, "", nil, err
 	}
-
-	start := pageOffset
-	end := start + pageSize
-	if start >= len(matchingResources) {
-		return nil, "", nil, nil
-	}
-	if end > len(matchingResources) {
-		end = len(matchingResources)
-	}
-
-	rv := matchingResources[start:end]
-
-	for _, resource := range rv {
-		childTypes := make(map[string]struct{})
-		for _, possibleChild := range loadedData.Resources {
-			if possibleChild.ParentResource == resource.Id.Resource {
-				childTypeId := strings.ToLower(possibleChild.ResourceType)
-				childTypes[childTypeId] = struct{}{}
-			}
-		}
-
-		if len(childTypes) > 0 {
-			annos := annotations.Annotations(resource.Annotations)
-			for childTypeId := range childTypes {
-				childAnno := &v2.ChildResourceType{ResourceTypeId: childTypeId}
-				annos.Append(childAnno)
-			}
-			resource.Annotations = annos
-		}
-	}
-
-	nextPageToken := ""
-	if end < len(matchingResources) {
-		nextPageToken, err = bag.NextToken(strconv.Itoa(end))
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to marshal next page token: %w", err)
-		}
-	}
-
 	return rv, nextPageToken, nil, nil
 }
 
 // The Entitlements method retrieves a paginated list of entitlements for the syncer's type.
 // It implements the Entitlements method, required by the connectorbuilder.ResourceSyncer interface.
-// It loads data, builds resource/entitlement caches, filters for the relevant resource, and returns paginated results.
+// It loads the data snapshot, looks up the entitlements defined on the resource, and returns paginated results.
 func (fs *fileSyncer) Entitlements(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	loadedData, err := LoadFileData(fs.inputFilePath)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("Entitlements: failed to load data file: %w", err)
-	}
-
-	resourceTypesCache, err := buildResourceTypeCache(ctx, loadedData.Resources, loadedData.Users)
+	snapshot, err := fs.store.load(ctx)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("Entitlements: failed to build resource type cache: %w", err)
-	}
-	resourceCache, err := buildResourceCache(ctx, loadedData.Users, loadedData.Resources, resourceTypesCache)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("Entitlements: failed to build resource cache: %w", err)
-	}
-	entitlementCache, err := buildEntitlementCache(ctx, loadedData.Entitlements, resourceCache)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("Entitlements: failed to build entitlement cache: %w", err)
-	}
-
-	matchingEntitlements := make([]*v2.Entitlement, 0)
-	for _, ent := range entitlementCache {
-		if ent.Resource.Id.ResourceType == resource.Id.ResourceType && ent.Resource.Id.Resource == resource.Id.Resource {
-			matchingEntitlements = append(matchingEntitlements, ent)
-		}
+		return nil, "", nil, fmt.Errorf("Entitlements: %w", err)
 	}
 
-	sort.SliceStable(matchingEntitlements, func(i, j int) bool {
-		return matchingEntitlements[i].Slug < matchingEntitlements[j].Slug
-	})
-
-	pageSize := 50
-	bag := &pagination.Bag{}
-	err = bag.Unmarshal(pToken.Token)
+	rv, nextPageToken, err := paginate(snapshot.entsByResource[newResourceKey(resource.Id)], fs.pageSizes.Entitlements, pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to unmarshal pagination token: %w", err)
+		return nil, "", nil, err
 	}
-
-	pageToken := bag.PageToken()
-	pageOffset := 0
-	if pageToken != "" {
-		pageOffset, err = strconv.Atoi(pageToken)
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to parse page token offset: %w", err)
-		}
-	}
-
-	start := pageOffset
-	end := start + pageSize
-	if start >= len(matchingEntitlements) {
-		return nil, "", nil, nil
-	}
-	if end > len(matchingEntitlements) {
-		end = len(matchingEntitlements)
-	}
-
-	rv := matchingEntitlements[start:end]
-
-	nextPageToken := ""
-	if end < len(matchingEntitlements) {
-		nextPageToken, err = bag.NextToken(strconv.Itoa(end))
-		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to marshal next page token: %w", err)
-		}
-	}
-
 	return rv, nextPageToken, nil, nil
 }
 
 // The Grants method retrieves a paginated list of grants for the syncer's type.
 // It implements the Grants method, required by the connectorbuilder.ResourceSyncer interface.
-// It loads data, builds all caches, filters grants based on the resource context, and returns paginated results.
+// It loads the data snapshot, looks up the grants indexed under the resource (as principal or target), and returns paginated results.
 func (fs *fileSyncer) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	l := ctxzap.Extract(ctx)
-
-	loadedData, err := LoadFileData(fs.inputFilePath)
+	snapshot, err := fs.store.load(ctx)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("Grants: failed to load data file: %w", err)
+		return nil, "", nil, fmt.Errorf("Grants: %w", err)
 	}
 
-	resourceTypesCache, err := buildResourceTypeCache(ctx, loadedData.Resources, loadedData.Users)
+	rv, nextPageToken, err := paginate(snapshot.grantsByRes[newResourceKey(resource.Id)], fs.pageSizes.Grants, pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("Grants: failed to build resource type cache: %w", err)
+		return nil, "", nil, err
 	}
-	resourceCache, err := buildResourceCache(ctx, loadedData.Users, loadedData.Resources, resourceTypesCache)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("Grants: failed to build resource cache: %w", err)
-	}
-	entitlementCache, err := buildEntitlementCache(ctx, loadedData.Entitlements, resourceCache)
-	if err != nil {
-		return nil, "", nil, fmt.Errorf("Grants: failed to build entitlement cache: %w", err)
-	}
-
-	matchingGrants := make([]*v2.Grant, 0)
-
-	for i, grantInfo := range loadedData.Grants {
-		principalIdentifier := grantInfo.Principal
-		entitlementIdentifier := grantInfo.EntitlementId
-
-		var principalResource *v2.Resource
-		if res, ok := resourceCache[principalIdentifier]; ok {
-			principalResource = res
-		} else {
-			if ent, ok := entitlementCache[principalIdentifier]; ok {
-				principalResource = ent.Resource
-			} else {
-				l.Warn("Skipping grant: principal resource not found", zap.String("principal_identifier", principalIdentifier), zap.Int("grant_data_index", i))
-				continue
-			}
-		}
-		principalIdProto := principalResource.Id
-
-		targetEntitlement, ok := entitlementCache[entitlementIdentifier]
-		if !ok {
-			l.Warn("Skipping grant because target entitlement not found in local cache",
-				zap.String("entitlement_id", entitlementIdentifier),
-				zap.Int("grant_data_index", i),
-			)
-			continue
-		}
-
-		grantMatchesContext := (principalIdProto.ResourceType == resource.Id.ResourceType && principalIdProto.Resource == resource.Id.Resource) ||
-			(targetEntitlement.Resource.Id.ResourceType == resource.Id.ResourceType && targetEntitlement.Resource.Id.Resource == resource.Id.Resource)
-
-		if !grantMatchesContext {
-			continue
-		}
-
-		grantOptions := []grant.GrantOption{}
-		principalResourceType, rtOk := resourceTypesCache[principalIdProto.ResourceType]
-		if rtOk {
-			isUserOrApp := resourceTypeHasTrait(principalResourceType, v2.ResourceType_TRAIT_USER) || resourceTypeHasTrait(principalResourceType, v2.ResourceType_TRAIT_APP)
-			_, principalWasEntitlementKey := entitlementCache[principalIdentifier]
-
-			if !isUserOrApp && principalWasEntitlementKey {
-				membershipEntitlement := entitlementCache[principalIdentifier]
-				expandableProto := &v2.GrantExpandable{EntitlementIds: []string{membershipEntitlement.Id}}
-				grantOptions = append(grantOptions, grant.WithAnnotation(expandableProto))
-			}
-		} else {
-			l.Warn("Could not find resource type for principal in local cache, skipping expansion check", zap.String("principal_type", principalIdProto.ResourceType), zap.Int("grant_data_index", i))
-		}
-
-		newGrant := grant.NewGrant(targetEntitlement.Resource, targetEntitlement.Slug, principalIdProto, grantOptions...)
-		matchingGrants = append(matchingGrants, newGrant)
-	}
-
-	sort.SliceStable(matchingGrants, func(i, j int) bool {
-		if matchingGrants[i].Principal.Id.String() != matchingGrants[j].Principal.Id.String() {
-			return matchingGrants[i].Principal.Id.String() < matchingGrants[j].Principal.Id.String()
-		}
-		return matchingGrants[i].Entitlement.Id < matchingGrants[j].Entitlement.Id
-	})
+	return rv, nextPageToken, nil, nil
+}
 
-	pageSize := 50
+// paginate returns the page of items selected by the pagination token and the token for the next page.
+// The page token holds the offset of the first item in the page.
+func paginate[T any](items []T, pageSize int, pToken *pagination.Token) ([]T, string, error) {
 	bag := &pagination.Bag{}
-	err = bag.Unmarshal(pToken.Token)
+	err := bag.Unmarshal(pToken.Token)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to unmarshal pagination token: %w", err)
+		return nil, "", fmt.Errorf("failed to unmarshal pagination token: %w", err)
 	}
 
 	pageToken := bag.PageToken()
@@ -304,34 +125,32 @@ func (fs *fileSyncer) Grants(ctx context.Context, resource *v2.Resource, pToken
 	if pageToken != "" {
 		pageOffset, err = strconv.Atoi(pageToken)
 		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to parse page token offset: %w", err)
+			return nil, "", fmt.Errorf("failed to parse page token offset: %w", err)
 		}
 	}
 
 	start := pageOffset
 	end := start + pageSize
-	if start >= len(matchingGrants) {
-		return nil, "", nil, nil
+	if start >= len(items) {
+		return nil, "", nil
 	}
-	if end > len(matchingGrants) {
-		end = len(matchingGrants)
+	if end > len(items) {
+		end = len(items)
 	}
 
-	rv := matchingGrants[start:end]
-
 	nextPageToken := ""
-	if end < len(matchingGrants) {
+	if end < len(items) {
 		nextPageToken, err = bag.NextToken(strconv.Itoa(end))
 		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to marshal next page token: %w", err)
+			return nil, "", fmt.Errorf("failed to marshal next page token: %w", err)
 		}
 	}
 
-	return rv, nextPageToken, nil, nil
+	return items[start:end], nextPageToken, nil
 }
 
 // resourceTypeHasTrait is a helper function to check if a resource type has a specific trait.
-// It is used within the Grants method to determine if a principal is expandable.
+// It is used while indexing grants to determine if a principal is expandable.
 func resourceTypeHasTrait(rt *v2.ResourceType, traitToFind v2.ResourceType_Trait) bool {
 	if rt == nil {
 		return false
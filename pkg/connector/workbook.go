@@ -0,0 +1,159 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// The workbook interface abstracts the spreadsheet file formats read by loadWorkbookData.
+// Implementations list their sheets, iterate the rows of a sheet and, where the format has them, locate named tables.
+type workbook interface {
+	sheetList() []string
+	rows(sheet string) (rowIterator, error)
+	findTable(sheetList []string, src SheetSource) (sheetRegion, error)
+}
+
+// The rowIterator interface walks the rows of one sheet, in the manner of excelize.Rows.
+type rowIterator interface {
+	Next() bool
+	Columns() ([]string, error)
+	Error() error
+	Close() error
+}
+
+// excelWorkbook reads .xlsx workbooks through excelize, streaming their rows.
+type excelWorkbook struct {
+	f *excelize.File
+}
+
+func (w *excelWorkbook) sheetList() []string {
+	return w.f.GetSheetList()
+}
+
+func (w *excelWorkbook) rows(sheet string) (rowIterator, error) {
+	rows, err := w.f.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &excelRows{Rows: rows}, nil
+}
+
+func (w *excelWorkbook) findTable(sheetList []string, src SheetSource) (sheetRegion, error) {
+	return findTableRegion(w.f, sheetList, src)
+}
+
+// excelRows adapts excelize.Rows, whose Columns method takes read options, to rowIterator.
+type excelRows struct {
+	*excelize.Rows
+}
+
+func (r *excelRows) Columns() ([]string, error) {
+	return r.Rows.Columns()
+}
+
+// gridSheet is a sheet whose cell text has been read into memory.
+type gridSheet struct {
+	name  string
+	cells [][]string
+}
+
+// gridWorkbook holds a spreadsheet that was decoded in full, such as an OpenDocument or BIFF (.xls) file.
+type gridWorkbook struct {
+	format string // File format, used in error messages
+	sheets []gridSheet
+}
+
+func (w *gridWorkbook) sheetList() []string {
+	names := make([]string, 0, len(w.sheets))
+	for _, sheet := range w.sheets {
+		names = append(names, sheet.name)
+	}
+	return names
+}
+
+func (w *gridWorkbook) rows(sheet string) (rowIterator, error) {
+	for _, s := range w.sheets {
+		if s.name == sheet {
+			return &gridRows{cells: s.cells, next: -1}, nil
+		}
+	}
+	return nil, fmt.Errorf("sheet %s does not exist", sheet)
+}
+
+func (w *gridWorkbook) findTable(_ []string, src SheetSource) (sheetRegion, error) {
+	return sheetRegion{}, fmt.Errorf("named table %q: named tables are not supported in %s files", src.Table, w.format)
+}
+
+// gridRows iterates the rows of a gridSheet.
+type gridRows struct {
+	cells [][]string
+	next  int
+}
+
+func (r *gridRows) Next() bool {
+	if r.next+1 >= len(r.cells) {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *gridRows) Columns() ([]string, error) {
+	return r.cells[r.next], nil
+}
+
+func (r *gridRows) Error() error { return nil }
+
+func (r *gridRows) Close() error { return nil }
+
+// appendGridCell stores a cell's text at a 0-based column of a row, padding the row with empty cells as needed.
+func appendGridCell(row []string, col int, value string) []string {
+	for len(row) <= col {
+		row = append(row, "")
+	}
+	row[col] = value
+	return row
+}
+
+// trimGridRows drops the trailing empty rows of a sheet, which spreadsheet applications often store explicitly.
+func trimGridRows(cells [][]string) [][]string {
+	for len(cells) > 0 && isBlankRow(cells[len(cells)-1]) {
+		cells = cells[:len(cells)-1]
+	}
+	return cells
+}
+
+// formatCellTime renders a date or timestamp cell in the MM/DD/YYYY layout expected for last_login, adding the time of day when there is one.
+func formatCellTime(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("01/02/2006")
+	}
+	return t.Format("01/02/2006 15:04:05")
+}
+
+// isDateFormatCode reports whether a number format code displays a date or time, ignoring quoted text, escapes and bracketed sections.
+func isDateFormatCode(code string) bool {
+	inQuote, inBracket, escaped := false, false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote:
+			inQuote = r != '"'
+		case inBracket:
+			inBracket = r != ']'
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = true
+		case r == '[':
+			inBracket = true
+		case r == 'd' || r == 'm' || r == 'y' || r == 'h' || r == 's':
+			return true
+		}
+	}
+	return false
+}
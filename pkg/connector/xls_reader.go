@@ -0,0 +1,500 @@
+package connector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// BIFF8 record types read by the .xls reader.
+const (
+	biffBOF        = 0x0809
+	biffEOF        = 0x000A
+	biffContinue   = 0x003C
+	biffFilePass   = 0x002F
+	biffDate1904   = 0x0022
+	biffBoundSheet = 0x0085
+	biffSST        = 0x00FC
+	biffFormat     = 0x041E
+	biffXF         = 0x00E0
+	biffLabelSST   = 0x00FD
+	biffLabel      = 0x0204
+	biffNumber     = 0x0203
+	biffRK         = 0x027E
+	biffMulRK      = 0x00BD
+	biffBoolErr    = 0x0205
+	biffFormula    = 0x0006
+	biffString     = 0x0207
+)
+
+// biffVersion8 is the BOF version of BIFF8 workbooks (Excel 97-2003).
+const biffVersion8 = 0x0600
+
+// biffErrorValues maps BIFF error codes to the text Excel displays for them.
+var biffErrorValues = map[byte]string{
+	0x00: "#NULL!",
+	0x07: "#DIV/0!",
+	0x0F: "#VALUE!",
+	0x17: "#REF!",
+	0x1D: "#NAME?",
+	0x24: "#NUM!",
+	0x2A: "#N/A",
+}
+
+// The biffRecord struct is one record of a BIFF stream, with the data of the CONTINUE records that follow it.
+type biffRecord struct {
+	offset    int
+	typ       uint16
+	data      []byte
+	continues [][]byte
+}
+
+// The xlsReader struct holds the workbook-global state needed to render cells: the shared strings and number formats.
+type xlsReader struct {
+	records     []biffRecord
+	strings     []string
+	formats     map[uint16]string // Custom number format codes by format index
+	xfFormats   []uint16          // Number format index of each XF record
+	date1904    bool
+	boundSheets []xlsBoundSheet
+}
+
+// xlsBoundSheet is a worksheet listed in the workbook globals, with the stream offset of its BOF record.
+type xlsBoundSheet struct {
+	name   string
+	offset int
+}
+
+// openXlsWorkbook reads a legacy Excel 97-2003 workbook (.xls, BIFF8) into memory.
+// String, number, boolean and cached formula values are read; numbers with a date format are rendered as MM/DD/YYYY.
+// Encrypted workbooks and files older than BIFF8 (Excel 95 and earlier) are rejected.
+func openXlsWorkbook(filePath string) (*gridWorkbook, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	stream, err := readXlsWorkbookStream(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workbook %s: %w", filePath, err)
+	}
+
+	r := &xlsReader{formats: make(map[uint16]string)}
+	if err := r.readRecords(stream); err != nil {
+		return nil, fmt.Errorf("failed to read workbook %s: %w", filePath, err)
+	}
+	if err := r.readGlobals(); err != nil {
+		return nil, fmt.Errorf("failed to read workbook %s: %w", filePath, err)
+	}
+
+	wb := &gridWorkbook{format: ".xls"}
+	for _, bs := range r.boundSheets {
+		cells, err := r.readSheet(bs.offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %s of %s: %w", bs.name, filePath, err)
+		}
+		wb.sheets = append(wb.sheets, gridSheet{name: bs.name, cells: cells})
+	}
+	return wb, nil
+}
+
+// readXlsWorkbookStream returns the content of the Workbook stream of an OLE compound document.
+func readXlsWorkbookStream(f *os.File) ([]byte, error) {
+	doc, err := mscfb.New(f)
+	if err != nil {
+		return nil, fmt.Errorf("not an OLE compound document: %w", err)
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		switch entry.Name {
+		case "Workbook":
+			return io.ReadAll(entry)
+		case "Book":
+			return nil, errors.New("workbooks older than Excel 97 (BIFF5) are not supported")
+		}
+	}
+	return nil, errors.New("workbook stream not found")
+}
+
+// readRecords splits a BIFF stream into records, attaching CONTINUE records to the record they extend.
+func (r *xlsReader) readRecords(stream []byte) error {
+	for pos := 0; pos+4 <= len(stream); {
+		typ := binary.LittleEndian.Uint16(stream[pos:])
+		size := int(binary.LittleEndian.Uint16(stream[pos+2:]))
+		if pos+4+size > len(stream) {
+			return fmt.Errorf("record 0x%04X at offset %d is truncated", typ, pos)
+		}
+		data := stream[pos+4 : pos+4+size]
+		if typ == biffContinue && len(r.records) > 0 {
+			last := &r.records[len(r.records)-1]
+			last.continues = append(last.continues, data)
+		} else {
+			r.records = append(r.records, biffRecord{offset: pos, typ: typ, data: data})
+		}
+		pos += 4 + size
+	}
+	if len(r.records) == 0 || r.records[0].typ != biffBOF {
+		return errors.New("stream does not start with a BOF record")
+	}
+	if v := r.records[0].data; len(v) < 2 || binary.LittleEndian.Uint16(v) != biffVersion8 {
+		return errors.New("only BIFF8 workbooks (Excel 97-2003) are supported")
+	}
+	return nil
+}
+
+// readGlobals reads the workbook globals substream: sheets, shared strings, number formats and the date system.
+func (r *xlsReader) readGlobals() error {
+	for _, rec := range r.records[1:] {
+		data := rec.data
+		switch rec.typ {
+		case biffEOF:
+			return nil
+		case biffFilePass:
+			return errors.New("encrypted workbooks are not supported")
+		case biffDate1904:
+			r.date1904 = len(data) >= 2 && binary.LittleEndian.Uint16(data) == 1
+		case biffBoundSheet:
+			if len(data) < 8 {
+				return errors.New("invalid BOUNDSHEET record")
+			}
+			if data[5] != 0 { // Chart, macro and VBA sheets hold no cells
+				continue
+			}
+			name, err := readXlsString(data[6:], 1)
+			if err != nil {
+				return fmt.Errorf("invalid sheet name: %w", err)
+			}
+			r.boundSheets = append(r.boundSheets, xlsBoundSheet{name: name, offset: int(binary.LittleEndian.Uint32(data))})
+		case biffFormat:
+			if len(data) < 2 {
+				return errors.New("invalid FORMAT record")
+			}
+			code, err := readXlsString(data[2:], 2)
+			if err != nil {
+				return fmt.Errorf("invalid number format: %w", err)
+			}
+			r.formats[binary.LittleEndian.Uint16(data)] = code
+		case biffXF:
+			if len(data) < 4 {
+				return errors.New("invalid XF record")
+			}
+			r.xfFormats = append(r.xfFormats, binary.LittleEndian.Uint16(data[2:]))
+		case biffSST:
+			sst, err := readSharedStrings(rec)
+			if err != nil {
+				return fmt.Errorf("invalid shared string table: %w", err)
+			}
+			r.strings = sst
+		}
+	}
+	return errors.New("workbook globals are not terminated by an EOF record")
+}
+
+// readSheet reads the cells of the worksheet substream starting at the given stream offset.
+func (r *xlsReader) readSheet(offset int) ([][]string, error) {
+	start := -1
+	for i, rec := range r.records {
+		if rec.offset == offset {
+			start = i
+			break
+		}
+	}
+	if start < 0 || r.records[start].typ != biffBOF {
+		return nil, fmt.Errorf("no BOF record at offset %d", offset)
+	}
+
+	var cells [][]string
+	set := func(row uint16, col uint16, value string) {
+		for len(cells) <= int(row) {
+			cells = append(cells, nil)
+		}
+		cells[row] = appendGridCell(cells[row], int(col), value)
+	}
+
+	pendingString := -1 // Cell (row<<16 | col) of a string formula whose result is in the next STRING record
+	for _, rec := range r.records[start+1:] {
+		data := rec.data
+		if rec.typ == biffEOF {
+			break
+		}
+		if rec.typ == biffString {
+			if pendingString >= 0 {
+				value, err := readXlsString(data, 2)
+				if err != nil {
+					return nil, fmt.Errorf("invalid formula string: %w", err)
+				}
+				set(uint16(pendingString>>16), uint16(pendingString), value)
+				pendingString = -1
+			}
+			continue
+		}
+		if len(data) < 6 {
+			continue
+		}
+		row, col, xf := binary.LittleEndian.Uint16(data), binary.LittleEndian.Uint16(data[2:]), binary.LittleEndian.Uint16(data[4:])
+
+		switch rec.typ {
+		case biffLabelSST:
+			if len(data) < 10 {
+				return nil, errors.New("invalid LABELSST record")
+			}
+			index := binary.LittleEndian.Uint32(data[6:])
+			if int(index) >= len(r.strings) {
+				return nil, fmt.Errorf("shared string %d out of range", index)
+			}
+			set(row, col, r.strings[index])
+		case biffLabel:
+			value, err := readXlsString(data[6:], 2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid LABEL record: %w", err)
+			}
+			set(row, col, value)
+		case biffNumber:
+			if len(data) < 14 {
+				return nil, errors.New("invalid NUMBER record")
+			}
+			set(row, col, r.formatNumber(math.Float64frombits(binary.LittleEndian.Uint64(data[6:])), xf))
+		case biffRK:
+			if len(data) < 10 {
+				return nil, errors.New("invalid RK record")
+			}
+			set(row, col, r.formatNumber(decodeRK(binary.LittleEndian.Uint32(data[6:])), xf))
+		case biffMulRK:
+			// rw, colFirst, then (ixfe, rk) pairs, then colLast.
+			for pos, c := 4, col; pos+6 <= len(data)-2; pos, c = pos+6, c+1 {
+				set(row, c, r.formatNumber(decodeRK(binary.LittleEndian.Uint32(data[pos+2:])), binary.LittleEndian.Uint16(data[pos:])))
+			}
+		case biffBoolErr:
+			if len(data) < 8 {
+				return nil, errors.New("invalid BOOLERR record")
+			}
+			set(row, col, formatBoolErr(data[6], data[7] != 0))
+		case biffFormula:
+			if len(data) < 14 {
+				return nil, errors.New("invalid FORMULA record")
+			}
+			result := data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				set(row, col, r.formatNumber(math.Float64frombits(binary.LittleEndian.Uint64(result)), xf))
+				continue
+			}
+			switch result[0] {
+			case 0x00:
+				pendingString = int(row)<<16 | int(col)
+			case 0x01:
+				set(row, col, formatBoolErr(result[2], false))
+			case 0x02:
+				set(row, col, formatBoolErr(result[2], true))
+			}
+		}
+	}
+	return trimGridRows(cells), nil
+}
+
+// formatNumber renders a numeric cell, as a date when its number format displays one.
+func (r *xlsReader) formatNumber(value float64, xf uint16) string {
+	if int(xf) < len(r.xfFormats) && r.isDateFormat(r.xfFormats[xf]) {
+		return formatCellTime(excelSerialToTime(value, r.date1904))
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// isDateFormat reports whether a number format index, built-in or custom, displays a date or time.
+func (r *xlsReader) isDateFormat(format uint16) bool {
+	if code, ok := r.formats[format]; ok {
+		return isDateFormatCode(code)
+	}
+	return (format >= 14 && format <= 22) || (format >= 27 && format <= 36) || (format >= 45 && format <= 47) || (format >= 50 && format <= 58)
+}
+
+// excelSerialToTime converts an Excel date serial number to a time, in the 1900 or 1904 date system.
+func excelSerialToTime(serial float64, date1904 bool) time.Time {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if serial < 61 {
+		// Excel treats 1900 as a leap year, so serials before March 1, 1900 are off by one day.
+		base = base.AddDate(0, 0, 1)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	return base.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
+
+// decodeRK decodes an RK number, the compressed integer or float representation used by RK and MULRK records.
+func decodeRK(rk uint32) float64 {
+	var value float64
+	if rk&0x02 != 0 {
+		value = float64(int32(rk) >> 2)
+	} else {
+		value = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		value /= 100
+	}
+	return value
+}
+
+// formatBoolErr renders a boolean or error cell value as Excel displays it.
+func formatBoolErr(value byte, isError bool) string {
+	if isError {
+		return biffErrorValues[value]
+	}
+	if value != 0 {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// readXlsString decodes an unformatted BIFF8 string whose character count is lenSize (1 or 2) bytes long.
+func readXlsString(data []byte, lenSize int) (string, error) {
+	if len(data) < lenSize+1 {
+		return "", io.ErrUnexpectedEOF
+	}
+	count := int(data[0])
+	if lenSize == 2 {
+		count = int(binary.LittleEndian.Uint16(data))
+	}
+	highByte := data[lenSize]&0x01 != 0
+	pos := lenSize + 1
+	size := count
+	if highByte {
+		size *= 2
+	}
+	if pos+size > len(data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	return decodeXlsChars(data[pos:pos+size], highByte), nil
+}
+
+// decodeXlsChars decodes BIFF8 character data, which is either UTF-16LE or compressed to one byte per character.
+func decodeXlsChars(data []byte, highByte bool) string {
+	if !highByte {
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// biffFragmentReader reads a record's data together with its CONTINUE records.
+type biffFragmentReader struct {
+	fragments [][]byte
+	index     int
+	pos       int
+}
+
+// available returns the unread bytes of the current fragment, moving to the next fragment when it is exhausted.
+func (b *biffFragmentReader) available() int {
+	for b.index < len(b.fragments) && b.pos >= len(b.fragments[b.index]) {
+		b.index++
+		b.pos = 0
+	}
+	if b.index >= len(b.fragments) {
+		return 0
+	}
+	return len(b.fragments[b.index]) - b.pos
+}
+
+// read returns the next n bytes, which may span fragments.
+func (b *biffFragmentReader) read(n int) ([]byte, error) {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		avail := b.available()
+		if avail == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		take := min(avail, n-len(out))
+		out = append(out, b.fragments[b.index][b.pos:b.pos+take]...)
+		b.pos += take
+	}
+	return out, nil
+}
+
+// readSharedStrings decodes the strings of an SST record.
+// A string's characters may continue in a CONTINUE record, which then starts with a new option byte giving their width.
+func readSharedStrings(rec biffRecord) ([]string, error) {
+	b := &biffFragmentReader{fragments: append([][]byte{rec.data}, rec.continues...)}
+	header, err := b.read(8)
+	if err != nil {
+		return nil, err
+	}
+	unique := int(binary.LittleEndian.Uint32(header[4:]))
+
+	sst := make([]string, 0, unique)
+	for i := 0; i < unique; i++ {
+		head, err := b.read(3)
+		if err != nil {
+			return nil, err
+		}
+		count := int(binary.LittleEndian.Uint16(head))
+		flags := head[2]
+		runs, extSize := 0, 0
+		if flags&0x08 != 0 {
+			v, err := b.read(2)
+			if err != nil {
+				return nil, err
+			}
+			runs = int(binary.LittleEndian.Uint16(v))
+		}
+		if flags&0x04 != 0 {
+			v, err := b.read(4)
+			if err != nil {
+				return nil, err
+			}
+			extSize = int(binary.LittleEndian.Uint32(v))
+		}
+
+		var value []rune
+		highByte := flags&0x01 != 0
+		fragment := b.index
+		for remaining := count; remaining > 0; {
+			avail := b.available()
+			if avail == 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if b.index != fragment {
+				// Characters continued in a new fragment start with their own option byte.
+				fragment = b.index
+				highByte = b.fragments[b.index][0]&0x01 != 0
+				b.pos++
+				avail--
+			}
+			width := 1
+			if highByte {
+				width = 2
+			}
+			n := min(remaining, avail/width)
+			if n == 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			chunk, err := b.read(n * width)
+			if err != nil {
+				return nil, err
+			}
+			value = append(value, []rune(decodeXlsChars(chunk, highByte))...)
+			remaining -= n
+		}
+
+		// Formatting runs and phonetic data are not needed.
+		if _, err := b.read(4*runs + extSize); err != nil {
+			return nil, err
+		}
+		sst = append(sst, string(value))
+	}
+	return sst, nil
+}
@@ -0,0 +1,179 @@
+package connector
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestOpenXlsWorkbook(t *testing.T) {
+	// testdata/users.xls holds shared strings continued across CONTINUE records, MULRK and RK numbers,
+	// dates with built-in and custom formats, a string formula result, a boolean and an error
+	wb, err := openXlsWorkbook("testdata/users.xls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gridSheet{{name: "users", cells: [][]string{
+		{"Name", "Display Name", "Last Login", "Logins", "Score", "Active"},
+		{"alice", "Anna Łukasz", "01/01/2024 12:00:00", "42", "1.5", "TRUE"},
+		{"bob", "Bob Jones", "03/15/2023", "2.25", "-7", "#N/A"},
+		nil,
+		{"carol"},
+	}}}
+	if !reflect.DeepEqual(wb.sheets, want) {
+		t.Errorf("sheets = %q, want %q", wb.sheets, want)
+	}
+}
+
+// sstString encodes a shared string with one byte per character, or UTF-16 when highByte is set.
+func sstString(s string, highByte bool) []byte {
+	chars := []rune(s)
+	data := binary.LittleEndian.AppendUint16(nil, uint16(len(chars)))
+	if !highByte {
+		data = append(data, 0x00)
+		for _, c := range chars {
+			data = append(data, byte(c))
+		}
+		return data
+	}
+	data = append(data, 0x01)
+	for _, c := range chars {
+		data = binary.LittleEndian.AppendUint16(data, uint16(c))
+	}
+	return data
+}
+
+// sstHeader returns the start of an SST record holding count strings.
+func sstHeader(count int) []byte {
+	return binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, uint32(count)), uint32(count))
+}
+
+func TestReadSharedStrings(t *testing.T) {
+	// A rich text string with two formatting runs and 3 bytes of phonetic data
+	rich := append(binary.LittleEndian.AppendUint16(nil, 4), 0x0C)
+	rich = binary.LittleEndian.AppendUint16(rich, 2)
+	rich = binary.LittleEndian.AppendUint32(rich, 3)
+	rich = append(rich, "bold"...)
+
+	tests := []struct {
+		name    string
+		rec     biffRecord
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "single record",
+			rec:  biffRecord{data: concatBytes(sstHeader(2), sstString("alice", false), sstString("Łukasz", true))},
+			want: []string{"alice", "Łukasz"},
+		},
+		{
+			name: "characters continued with another width",
+			rec: biffRecord{
+				data:      concatBytes(sstHeader(2), sstString("Anna Łukasz", false)[:3+5]),
+				continues: [][]byte{concatBytes([]byte{0x01}, sstString("Łukasz", true)[3:], sstString("bob", false))},
+			},
+			want: []string{"Anna Łukasz", "bob"},
+		},
+		{
+			name: "characters starting in the next record",
+			rec: biffRecord{
+				data:      concatBytes(sstHeader(1), sstString("carol", false)[:3]),
+				continues: [][]byte{concatBytes([]byte{0x00}, []byte("carol"))},
+			},
+			want: []string{"carol"},
+		},
+		{
+			name: "string header in the next record",
+			rec: biffRecord{
+				data:      concatBytes(sstHeader(2), sstString("dave", true)),
+				continues: [][]byte{sstString("erin", false)},
+			},
+			want: []string{"dave", "erin"},
+		},
+		{
+			name: "formatting runs and phonetic data across records",
+			rec: biffRecord{
+				data:      concatBytes(sstHeader(2), rich, make([]byte, 5)),
+				continues: [][]byte{concatBytes(make([]byte, 6), sstString("frank", false))},
+			},
+			want: []string{"bold", "frank"},
+		},
+		{
+			name:    "truncated",
+			rec:     biffRecord{data: concatBytes(sstHeader(2), sstString("alice", false))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSharedStrings(tt.rec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readSharedStrings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSharedStrings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// concatBytes joins byte slices into a new one.
+func concatBytes(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestDecodeRK(t *testing.T) {
+	tests := []struct {
+		rk   uint32
+		want float64
+	}{
+		{rk: 42<<2 | 0x02, want: 42},
+		{rk: 0xFFFFFFE6, want: -7}, // -7<<2 | 0x02
+		{rk: 150<<2 | 0x03, want: 1.5},
+		{rk: uint32(math.Float64bits(2.25) >> 32), want: 2.25},
+		{rk: uint32(math.Float64bits(1234.5)>>32) | 0x01, want: 12.345},
+	}
+	for _, tt := range tests {
+		if got := decodeRK(tt.rk); got != tt.want {
+			t.Errorf("decodeRK(0x%08X) = %v, want %v", tt.rk, got, tt.want)
+		}
+	}
+}
+
+func TestXlsFormatNumber(t *testing.T) {
+	r := &xlsReader{
+		formats:   map[uint16]string{164: "yyyy-mm-dd", 165: `[Red]0.00;"d"0`, 166: "[h]:mm"},
+		xfFormats: []uint16{0, 14, 164, 165, 166, 22, 49},
+	}
+	tests := []struct {
+		name     string
+		value    float64
+		xf       uint16
+		date1904 bool
+		want     string
+	}{
+		{name: "general", value: 1234.5, xf: 0, want: "1234.5"},
+		{name: "built-in date", value: 45292, xf: 1, want: "01/01/2024"},
+		{name: "custom date", value: 45000, xf: 2, want: "03/15/2023"},
+		{name: "custom number with quoted and bracketed text", value: 3, xf: 3, want: "3"},
+		{name: "elapsed time", value: 45292.5, xf: 4, want: "01/01/2024 12:00:00"},
+		{name: "built-in date and time", value: 45292.75, xf: 5, want: "01/01/2024 18:00:00"},
+		{name: "built-in text", value: 7, xf: 6, want: "7"},
+		{name: "1900 leap year bug", value: 59, xf: 1, want: "02/28/1900"},
+		{name: "1904 date system", value: 43830, xf: 1, date1904: true, want: "01/01/2024"},
+		{name: "unknown XF", value: 45292, xf: 99, want: "45292"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.date1904 = tt.date1904
+			if got := r.formatNumber(tt.value, tt.xf); got != tt.want {
+				t.Errorf("formatNumber(%v, %d) = %q, want %q", tt.value, tt.xf, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,856 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+// The xlsxRegion struct is a sheet region holding rows of one section, as read for an in-place edit.
+type xlsxRegion struct {
+	section string
+	region  sheetRegion
+	headers []string // Header cells, clipped to the region
+	cols    columnIndex
+	lastRow int // Last row of the sheet holding cells, 1-based; new rows are added below it
+	width   int // Columns of the region holding cells, including columns without a header
+}
+
+// The xlsxRecordSource struct locates a record of the input file in a workbook.
+type xlsxRecordSource struct {
+	region *xlsxRegion
+	row    int       // 1-based
+	cells  []string  // Cells of the row, clipped to the region
+	field  string    // Cell the record is one value of: "members" for entitlement members, "" for the whole row
+	grant  GrantData // For grant rows, the row as read, before multiple values are expanded
+}
+
+// The xlsxEditor struct applies record changes to a workbook in place, keeping its formatting, formulas, data validation
+// and unrelated sheets. Rows are located with the same sheet sources, header resolution and row processing as the loader,
+// so each record is found where it was read from.
+type xlsxEditor struct {
+	f         *excelize.File
+	opts      *LoadOptions
+	regions   map[string][]*xlsxRegion
+	sources   map[string]map[string]*xlsxRecordSource // Section, then journal record key
+	deletions map[string]map[int]bool                 // Sheet, then rows to delete
+	removals  map[*xlsxRecordSource][]string          // Values to remove from multi-value cells
+	tables    map[string]bool                         // Sections read from a named table, which are not edited
+}
+
+// editWorkbook applies record changes, as computed by diffJournalRecords, to the Excel workbook at filePath in place.
+// Modified records have their changed cells set, deleted records have their rows removed, or their value removed
+// from a multi-value cell, and created records are added below the last row of their section. Columns missing for a
+// value are added after the last column holding cells. The check runs before the file is replaced, see atomicWriteFile.
+func editWorkbook(ctx context.Context, filePath string, opts *LoadOptions, changes []journalRecordChange, check func() error) error {
+	l := ctxzap.Extract(ctx)
+
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			l.Error("failed to close file", zap.Error(err), zap.String("file", filePath))
+		}
+	}()
+
+	e := &xlsxEditor{
+		f:         f,
+		opts:      opts,
+		regions:   make(map[string][]*xlsxRegion),
+		sources:   make(map[string]map[string]*xlsxRecordSource),
+		deletions: make(map[string]map[int]bool),
+		removals:  make(map[*xlsxRecordSource][]string),
+		tables:    make(map[string]bool),
+	}
+	// Rows skipped by the loader were already reported when the file was read
+	if err := e.locateRecords(ctxzap.ToContext(ctx, zap.NewNop())); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if err := e.apply(c); err != nil {
+			return fmt.Errorf("%s record %q: %w", strings.TrimSuffix(c.Section, "s"), c.Key, err)
+		}
+	}
+	if err := e.applyRemovals(); err != nil {
+		return err
+	}
+	if err := e.applyDeletions(); err != nil {
+		return err
+	}
+
+	return atomicWriteFile(filePath, func(w io.Writer) error {
+		_, err := f.WriteTo(w)
+		return err
+	}, check)
+}
+
+// locateRecords reads the rows of every section and records where each record comes from, keyed as journalRecords keys them.
+// Sections read from a named table are skipped; the table would have to be resized with its rows.
+func (e *xlsxEditor) locateRecords(ctx context.Context) error {
+	wb := &excelWorkbook{f: e.f}
+	scratch := &LoadedData{}
+	sheetConfigs := newSheetConfigs(ctx, scratch)
+	delimiter := e.opts.delimiter()
+
+	var memberGrants []*xlsxRecordSource
+	var memberKeys []string
+	for _, section := range sectionNames {
+		regions, err := resolveSheetRegions(wb, e.opts.sheetSources(section))
+		if err != nil {
+			return fmt.Errorf("failed to resolve sheets for section %s: %w", section, err)
+		}
+		keys := make(journalKeyCounter)
+		e.sources[section] = make(map[string]*xlsxRecordSource)
+		config := sheetConfigs[section]
+
+		for _, region := range regions {
+			if region.lastRow > 0 {
+				e.tables[section] = true
+			}
+		}
+		if e.tables[section] {
+			continue
+		}
+
+		for _, region := range regions {
+			rows, err := e.f.GetRows(region.sheet)
+			if err != nil {
+				return fmt.Errorf("failed to read sheet %s: %w", region.sheet, err)
+			}
+			if len(rows) < region.headerRow {
+				continue
+			}
+			r := &xlsxRegion{section: section, region: region, headers: region.clip(rows[region.headerRow-1]), lastRow: len(rows)}
+			r.cols = buildColumnIndex(r.headers, e.opts.columnAliases(section))
+			if r.width, err = e.regionWidth(region, rows); err != nil {
+				return err
+			}
+			usable := true
+			for _, field := range config.fields {
+				if _, ok := r.cols[field]; !ok {
+					usable = false
+				}
+			}
+			if !usable {
+				continue // Skipped by the loader as well
+			}
+			e.regions[section] = append(e.regions[section], r)
+
+			for i := region.headerRow; i < len(rows); i++ {
+				cells := region.clip(rows[i])
+				if isBlankRow(cells) {
+					continue
+				}
+				scratch.Users, scratch.Resources, scratch.Entitlements, scratch.Grants = nil, nil, nil, nil
+				config.process(i+1, cells, r.cols)
+				var rowGrant GrantData
+				if len(scratch.Grants) == 1 {
+					rowGrant = scratch.Grants[0]
+				}
+				expandMultiValues(scratch, delimiter)
+
+				for _, record := range sectionRecords(scratch, section) {
+					e.sources[section][keys.next(journalRecordKey(record))] = &xlsxRecordSource{region: r, row: i + 1, cells: cells, grant: rowGrant}
+				}
+				if section == sectionEntitlements {
+					// Entitlement members are loaded as grants, after the grants of the grants section
+					for _, g := range scratch.Grants {
+						memberGrants = append(memberGrants, &xlsxRecordSource{region: r, row: i + 1, cells: cells, field: "members", grant: g})
+						memberKeys = append(memberKeys, journalRecordKey(g))
+					}
+				}
+			}
+		}
+
+		if section == sectionGrants {
+			for i, source := range memberGrants {
+				e.sources[section][keys.next(memberKeys[i])] = source
+			}
+		}
+	}
+	return nil
+}
+
+// regionWidth returns the number of columns of a sheet region holding cells, from the sheet's dimension,
+// which includes formula cells without a cached value, and from its rows.
+func (e *xlsxEditor) regionWidth(region sheetRegion, rows [][]string) (int, error) {
+	width := 0
+	for _, row := range rows[region.headerRow-1:] {
+		width = max(width, len(region.clip(row)))
+	}
+	dimension, err := e.f.GetSheetDimension(region.sheet)
+	if err != nil {
+		return 0, err
+	}
+	if _, last, ok := strings.Cut(dimension, ":"); ok {
+		lastCol, _, err := excelize.CellNameToCoordinates(last)
+		if err != nil {
+			return 0, err
+		}
+		if region.lastCol > 0 {
+			lastCol = min(lastCol, region.lastCol)
+		}
+		width = max(width, lastCol-region.firstCol+1)
+	}
+	return width, nil
+}
+
+// sectionRecords returns the records of one section of the data.
+func sectionRecords(data *LoadedData, section string) []interface{} {
+	var records []interface{}
+	switch section {
+	case sectionUsers:
+		for _, r := range data.Users {
+			records = append(records, r)
+		}
+	case sectionResources:
+		for _, r := range data.Resources {
+			records = append(records, r)
+		}
+	case sectionEntitlements:
+		for _, r := range data.Entitlements {
+			records = append(records, r)
+		}
+	case sectionGrants:
+		for _, r := range data.Grants {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// apply applies one record change. Row deletions and multi-value removals are collected and applied last,
+// so the rows located before stay valid.
+func (e *xlsxEditor) apply(c journalRecordChange) error {
+	if e.tables[c.Section] {
+		return fmt.Errorf("section %s is read from a named table, which %w", c.Section, errNotEditableInPlace)
+	}
+	after, err := recordFieldValues(c.After)
+	if err != nil {
+		return err
+	}
+	if isJSONNull(c.Before) {
+		return e.appendRecord(c.Section, after)
+	}
+
+	source, ok := e.sources[c.Section][c.Key]
+	if !ok {
+		if c.Section == sectionGrants && e.tables[sectionEntitlements] {
+			return fmt.Errorf("not found in the workbook; entitlement members are read from a named table, which %w", errNotEditableInPlace)
+		}
+		return fmt.Errorf("not found in the workbook")
+	}
+	if isJSONNull(c.After) {
+		return e.deleteRecord(c.Section, source, c.Before)
+	}
+
+	before, err := recordFieldValues(c.Before)
+	if err != nil {
+		return err
+	}
+	// Grants are identified by all their fields, so only users, resources and entitlements are modified
+	for _, field := range changedFields(c.Section, before, after) {
+		if err := e.setField(source.region, source.row, field, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRecord removes the row of a record, or its value from a multi-value cell when the row holds other records too.
+func (e *xlsxEditor) deleteRecord(section string, source *xlsxRecordSource, beforeRaw json.RawMessage) error {
+	field := source.field
+	var value string
+	switch {
+	case field == "members":
+		value = source.grant.Principal
+	case section == sectionGrants:
+		var record GrantData
+		if err := json.Unmarshal(beforeRaw, &record); err != nil {
+			return err
+		}
+		var err error
+		if field, value, err = multiValueField(source.grant, record, e.opts.delimiter()); err != nil {
+			return err
+		}
+	}
+
+	if field == "" {
+		sheet := source.region.region.sheet
+		if e.deletions[sheet] == nil {
+			e.deletions[sheet] = make(map[int]bool)
+		}
+		e.deletions[sheet][source.row] = true
+		return nil
+	}
+	source.field = field
+	e.removals[source] = append(e.removals[source], value)
+	return nil
+}
+
+// applyRemovals removes the values of deleted records from multi-value cells. A grant row left without values is deleted,
+// while an entitlement keeps its row with an empty members cell. Rows being deleted anyway are left alone.
+func (e *xlsxEditor) applyRemovals() error {
+	type cellKey struct {
+		region *xlsxRegion
+		row    int
+		field  string
+	}
+	removals := make(map[cellKey][]string)
+	cells := make(map[cellKey][]string)
+	var order []cellKey
+	for source, values := range e.removals {
+		key := cellKey{region: source.region, row: source.row, field: source.field}
+		if _, ok := removals[key]; !ok {
+			order = append(order, key)
+		}
+		removals[key] = append(removals[key], values...)
+		cells[key] = source.cells
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].row < order[j].row })
+
+	for _, key := range order {
+		sheet := key.region.region.sheet
+		if e.deletions[sheet][key.row] {
+			continue
+		}
+		cell, remains := removeMultiValues(key.region.cols.get(cells[key], key.field), removals[key], e.opts.delimiter())
+		if !remains && key.field != "members" {
+			if e.deletions[sheet] == nil {
+				e.deletions[sheet] = make(map[int]bool)
+			}
+			e.deletions[sheet][key.row] = true
+			continue
+		}
+		if err := e.setCell(key.region, key.row, key.field, cell); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDeletions removes the rows of deleted records, bottom up so the row numbers of the others stay valid.
+// excelize neither moves comments nor shifts data validation ranges the way spreadsheet applications do, and moves
+// formula references to a removed row up instead of dropping them, so all three are read before and set again after the removal.
+func (e *xlsxEditor) applyDeletions() error {
+	if len(e.deletions) == 0 {
+		return nil
+	}
+	removed := make(map[string][]int, len(e.deletions))
+	for sheet, rows := range e.deletions {
+		sorted := make([]int, 0, len(rows))
+		for row := range rows {
+			sorted = append(sorted, row)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+		removed[sheet] = sorted
+	}
+	formulas, err := e.readFormulas()
+	if err != nil {
+		return err
+	}
+	names := e.f.GetDefinedName()
+
+	for sheet, sorted := range removed {
+		comments, err := e.f.GetComments(sheet)
+		if err != nil {
+			return fmt.Errorf("failed to read the comments of sheet %s: %w", sheet, err)
+		}
+		validations, err := e.f.GetDataValidations(sheet)
+		if err != nil {
+			return fmt.Errorf("failed to read the data validations of sheet %s: %w", sheet, err)
+		}
+		for _, row := range sorted {
+			if err := e.f.RemoveRow(sheet, row); err != nil {
+				return fmt.Errorf("failed to remove row %d of sheet %s: %w", row, sheet, err)
+			}
+		}
+		if err := e.moveComments(sheet, comments, sorted); err != nil {
+			return err
+		}
+		if err := e.shiftDataValidations(sheet, validations, sorted); err != nil {
+			return err
+		}
+	}
+	if err := e.shiftFormulas(formulas, removed); err != nil {
+		return err
+	}
+	return e.shiftDefinedNames(names, removed)
+}
+
+// moveComments moves the comments of a sheet up by the number of removed rows above them, and deletes
+// the comments of removed rows. Removed rows are given bottom up.
+func (e *xlsxEditor) moveComments(sheet string, comments []excelize.Comment, removed []int) error {
+	var moved []excelize.Comment
+	for _, comment := range comments {
+		col, row, err := excelize.CellNameToCoordinates(comment.Cell)
+		if err != nil {
+			return err
+		}
+		newRow, kept := shiftRow(row, removed)
+		if kept && newRow == row {
+			continue
+		}
+		if err := e.f.DeleteComment(sheet, comment.Cell); err != nil {
+			return fmt.Errorf("failed to move comment %s of sheet %s: %w", comment.Cell, sheet, err)
+		}
+		if !kept {
+			continue
+		}
+		if comment.Cell, err = excelize.CoordinatesToCellName(col, newRow); err != nil {
+			return err
+		}
+		moved = append(moved, comment)
+	}
+	// Comments are added once every moved one is deleted, so none replaces another that is still to move
+	for _, comment := range moved {
+		if err := e.f.AddComment(sheet, comment); err != nil {
+			return fmt.Errorf("failed to move comment to %s of sheet %s: %w", comment.Cell, sheet, err)
+		}
+	}
+	return nil
+}
+
+// shiftDataValidations sets the data validations of a sheet again, with their ranges shrunk or moved up for the removed rows.
+// Removed rows are given bottom up. A validation left without cells is dropped.
+func (e *xlsxEditor) shiftDataValidations(sheet string, validations []*excelize.DataValidation, removed []int) error {
+	if len(validations) == 0 {
+		return nil
+	}
+	if err := e.f.DeleteDataValidation(sheet); err != nil {
+		return err
+	}
+	for _, dv := range validations {
+		var ranges []string
+		for _, ref := range strings.Fields(dv.Sqref) {
+			if shifted, ok := shiftRangeRef(ref, removed); ok {
+				ranges = append(ranges, shifted)
+			}
+		}
+		if len(ranges) == 0 {
+			continue
+		}
+		dv.Sqref = strings.Join(ranges, " ")
+		// AddDataValidation takes the formulas as XML content, while GetDataValidations returns them unescaped
+		dv.Formula1, dv.Formula2 = xmlFormulaEscaper.Replace(dv.Formula1), xmlFormulaEscaper.Replace(dv.Formula2)
+		if err := e.f.AddDataValidation(sheet, dv); err != nil {
+			return fmt.Errorf("failed to set data validation %s of sheet %s: %w", dv.Sqref, sheet, err)
+		}
+	}
+	return nil
+}
+
+// xmlFormulaEscaper escapes a formula for use as XML content.
+var xmlFormulaEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// The xlsxFormula struct is the formula of a cell, as read before rows are removed.
+type xlsxFormula struct {
+	sheet   string
+	col     int
+	row     int
+	formula string
+}
+
+// readFormulas returns the formulas of every sheet, row by row so shared formulas come before the cells sharing them.
+// Cells are read up to the sheet's dimension, or its last cell holding a value when that is further.
+func (e *xlsxEditor) readFormulas() ([]xlsxFormula, error) {
+	var formulas []xlsxFormula
+	for _, sheet := range e.f.GetSheetList() {
+		rows, err := e.f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %s: %w", sheet, err)
+		}
+		lastCol, lastRow := 0, len(rows)
+		for _, row := range rows {
+			lastCol = max(lastCol, len(row))
+		}
+		if dimension, err := e.f.GetSheetDimension(sheet); err == nil && dimension != "" {
+			_, end, _ := strings.Cut(dimension, ":")
+			if col, row, err := excelize.CellNameToCoordinates(end); err == nil {
+				lastCol, lastRow = max(lastCol, col), max(lastRow, row)
+			}
+		}
+		for row := 1; row <= lastRow; row++ {
+			for col := 1; col <= lastCol; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, row)
+				formula, err := e.f.GetCellFormula(sheet, cell)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read cell %s of sheet %s: %w", cell, sheet, err)
+				}
+				if formula != "" {
+					formulas = append(formulas, xlsxFormula{sheet: sheet, col: col, row: row, formula: formula})
+				}
+			}
+		}
+	}
+	return formulas, nil
+}
+
+// shiftFormulas sets the formulas read before rows were removed again, at their cell's new row and with their references
+// shifted by shiftFormulaRefs, wherever excelize adjusted them differently. Removed rows are given per sheet, bottom up.
+func (e *xlsxEditor) shiftFormulas(formulas []xlsxFormula, removed map[string][]int) error {
+	for _, f := range formulas {
+		row, kept := shiftRow(f.row, removed[f.sheet])
+		if !kept {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(f.col, row)
+		want := shiftFormulaRefs(f.formula, f.sheet, removed)
+		got, err := e.f.GetCellFormula(f.sheet, cell)
+		if err != nil {
+			return fmt.Errorf("failed to read cell %s of sheet %s: %w", cell, f.sheet, err)
+		}
+		if got == want {
+			continue
+		}
+		if err := e.f.SetCellFormula(f.sheet, cell, want); err != nil {
+			return fmt.Errorf("failed to set the formula of cell %s of sheet %s: %w", cell, f.sheet, err)
+		}
+	}
+	return nil
+}
+
+// shiftDefinedNames sets the defined names read before rows were removed again, with their references shifted by
+// shiftFormulaRefs, wherever excelize adjusted them differently. Removed rows are given per sheet, bottom up.
+func (e *xlsxEditor) shiftDefinedNames(names []excelize.DefinedName, removed map[string][]int) error {
+	current := e.f.GetDefinedName()
+	if len(current) != len(names) {
+		return nil
+	}
+	for i, name := range names {
+		name.RefersTo = shiftFormulaRefs(name.RefersTo, "", removed)
+		if current[i].RefersTo == name.RefersTo {
+			continue
+		}
+		if err := e.f.DeleteDefinedName(&current[i]); err != nil {
+			return fmt.Errorf("failed to update defined name %s: %w", name.Name, err)
+		}
+		if name.Scope == "Workbook" {
+			name.Scope = ""
+		}
+		if err := e.f.SetDefinedName(&name); err != nil {
+			return fmt.Errorf("failed to update defined name %s: %w", name.Name, err)
+		}
+	}
+	return nil
+}
+
+// formulaRefPattern matches a cell, cell range or row range reference of a formula, with its optional sheet name.
+var formulaRefPattern = regexp.MustCompile(`(?:('(?:[^']|'')+'|[A-Za-z0-9_.]+)!)?(\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[0-9]+:\$?[0-9]+)`)
+
+// cellRefPattern splits one end of a reference into its column and row, each with its "$" marker.
+var cellRefPattern = regexp.MustCompile(`^(\$?[A-Za-z]*)(\$?)([0-9]+)$`)
+
+// shiftFormulaRefs returns a formula with its references to sheets with removed rows shifted the way spreadsheet
+// applications shift them: ranges shrink for rows removed inside them and move up for rows removed above them, and
+// references to removed cells become #REF!. References without a sheet name are to the sheet of the formula, or to
+// no sheet when it is empty. String literals are left as they are.
+func shiftFormulaRefs(formula, sheet string, removed map[string][]int) string {
+	var out strings.Builder
+	for i, part := range strings.Split(formula, `"`) {
+		if i > 0 {
+			out.WriteByte('"')
+		}
+		if i%2 == 1 { // Inside a string literal; its doubled quotes split it into further literals
+			out.WriteString(part)
+			continue
+		}
+		last := 0
+		for _, m := range formulaRefPattern.FindAllStringSubmatchIndex(part, -1) {
+			if !isFormulaRefBoundary(part, m[0]-1, true) || !isFormulaRefBoundary(part, m[1], false) {
+				continue
+			}
+			refSheet := sheet
+			if m[2] >= 0 {
+				refSheet = strings.ReplaceAll(strings.Trim(part[m[2]:m[3]], "'"), "''", "'")
+			}
+			rows := removedRowsOf(removed, refSheet)
+			if len(rows) == 0 {
+				continue
+			}
+			if shifted, ok := shiftFormulaRef(part[m[4]:m[5]], rows); ok {
+				out.WriteString(part[last:m[4]])
+				out.WriteString(shifted)
+			} else {
+				out.WriteString(part[last:m[0]])
+				out.WriteString("#REF!")
+			}
+			last = m[1]
+		}
+		out.WriteString(part[last:])
+	}
+	return out.String()
+}
+
+// isFormulaRefBoundary reports whether the character of a formula at i may precede (before) or follow a reference,
+// so names and function calls that look like references, such as LOG10(, are left alone.
+func isFormulaRefBoundary(formula string, i int, before bool) bool {
+	if i < 0 || i >= len(formula) {
+		return true
+	}
+	c := formula[i]
+	if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_.$!'", c) >= 0 {
+		return false
+	}
+	return !(before && c == ':' || !before && c == '(')
+}
+
+// removedRowsOf returns the removed rows of a sheet, matching its name the way formulas do, ignoring case.
+func removedRowsOf(removed map[string][]int, sheet string) []int {
+	if sheet == "" {
+		return nil
+	}
+	for name, rows := range removed {
+		if strings.EqualFold(name, sheet) {
+			return rows
+		}
+	}
+	return nil
+}
+
+// shiftFormulaRef returns a cell, cell range or row range reference, without its sheet name, once the removed rows,
+// given bottom up, are gone, keeping its "$" markers. It returns false when every cell it refers to is removed.
+func shiftFormulaRef(ref string, removed []int) (string, bool) {
+	first, last, isRange := strings.Cut(ref, ":")
+	if !isRange {
+		last = first
+	}
+	m1, m2 := cellRefPattern.FindStringSubmatch(first), cellRefPattern.FindStringSubmatch(last)
+	if m1 == nil || m2 == nil {
+		return ref, true
+	}
+	row1, _ := strconv.Atoi(m1[3])
+	row2, _ := strconv.Atoi(m2[3])
+	row1, row2, ok := shiftRows(row1, row2, removed)
+	if !ok {
+		return "", false
+	}
+	shifted := m1[1] + m1[2] + strconv.Itoa(row1)
+	if isRange {
+		shifted += ":" + m2[1] + m2[2] + strconv.Itoa(row2)
+	}
+	return shifted, true
+}
+
+// shiftRow returns the number of a row once the removed rows, given bottom up, are gone,
+// and false when the row is one of them.
+func shiftRow(row int, removed []int) (int, bool) {
+	shifted := row
+	for _, r := range removed {
+		switch {
+		case r == row:
+			return 0, false
+		case r < row:
+			shifted--
+		}
+	}
+	return shifted, true
+}
+
+// shiftRows returns the first and last row of a range once the removed rows, given bottom up, are gone: rows removed
+// inside the range shrink it and rows removed above move it up. It returns false when every row of the range is removed.
+// Ranges reaching the last row of the sheet keep reaching it.
+func shiftRows(first, last int, removed []int) (int, int, bool) {
+	for _, r := range removed {
+		if r < first {
+			first--
+		}
+		if r <= last && last < excelize.TotalRows {
+			last--
+		}
+		if last < first {
+			return 0, 0, false
+		}
+	}
+	return first, last, true
+}
+
+// shiftRangeRef returns a cell range such as "D2:D100" once the removed rows, given bottom up, are gone, see shiftRows,
+// and false when every row of the range is removed. References that cannot be parsed are returned unchanged.
+func shiftRangeRef(ref string, removed []int) (string, bool) {
+	first, last, isRange := strings.Cut(ref, ":")
+	if !isRange {
+		last = first
+	}
+	col1, row1, err1 := excelize.CellNameToCoordinates(first)
+	col2, row2, err2 := excelize.CellNameToCoordinates(last)
+	if err1 != nil || err2 != nil {
+		return ref, true
+	}
+	row1, row2, ok := shiftRows(row1, row2, removed)
+	if !ok {
+		return "", false
+	}
+	start, _ := excelize.CoordinatesToCellName(col1, row1)
+	if col1 == col2 && row1 == row2 {
+		return start, true
+	}
+	end, _ := excelize.CoordinatesToCellName(col2, row2)
+	return start + ":" + end, true
+}
+
+// appendRecord adds a row for a created record below the last row of its section's last sheet,
+// styled like the row above it. A sheet named after the section is added when the workbook has none.
+func (e *xlsxEditor) appendRecord(section string, values map[string]interface{}) error {
+	regions := e.regions[section]
+	if len(regions) == 0 {
+		r, err := e.addSheet(section)
+		if err != nil {
+			return err
+		}
+		regions = append(regions, r)
+		e.regions[section] = regions
+	}
+	r := regions[len(regions)-1]
+	r.lastRow++
+	row := r.lastRow
+
+	if above := row - 1; above > r.region.headerRow {
+		for i := range r.headers {
+			aboveCell, err := excelize.CoordinatesToCellName(r.region.firstCol+i, above)
+			if err != nil {
+				return err
+			}
+			cell, err := excelize.CoordinatesToCellName(r.region.firstCol+i, row)
+			if err != nil {
+				return err
+			}
+			style, err := e.f.GetCellStyle(r.region.sheet, aboveCell)
+			if err != nil {
+				return err
+			}
+			if err := e.f.SetCellStyle(r.region.sheet, cell, cell, style); err != nil {
+				return err
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if err := e.setField(r, row, field, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSheet adds a sheet named after a section, with the headers the workbook writer uses.
+func (e *xlsxEditor) addSheet(section string) (*xlsxRegion, error) {
+	if _, err := e.f.NewSheet(section); err != nil {
+		return nil, fmt.Errorf("failed to add sheet %s: %w", section, err)
+	}
+	r := &xlsxRegion{section: section, region: sheetRegion{sheet: section, headerRow: 1, firstCol: 1}, lastRow: 1}
+	r.headers = append(r.headers, sheetHeaders[section]...)
+	if err := e.f.SetSheetRow(section, "A1", &r.headers); err != nil {
+		return nil, err
+	}
+	r.cols = buildColumnIndex(r.headers, e.opts.columnAliases(section))
+	return r, nil
+}
+
+// setField writes a field of a record's row, as given by recordFieldValues. Profile attributes go to their
+// "Profile: <key>" column, whose header matches keys case-insensitively, or to the JSON profile column when the sheet has one instead.
+func (e *xlsxEditor) setField(r *xlsxRegion, row int, field string, values map[string]interface{}) error {
+	value := cellText(values[field])
+	if key, ok := strings.CutPrefix(field, profileFieldPrefix); ok {
+		field = profileFieldPrefix + strings.ToLower(key)
+		if _, ok := r.cols[field]; !ok {
+			if _, ok := r.cols["profile"]; ok {
+				profile := make(map[string]interface{})
+				for f, v := range values {
+					if key, ok := strings.CutPrefix(f, profileFieldPrefix); ok {
+						profile[key] = v
+					}
+				}
+				return e.setCell(r, row, "profile", cellText(profile))
+			}
+		}
+	}
+	return e.setCell(r, row, field, value)
+}
+
+// setCell writes the cell of a canonical field in a row, adding the field's column when the sheet lacks it.
+// Existing cell styles are kept.
+func (e *xlsxEditor) setCell(r *xlsxRegion, row int, field string, value string) error {
+	col, ok := r.cols[field]
+	if !ok {
+		if value == "" {
+			return nil
+		}
+		var err error
+		if col, err = e.addColumn(r, field); err != nil {
+			return err
+		}
+	}
+	cell, err := excelize.CoordinatesToCellName(r.region.firstCol+col, row)
+	if err != nil {
+		return err
+	}
+	return e.f.SetCellValue(r.region.sheet, cell, value)
+}
+
+// addColumn adds the header of a field after the last column of a sheet region holding cells, styled like the last header,
+// and returns the column's 0-based position in the region. A region limited to a range of columns must have room for it.
+func (e *xlsxEditor) addColumn(r *xlsxRegion, field string) (int, error) {
+	header := ""
+	if key, ok := strings.CutPrefix(field, profileFieldPrefix); ok {
+		header = "Profile: " + key
+	} else {
+		for i, f := range canonicalFields[r.section] {
+			if f == field && i < len(sheetHeaders[r.section]) {
+				header = sheetHeaders[r.section][i]
+			}
+		}
+	}
+	if header == "" {
+		return 0, fmt.Errorf("sheet %s has no %s column", r.region.sheet, field)
+	}
+
+	col := max(len(r.headers), r.width)
+	if r.region.lastCol > 0 && r.region.firstCol+col > r.region.lastCol {
+		return 0, fmt.Errorf("sheet %s has no %s column and no room for one in its configured range", r.region.sheet, field)
+	}
+	cell, err := excelize.CoordinatesToCellName(r.region.firstCol+col, r.region.headerRow)
+	if err != nil {
+		return 0, err
+	}
+	if len(r.headers) > 0 {
+		previous, err := excelize.CoordinatesToCellName(r.region.firstCol+len(r.headers)-1, r.region.headerRow)
+		if err != nil {
+			return 0, err
+		}
+		style, err := e.f.GetCellStyle(r.region.sheet, previous)
+		if err != nil {
+			return 0, err
+		}
+		if err := e.f.SetCellStyle(r.region.sheet, cell, cell, style); err != nil {
+			return 0, err
+		}
+	}
+	if err := e.f.SetCellValue(r.region.sheet, cell, header); err != nil {
+		return 0, err
+	}
+	for len(r.headers) < col {
+		r.headers = append(r.headers, "")
+	}
+	r.headers = append(r.headers, header)
+	r.width = len(r.headers)
+	r.cols[field] = col
+	return col, nil
+}
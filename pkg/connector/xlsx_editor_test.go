@@ -0,0 +1,254 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// The testSheet struct is a sheet of a workbook written by writeTestWorkbook, header row first.
+type testSheet struct {
+	name string
+	rows [][]string
+}
+
+// writeTestWorkbook writes a workbook with the given sheets to a temporary directory and returns its path.
+func writeTestWorkbook(t *testing.T, sheets ...testSheet) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for _, sheet := range sheets {
+		if _, err := f.NewSheet(sheet.name); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range sheet.rows {
+			cells := make([]interface{}, len(row))
+			for j, cell := range row {
+				cells[j] = cell
+			}
+			if err := f.SetSheetRow(sheet.name, fmt.Sprintf("A%d", i+1), &cells); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := f.DeleteSheet("Sheet1"); err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), "access.xlsx")
+	if err := f.SaveAs(filePath); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestEditWorkbookRoundTrip(t *testing.T) {
+	users := testSheet{name: sectionUsers, rows: [][]string{
+		{"Name", "Display Name", "Email"},
+		{"alice", "Alice", "alice@example.com"},
+		{"bob", "Bob", "bob@example.com"},
+	}}
+	resources := testSheet{name: sectionResources, rows: [][]string{
+		{"Resource Type", "Resource Function", "Name", "Display Name"},
+		{"team", "group", "eng", "Engineering"},
+		{"team", "group", "ops", "Operations"},
+	}}
+
+	tests := []struct {
+		name   string
+		sheets []testSheet
+		mutate func(t *testing.T, data *LoadedData)
+	}{
+		{
+			name: "multi-value cells",
+			sheets: []testSheet{users, resources, {name: sectionGrants, rows: [][]string{
+				{"Principal", "Entitlement ID"},
+				{"alice; bob", "eng:member"},
+				{"carol", "eng:member; ops:member"},
+				{"dave", "ops:member"},
+			}}},
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "carol -> ops:member")
+			},
+		},
+		{
+			name: "members",
+			sheets: []testSheet{users, resources, {name: sectionEntitlements, rows: [][]string{
+				{"Resource Name", "Entitlement", "Display Name", "Members"},
+				{"eng", "member", "Member", "alice; bob; carol"},
+				{"ops", "member", "Member", "dave"},
+			}}, {name: sectionGrants, rows: [][]string{
+				{"Principal", "Entitlement ID"},
+				{"erin", "eng:member"},
+			}}},
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "dave -> ops:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "frank", EntitlementId: "ops:member"})
+			},
+		},
+		{
+			name: "duplicate keys",
+			sheets: []testSheet{users, resources, {name: sectionEntitlements, rows: [][]string{
+				{"Resource Name", "Entitlement", "Display Name", "Members"},
+				{"eng", "member", "Member", "alice; bob"},
+				{"eng", "member", "Member", ""},
+			}}, {name: sectionGrants, rows: [][]string{
+				{"Principal", "Entitlement ID"},
+				{"alice", "eng:member"},
+				{"alice; bob", "eng:member"},
+			}}},
+			mutate: func(t *testing.T, data *LoadedData) {
+				// The grants listed after the first alice grant move up, so the last duplicate key disappears
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Entitlements[1].Description = "Second definition"
+			},
+		},
+		{
+			name: "deletes next to creates",
+			sheets: []testSheet{users, resources, {name: sectionGrants, rows: [][]string{
+				{"Principal", "Entitlement ID"},
+				{"alice", "eng:member"},
+				{"bob", "eng:member"},
+			}}},
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users = removeRecord(t, data.Users, "bob")
+				data.Users = append(data.Users, UserData{Name: "bobby", DisplayName: "Bob", Profile: map[string]interface{}{}})
+				data.Users[0].Email = "alice@example.org"
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "bobby", EntitlementId: "eng:member"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := writeTestWorkbook(t, tt.sheets...)
+			assertEditRoundTrip(t, filePath, nil, func(changes []journalRecordChange) error {
+				return editWorkbook(context.Background(), filePath, nil, changes, nil)
+			}, tt.mutate)
+		})
+	}
+}
+
+func TestEditWorkbookKeepsFormattingAroundDeletedRows(t *testing.T) {
+	filePath := writeTestWorkbook(t, testSheet{name: sectionUsers, rows: [][]string{
+		{"Name", "Display Name", "Email", "Status"},
+		{"alice", "Alice", "alice@example.com", "enabled"},
+		{"bob", "Bob", "bob@example.com", "enabled"},
+		{"carol", "Carol", "carol@example.com", "enabled"},
+		{"dave", "Dave", "dave@example.com", "disabled"},
+	}}, testSheet{name: "summary", rows: [][]string{{"Users"}}}, testSheet{name: "notes", rows: [][]string{{"keep"}}})
+
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	highlight, err := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFFF00"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []error{
+		f.SetCellStyle(sectionUsers, "A4", "D4", highlight),
+		f.AddComment(sectionUsers, excelize.Comment{Cell: "B3", Author: "ops", Text: "Leaving in May"}),
+		f.AddComment(sectionUsers, excelize.Comment{Cell: "B4", Author: "ops", Text: "Team lead"}),
+		f.SetCellFormula("summary", "B1", "COUNTA(users!A2:A5)"),
+		f.SetCellFormula(sectionUsers, "E5", "LEN(A5)"),
+		f.SetDefinedName(&excelize.DefinedName{Name: "Statuses", RefersTo: "users!$D$2:$D$5"}),
+	}
+	statuses := excelize.NewDataValidation(true)
+	statuses.Sqref = "D2:D100"
+	steps = append(steps, statuses.SetDropList([]string{"enabled", "disabled"}), f.AddDataValidation(sectionUsers, statuses))
+	single := excelize.NewDataValidation(true)
+	single.Sqref = "C5"
+	steps = append(steps, single.SetDropList([]string{"a<b", "c&d"}), f.AddDataValidation(sectionUsers, single))
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	assertEditRoundTrip(t, filePath, nil, func(changes []journalRecordChange) error {
+		return editWorkbook(context.Background(), filePath, nil, changes, nil)
+	}, func(t *testing.T, data *LoadedData) {
+		data.Users = removeRecord(t, data.Users, "alice")
+		data.Users = removeRecord(t, data.Users, "bob")
+	})
+
+	f, err = excelize.OpenFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if style, err := f.GetCellStyle(sectionUsers, "B2"); err != nil || style != highlight {
+		t.Errorf("style of carol's row = %d (%v), want %d", style, err, highlight)
+	}
+	comments, err := f.GetComments(sectionUsers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Cell != "B2" || comments[0].Text != "Team lead" {
+		t.Errorf("comments = %+v, want only carol's comment at B2", comments)
+	}
+	validations, err := f.GetDataValidations(sectionUsers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sqrefs []string
+	for _, dv := range validations {
+		sqrefs = append(sqrefs, dv.Sqref+" "+dv.Formula1)
+	}
+	if want := []string{`D2:D98 "enabled,disabled"`, `C3 "a<b,c&d"`}; !reflect.DeepEqual(sqrefs, want) {
+		t.Errorf("data validations = %q, want %q", sqrefs, want)
+	}
+	for _, c := range []struct{ sheet, cell, want string }{
+		{"summary", "B1", "COUNTA(users!A2:A3)"},
+		{sectionUsers, "E3", "LEN(A3)"},
+	} {
+		if formula, err := f.GetCellFormula(c.sheet, c.cell); err != nil || formula != c.want {
+			t.Errorf("formula in %s!%s = %q (%v), want %q", c.sheet, c.cell, formula, err, c.want)
+		}
+	}
+	if names := f.GetDefinedName(); len(names) != 1 || names[0].RefersTo != "users!$D$2:$D$3" {
+		t.Errorf("defined names = %+v, want Statuses referring to users!$D$2:$D$3", names)
+	}
+	if value, err := f.GetCellValue("notes", "A1"); err != nil || value != "keep" {
+		t.Errorf("notes!A1 = %q (%v), want %q", value, err, "keep")
+	}
+}
+
+func TestShiftFormulaRefs(t *testing.T) {
+	removed := map[string][]int{sectionUsers: {5, 2}, "Team Leads": {3}}
+	tests := []struct {
+		formula string
+		sheet   string
+		want    string
+	}{
+		{formula: "COUNTA(users!A2:A10)", sheet: "summary", want: "COUNTA(users!A2:A8)"},
+		{formula: "SUM($B$3:B$6)*2", sheet: sectionUsers, want: "SUM($B$2:B$4)*2"},
+		{formula: "A2+A3", sheet: sectionUsers, want: "#REF!+A2"},
+		{formula: "Users!A1&users!A6", sheet: "summary", want: "Users!A1&users!A4"},
+		{formula: "SUM(users!2:2, users!3:7)", sheet: "summary", want: "SUM(#REF!, users!2:5)"},
+		{formula: "COUNTA('Team Leads'!A1:A4)+'Team Leads'!B3", sheet: "summary", want: "COUNTA('Team Leads'!A1:A3)+#REF!"},
+		{formula: `IF(A3="A3","users!A3",LOG10(A4))`, sheet: sectionUsers, want: `IF(A2="A3","users!A3",LOG10(A3))`},
+		{formula: "COUNTA(users!A:A)+SUM(users!B2:B1048576)", sheet: "summary", want: "COUNTA(users!A:A)+SUM(users!B2:B1048576)"},
+		{formula: "notes!A3", sheet: sectionUsers, want: "notes!A3"},
+		{formula: "users!$D$3:$D$6", want: "users!$D$2:$D$4"},
+		{formula: "A3", want: "A3"},
+	}
+	for _, tt := range tests {
+		if got := shiftFormulaRefs(tt.formula, tt.sheet, removed); got != tt.want {
+			t.Errorf("shiftFormulaRefs(%q, %q) = %q, want %q", tt.formula, tt.sheet, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The yamlRecordSource struct locates a record of the input file in the node tree of a YAML file.
+type yamlRecordSource struct {
+	node   *yaml.Node // Record mapping node
+	seq    *yaml.Node // Sequence holding the record, or nil for a document holding a single record
+	doc    int        // Index of the document holding the record
+	parent string     // For records nested in a resource, the resource implied as their parent or entitlement resource
+	member bool       // Whether the record is a grant listed in the members of the entitlement at node
+	grant  GrantData  // For grants, the grant as read, before multiple values are expanded
+}
+
+// The yamlEditor struct applies record changes to the node tree of a YAML or JSON file, so comments, key order and keys
+// the connector does not know survive. Records are located with the same document shapes, key mapping, environment variable
+// interpolation and multi-value expansion as the loader, so each record is found where it was read from.
+type yamlEditor struct {
+	opts     *LoadOptions
+	jsonFile bool                                    // Whether the file is JSON, which holds one document and no single-record documents
+	docs     []*yaml.Node                            // Documents of the file; deleted single-record documents are nil
+	resolved map[*yaml.Node]*yaml.Node               // Copy of each node of docs as the loader reads it, e.g. with ${VAR} references expanded
+	sources  map[string]map[string]*yamlRecordSource // Section, then journal record key
+}
+
+// newYamlEditor creates a yamlEditor without documents.
+func newYamlEditor(opts *LoadOptions) *yamlEditor {
+	return &yamlEditor{opts: opts, resolved: make(map[*yaml.Node]*yaml.Node), sources: make(map[string]map[string]*yamlRecordSource)}
+}
+
+// editYamlFile applies record changes, as computed by diffJournalRecords, to the YAML file at filePath in place.
+// Modified records have their changed keys set or removed, deleted records are removed from their sequence, or their
+// value from a multi-value list, and created records are appended to their section. The changes are spliced into the
+// file's text, see yamlSplicer. The check runs before the file is replaced, see atomicWriteFile.
+func editYamlFile(filePath string, opts *LoadOptions, changes []journalRecordChange, check func() error) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	e := newYamlEditor(opts)
+	preprocessor := newYamlPreprocessor()
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		e.docs = append(e.docs, &doc)
+		// Records are located from the values the loader read, while edits go to the nodes as written
+		if err := preprocessor.process(copyYamlNode(&doc, e.resolved), filepath.Dir(filePath)); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+	splicer := newYamlSplicer(content, e.docs)
+	if err := e.applyChanges(changes); err != nil {
+		return err
+	}
+	out, err := splicer.splice(e.docs)
+	if err != nil {
+		return err
+	}
+
+	return atomicWriteFile(filePath, func(w io.Writer) error {
+		_, err := w.Write(out)
+		return err
+	}, check)
+}
+
+// yamlIndent returns the indentation used by a YAML file: the smallest indentation of its lines, 2 when it has none.
+func yamlIndent(content []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if n := len(line) - len(trimmed); n > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && (indent == 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent < 2 {
+		return 2
+	}
+	return indent
+}
+
+// applyChanges locates the records of the documents and applies record changes to them.
+func (e *yamlEditor) applyChanges(changes []journalRecordChange) error {
+	if err := e.locateRecords(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := e.apply(c); err != nil {
+			return fmt.Errorf("%s record %q: %w", strings.TrimSuffix(c.Section, "s"), c.Key, err)
+		}
+	}
+	return nil
+}
+
+// locateRecords walks the documents and records where each record comes from, keyed as journalRecords keys them.
+// Records are keyed in the order the loader reads them: by document, entitlements declared at the top level before those
+// nested in resources, and grants before the entitlement members expanded into grants.
+func (e *yamlEditor) locateRecords() error {
+	located := make(map[string][]*yamlRecordSource)
+	keys := make(map[string][]string)
+	add := func(section string, key string, source *yamlRecordSource) {
+		located[section] = append(located[section], source)
+		keys[section] = append(keys[section], key)
+	}
+	var entitlementSources []*yamlRecordSource
+	var memberKeys [][]string
+
+	for i, doc := range e.docs {
+		root := doc
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		root = resolveAlias(root)
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+
+		var nestedEntitlements []func()
+		walk := func(section string, node *yaml.Node, seq *yaml.Node) error {
+			return e.locateRecord(section, node, seq, i, "", add, func(source *yamlRecordSource, key string, members []string, nested bool) {
+				record := func() {
+					add(sectionEntitlements, key, source)
+					entitlementSources = append(entitlementSources, source)
+					memberKeys = append(memberKeys, members)
+				}
+				if nested {
+					nestedEntitlements = append(nestedEntitlements, record)
+				} else {
+					record()
+				}
+			})
+		}
+
+		if kind := mappingValue(root, recordKindKey); kind != nil && !e.jsonFile {
+			kind = e.resolved[kind]
+			section, ok := recordKinds[strings.ToLower(strings.TrimSpace(kind.Value))]
+			if !ok {
+				return fmt.Errorf("document %d has unknown record kind %q", i+1, kind.Value)
+			}
+			if err := walk(section, root, nil); err != nil {
+				return err
+			}
+		} else {
+			for j := 0; j+1 < len(root.Content); j += 2 {
+				section := root.Content[j].Value
+				items := resolveAlias(root.Content[j+1])
+				if !isSectionName(section) || items.Kind != yaml.SequenceNode {
+					continue
+				}
+				for _, item := range items.Content {
+					if err := walk(section, resolveAlias(item), items); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, record := range nestedEntitlements {
+			record()
+		}
+	}
+
+	for i, source := range entitlementSources {
+		for _, key := range memberKeys[i] {
+			add(sectionGrants, key, &yamlRecordSource{node: source.node, doc: source.doc, parent: source.parent, member: true})
+		}
+	}
+
+	for _, section := range sectionNames {
+		counter := make(journalKeyCounter)
+		e.sources[section] = make(map[string]*yamlRecordSource, len(located[section]))
+		for i, source := range located[section] {
+			e.sources[section][counter.next(keys[section][i])] = source
+		}
+	}
+	return nil
+}
+
+// locateRecord decodes one record node the way the loader does and reports where its records come from.
+// Entitlements, whose members are keyed after every grant, are reported through addEntitlement with the keys of their member grants.
+// Resources are walked into, for the entitlements and child resources nested in them.
+func (e *yamlEditor) locateRecord(
+	section string,
+	node *yaml.Node,
+	seq *yaml.Node,
+	doc int,
+	parent string,
+	add func(section string, key string, source *yamlRecordSource),
+	addEntitlement func(source *yamlRecordSource, key string, members []string, nested bool),
+) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	source := &yamlRecordSource{node: node, seq: seq, doc: doc, parent: parent}
+	record := copyYamlNode(e.resolved[node], make(map[*yaml.Node]*yaml.Node))
+	if seq == nil {
+		removeMappingKey(record, recordKindKey)
+	}
+	scratch := &LoadedData{}
+
+	switch section {
+	case sectionUsers:
+		canonicalizeRecord(record, e.opts.columnAliases(section))
+		var user UserData
+		if err := record.Decode(&user); err != nil {
+			return err
+		}
+		add(section, journalRecordKey(user), source)
+
+	case sectionResources:
+		canonicalizeRecord(record, e.opts.columnAliases(section))
+		var resource ResourceData
+		if err := record.Decode(&resource); err != nil {
+			return err
+		}
+		if parent != "" {
+			resource.ParentResource = parent
+		}
+		add(section, journalRecordKey(resource), source)
+
+		// The loader reads a resource's own entitlements before its child resources, whichever key comes first
+		for _, nested := range []struct{ key, section string }{{"entitlements", sectionEntitlements}, {"resources", sectionResources}} {
+			items := mappingValue(node, nested.key)
+			if items == nil || resolveAlias(items).Kind != yaml.SequenceNode {
+				continue
+			}
+			items = resolveAlias(items)
+			for _, item := range items.Content {
+				if err := e.locateRecord(nested.section, resolveAlias(item), items, doc, resource.Name, add, addEntitlement); err != nil {
+					return err
+				}
+			}
+		}
+
+	case sectionEntitlements:
+		canonicalizeRecord(record, e.opts.columnAliases(section))
+		var entitlement nestedEntitlementData
+		if err := record.Decode(&entitlement); err != nil {
+			return err
+		}
+		if parent != "" {
+			entitlement.ResourceName = parent
+			entitlement.Members = append(entitlement.Members, entitlement.Grants...)
+		}
+		scratch.Entitlements = []EntitlementData{entitlement.EntitlementData}
+		expandMultiValues(scratch, e.opts.delimiter())
+		members := make([]string, 0, len(scratch.Grants))
+		for _, g := range scratch.Grants {
+			members = append(members, journalRecordKey(g))
+		}
+		addEntitlement(source, journalRecordKey(scratch.Entitlements[0]), members, parent != "")
+
+	case sectionGrants:
+		canonicalizeRecord(record, e.opts.columnAliases(section))
+		if err := record.Decode(&source.grant); err != nil {
+			return err
+		}
+		scratch.Grants = []GrantData{source.grant}
+		expandMultiValues(scratch, e.opts.delimiter())
+		for _, g := range scratch.Grants {
+			add(section, journalRecordKey(g), source)
+		}
+	}
+	return nil
+}
+
+// apply applies one record change to the node tree.
+func (e *yamlEditor) apply(c journalRecordChange) error {
+	if isJSONNull(c.Before) {
+		return e.appendRecord(c.Section, c.After)
+	}
+
+	source, ok := e.sources[c.Section][c.Key]
+	if !ok {
+		return fmt.Errorf("not found in the file")
+	}
+	if isJSONNull(c.After) {
+		return e.deleteRecord(c.Section, source, c.Before)
+	}
+
+	before, err := recordFieldValues(c.Before)
+	if err != nil {
+		return err
+	}
+	after, err := recordFieldValues(c.After)
+	if err != nil {
+		return err
+	}
+	// Grants are identified by all their fields, so only users, resources and entitlements are modified
+	for _, field := range changedFields(c.Section, before, after) {
+		if source.parent != "" && (field == "parent_resource" || field == "resource_name") {
+			return fmt.Errorf("is nested in resource %s and its %s %w", source.parent, field, errNotEditableInPlace)
+		}
+		e.setField(source.node, c.Section, field, after[field])
+	}
+	return nil
+}
+
+// deleteRecord removes the node of a record, or its value from a multi-value list when the node holds other records too.
+func (e *yamlEditor) deleteRecord(section string, source *yamlRecordSource, beforeRaw json.RawMessage) error {
+	var record GrantData
+	if section == sectionGrants {
+		if err := json.Unmarshal(beforeRaw, &record); err != nil {
+			return err
+		}
+	}
+	if source.member {
+		return e.removeMember(source.node, source.parent != "", record.Principal)
+	}
+	if section == sectionGrants {
+		field, value, err := multiValueField(source.grant, record, e.opts.delimiter())
+		if err != nil {
+			return err
+		}
+		if field != "" {
+			for i := 0; i+1 < len(source.node.Content); i += 2 {
+				alias, ok := e.opts.columnAliases(section).resolve(source.node.Content[i].Value)
+				valueNode := resolveAlias(source.node.Content[i+1])
+				if !ok || alias.field != field || valueNode.Kind != yaml.ScalarNode {
+					continue
+				}
+				if !slices.Contains(splitMultiValue(valueNode.Value, e.opts.delimiter()), value) {
+					return fmt.Errorf("grant %s -> %s is listed through an environment variable reference and %w",
+						record.Principal, record.EntitlementId, errNotEditableInPlace)
+				}
+				remaining, remains := removeMultiValues(valueNode.Value, []string{value}, e.opts.delimiter())
+				if remains {
+					source.node.Content[i+1] = scalarNode(remaining, valueNode)
+					return nil
+				}
+			}
+		}
+	}
+
+	if source.seq == nil {
+		e.docs[source.doc] = nil
+		return nil
+	}
+	for i, item := range source.seq.Content {
+		if resolveAlias(item) == source.node {
+			source.seq.Content = append(source.seq.Content[:i], source.seq.Content[i+1:]...)
+			return nil
+		}
+	}
+	return nil // Already removed with the resource it was nested in
+}
+
+// removeMember removes a principal from the members of an entitlement node, listed under its members key or,
+// for entitlements nested in resources, its grants key. Items may list several principals.
+func (e *yamlEditor) removeMember(entitlement *yaml.Node, nested bool, principal string) error {
+	aliases := e.opts.columnAliases(sectionEntitlements)
+	for i := 0; i+1 < len(entitlement.Content); i += 2 {
+		key := entitlement.Content[i].Value
+		if alias, ok := aliases.resolve(key); !(ok && alias.field == "members") && !(nested && key == "grants") {
+			continue
+		}
+		members := resolveAlias(entitlement.Content[i+1])
+		if members.Kind != yaml.SequenceNode {
+			continue
+		}
+		for j, item := range members.Content {
+			item = resolveAlias(item)
+			if item.Kind != yaml.ScalarNode {
+				continue
+			}
+			values := splitMultiValue(e.resolved[item].Value, e.opts.delimiter())
+			if !slices.Contains(values, principal) {
+				continue
+			}
+			if len(values) == 1 {
+				members.Content = append(members.Content[:j], members.Content[j+1:]...)
+				return nil
+			}
+			if !slices.Contains(splitMultiValue(item.Value, e.opts.delimiter()), principal) {
+				return fmt.Errorf("member %s is listed through an environment variable reference and %w", principal, errNotEditableInPlace)
+			}
+			remaining, _ := removeMultiValues(item.Value, []string{principal}, e.opts.delimiter())
+			members.Content[j] = scalarNode(remaining, item)
+			return nil
+		}
+	}
+	return fmt.Errorf("member %s not found in the file", principal)
+}
+
+// appendRecord appends a created record to its section in the first document listing sections, adding the section when missing.
+// A file made of single-record documents gets a new document tagged with the record's kind.
+func (e *yamlEditor) appendRecord(section string, raw json.RawMessage) error {
+	record, err := recordNode(section, raw)
+	if err != nil {
+		return err
+	}
+
+	var recordDocs bool
+	for _, doc := range e.docs {
+		if doc == nil || len(doc.Content) == 0 {
+			continue
+		}
+		root := resolveAlias(doc.Content[0])
+		if root.Kind != yaml.MappingNode {
+			continue
+		}
+		if mappingValue(root, recordKindKey) != nil && !e.jsonFile {
+			recordDocs = true
+			continue
+		}
+		items := mappingValue(root, section)
+		if items == nil {
+			items = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: section}, items)
+		}
+		items = resolveAlias(items)
+		if items.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s is not a list", section)
+		}
+		if len(items.Content) == 0 {
+			items.Style = 0 // An empty flow list, "[]", becomes a block list
+		}
+		items.Content = append(items.Content, record)
+		return nil
+	}
+
+	if e.jsonFile {
+		return fmt.Errorf("the file does not hold a JSON object")
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if recordDocs {
+		kind := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.TrimSuffix(section, "s")}
+		root.Content = append([]*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: recordKindKey}, kind}, record.Content...)
+	} else {
+		root.Content = []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: section},
+			{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{record}},
+		}
+	}
+	e.docs = append(e.docs, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}})
+	return nil
+}
+
+// setField sets or, for an empty value, removes a field of a record node. The key the record already uses for the
+// field is kept, whatever its spelling; a missing field is added with its canonical key. Profile attributes are set in the
+// record's profile mapping, unless the record maps them to keys of its own.
+func (e *yamlEditor) setField(node *yaml.Node, section string, field string, value interface{}) {
+	aliases := e.opts.columnAliases(section)
+	if key, ok := strings.CutPrefix(field, profileFieldPrefix); ok {
+		if i := mappingFieldIndex(node, aliases, profileFieldPrefix+strings.ToLower(key)); i >= 0 {
+			setMappingValue(node, i, key, value)
+			return
+		}
+		profileIndex := mappingFieldIndex(node, aliases, "profile")
+		if profileIndex < 0 {
+			if value == nil {
+				return
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "profile"}, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+			profileIndex = len(node.Content) - 2
+		}
+		profile := node.Content[profileIndex+1]
+		if profile.Kind != yaml.MappingNode {
+			// A shared (anchored) or empty profile is replaced by a copy, so other records keep theirs
+			copied := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if resolved := resolveAlias(profile); resolved.Kind == yaml.MappingNode {
+				copied.Content = append(copied.Content, resolved.Content...)
+			}
+			node.Content[profileIndex+1] = copied
+			profile = copied
+		}
+		i := -1
+		for j := 0; j+1 < len(profile.Content); j += 2 {
+			if profile.Content[j].Value == key {
+				i = j
+			}
+		}
+		setMappingValue(profile, i, key, value)
+		return
+	}
+	setMappingValue(node, mappingFieldIndex(node, aliases, field), field, value)
+}
+
+// mappingFieldIndex returns the index of the key of a record node that resolves to a canonical field, or -1.
+func mappingFieldIndex(node *yaml.Node, aliases columnAliases, field string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if alias, ok := aliases.resolve(node.Content[i].Value); ok && alias.field == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// setMappingValue sets the value of the key at index i of a mapping node, or adds the key when i is -1.
+// A nil value removes the key. The comments of a replaced value are kept.
+func setMappingValue(node *yaml.Node, i int, key string, value interface{}) {
+	if value == nil {
+		if i >= 0 {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		}
+		return
+	}
+	valueNode := &yaml.Node{}
+	if err := valueNode.Encode(value); err != nil {
+		valueNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: cellText(value)}
+	}
+	if i < 0 {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+		return
+	}
+	old := node.Content[i+1]
+	valueNode.HeadComment, valueNode.LineComment, valueNode.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	node.Content[i+1] = valueNode
+}
+
+// scalarNode returns a string scalar replacing another, keeping its comments.
+func scalarNode(value string, replaced *yaml.Node) *yaml.Node {
+	return &yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Tag:         "!!str",
+		Value:       value,
+		Style:       replaced.Style,
+		HeadComment: replaced.HeadComment,
+		LineComment: replaced.LineComment,
+		FootComment: replaced.FootComment,
+	}
+}
+
+// recordNode encodes a created record as a mapping node with its canonical keys, leaving out empty fields.
+func recordNode(section string, raw json.RawMessage) (*yaml.Node, error) {
+	var record interface{}
+	switch section {
+	case sectionUsers:
+		record = &UserData{}
+	case sectionResources:
+		record = &ResourceData{}
+	case sectionEntitlements:
+		record = &EntitlementData{}
+	case sectionGrants:
+		record = &GrantData{}
+	default:
+		return nil, fmt.Errorf("unknown section %q", section)
+	}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(record); err != nil {
+		return nil, err
+	}
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		empty := (value.Kind == yaml.ScalarNode && (value.Value == "" || value.Tag == "!!null")) ||
+			((value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode) && len(value.Content) == 0)
+		if !empty {
+			content = append(content, node.Content[i], value)
+		}
+	}
+	node.Content = content
+	return node, nil
+}
+
+// removeMappingKey removes a key and its value from a mapping node.
+func removeMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// copyYamlNode returns a deep copy of a node, including the nodes its aliases refer to, so it can be canonicalized
+// without changing the original tree.
+func copyYamlNode(n *yaml.Node, copies map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if c, ok := copies[n]; ok {
+		return c
+	}
+	c := *n
+	copies[n] = &c
+	c.Alias = copyYamlNode(n.Alias, copies)
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyYamlNode(child, copies)
+	}
+	return &c
+}
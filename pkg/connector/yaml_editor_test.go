@@ -0,0 +1,376 @@
+package connector
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEditYamlFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		mutate  func(t *testing.T, data *LoadedData)
+	}{
+		{
+			name: "multi-value cells",
+			content: `resources:
+  - resource_type: team
+    name: eng
+  - resource_type: team
+    name: ops
+grants:
+  - principal: "alice; bob"
+    entitlement_id: eng:member
+  - principal: carol
+    entitlement_id: "eng:member; ops:member"
+  - principal: dave
+    entitlement_id: ops:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "carol -> ops:member")
+			},
+		},
+		{
+			name: "members",
+			content: `entitlements:
+  - resource_name: eng
+    entitlement: member
+    members:
+      - alice
+      - "bob; carol"
+  - resource_name: ops
+    entitlement: member
+    members: [dave]
+grants:
+  - principal: erin
+    entitlement_id: eng:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "carol -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "dave -> ops:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "frank", EntitlementId: "ops:member"})
+			},
+		},
+		{
+			name: "nested entitlements",
+			content: `resources:
+  - resource_type: team
+    name: eng
+    resources:
+      - resource_type: team
+        name: platform
+        entitlements:
+          - entitlement: member
+            members: [alice, bob]
+    entitlements:
+      - entitlement: member
+        display_name: Member
+        grants: [alice]
+      - entitlement: admin
+        members: ["carol; alice"]
+entitlements:
+  - resource_name: ops
+    entitlement: member
+    members: [alice]
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:admin")
+				data.Grants = removeRecord(t, data.Grants, "bob -> platform:member")
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				for i := range data.Entitlements {
+					if journalRecordKey(data.Entitlements[i]) == "eng:member" {
+						data.Entitlements[i].Description = "Engineering team members"
+					}
+				}
+			},
+		},
+		{
+			name: "duplicate keys",
+			content: `entitlements:
+  - resource_name: eng
+    entitlement: member
+    members: [alice, alice]
+grants:
+  - principal: alice
+    entitlement_id: eng:member
+  - principal: "alice; bob"
+    entitlement_id: eng:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				// The grants listed after the first alice grant move up, so the last duplicate key disappears
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+			},
+		},
+		{
+			name: "duplicate keys in nested resources",
+			content: `resources:
+  - resource_type: folder
+    name: shared
+    resources:
+      - resource_type: folder
+        name: shared
+        entitlements:
+          - entitlement: reader
+            members: [bob]
+    entitlements:
+      - entitlement: reader
+        members: [alice]
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				// The outer folder's entitlement is read first, whichever key comes first in the file
+				data.Entitlements[0].Description = "Outer folder readers"
+				data.Grants = removeRecord(t, data.Grants, "alice -> shared:reader")
+			},
+		},
+		{
+			name: "deletes next to creates",
+			content: `users:
+  - name: alice
+    display_name: Alice
+  - name: bob
+    display_name: Bob
+grants:
+  - principal: alice
+    entitlement_id: eng:member
+  - principal: bob
+    entitlement_id: eng:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users = removeRecord(t, data.Users, "bob")
+				data.Users = append(data.Users, UserData{Name: "bobby", DisplayName: "Bob"})
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "bobby", EntitlementId: "eng:member"})
+			},
+		},
+		{
+			name: "single-record documents",
+			content: `kind: user
+name: alice
+display_name: Alice
+---
+kind: resource
+resource_type: team
+name: eng
+entitlements:
+  - entitlement: member
+    members: [alice]
+---
+kind: grant
+principal: "alice; bob"
+entitlement_id: ops:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users[0].DisplayName = "Alice Smith"
+				data.Grants = removeRecord(t, data.Grants, "alice -> ops:member")
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "carol", EntitlementId: "eng:member"})
+			},
+		},
+		{
+			name: "environment variables",
+			content: `resources:
+  - resource_type: team
+    name: ${TEAM}
+    description: ${TEAM_DESCRIPTION:-Team}
+grants:
+  - principal: alice
+    entitlement_id: ${TEAM}:member
+  - principal: "${ADMIN}; bob"
+    entitlement_id: ${TEAM}:admin
+`,
+			env: map[string]string{"TEAM": "eng", "ADMIN": "carol"},
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Resources[0].DisplayName = "Engineering"
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:admin")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			filePath := writeTestFile(t, "access.yaml", tt.content)
+			assertEditRoundTrip(t, filePath, nil, func(changes []journalRecordChange) error {
+				return editYamlFile(filePath, nil, changes, nil)
+			}, tt.mutate)
+		})
+	}
+}
+
+func TestEditYamlFileRefusesValueFromVariable(t *testing.T) {
+	t.Setenv("ADMINS", "alice; bob")
+	filePath := writeTestFile(t, "access.yaml", `grants:
+  - principal: "${ADMINS}; carol"
+    entitlement_id: eng:admin
+`)
+
+	err := editYamlFile(filePath, nil, []journalRecordChange{{
+		Section: sectionGrants,
+		Key:     "bob -> eng:admin",
+		Before:  []byte(`{"principal":"bob","entitlement_id":"eng:admin"}`),
+	}}, nil)
+	if !errors.Is(err, errNotEditableInPlace) {
+		t.Fatalf("got error %v, want %v", err, errNotEditableInPlace)
+	}
+}
+
+func TestEditYamlFileKeepsLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mutate  func(t *testing.T, data *LoadedData)
+		want    string
+	}{
+		{
+			name: "comments, blank lines and indentation",
+			content: `# Access for the platform team
+
+users:
+- name: alice   # team lead
+  display_name: Alice
+- name: bob
+  display_name: Bob
+  profile: {department: R&D}
+
+resources:
+    - resource_type: team
+      name: eng
+
+grants:
+    # keep sorted
+    - principal: alice
+      entitlement_id: eng:member
+    - principal: bob
+      entitlement_id: eng:member
+    - principal: bob
+      entitlement_id: eng:admin
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users[0].DisplayName = "Alice Smith"
+				data.Users[1].Profile["level"] = 3
+				data.Users = append(data.Users, UserData{Name: "carol", DisplayName: "Carol"})
+				data.Grants = removeRecord(t, data.Grants, "alice -> eng:member")
+				data.Grants = append(data.Grants, GrantData{Principal: "carol", EntitlementId: "eng:member"})
+			},
+			want: `# Access for the platform team
+
+users:
+- name: alice   # team lead
+  display_name: Alice Smith
+- name: bob
+  display_name: Bob
+  profile: {department: R&D, level: 3}
+- name: carol
+  display_name: Carol
+
+resources:
+    - resource_type: team
+      name: eng
+
+grants:
+    # keep sorted
+    - principal: bob
+      entitlement_id: eng:member
+    - principal: bob
+      entitlement_id: eng:admin
+    - principal: carol
+      entitlement_id: eng:member
+`,
+		},
+		{
+			name: "members and emptied lists",
+			content: `entitlements:
+  - resource_name: eng
+    entitlement: member
+    description: Engineers   # shown in the catalog
+    members: [alice, "bob; carol"]  # reviewed quarterly
+grants:
+  - principal: dave
+    entitlement_id: eng:member
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Entitlements[0].Description = ""
+				data.Grants = removeRecord(t, data.Grants, "bob -> eng:member")
+				data.Grants = removeRecord(t, data.Grants, "dave -> eng:member")
+			},
+			want: `entitlements:
+  - resource_name: eng
+    entitlement: member
+    members: [alice, "carol"]  # reviewed quarterly
+grants: []
+`,
+		},
+		{
+			name: "first key and new section",
+			content: `users:
+  - display_name: Alice
+    name: alice
+    email: alice@example.com
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users[0].DisplayName = ""
+				data.Grants = append(data.Grants, GrantData{Principal: "alice", EntitlementId: "eng:member"})
+			},
+			want: `users:
+  - name: alice
+    email: alice@example.com
+grants:
+  - principal: alice
+    entitlement_id: eng:member
+`,
+		},
+		{
+			name: "single-record documents",
+			content: `# Generated from the HR export
+kind: user
+name: alice
+---
+kind: user
+name: bob
+---
+# Contractors
+kind: user
+name: carol
+`,
+			mutate: func(t *testing.T, data *LoadedData) {
+				data.Users = removeRecord(t, data.Users, "bob")
+				data.Users = append(data.Users, UserData{Name: "dave"})
+			},
+			want: `# Generated from the HR export
+kind: user
+name: alice
+---
+# Contractors
+kind: user
+name: carol
+---
+kind: user
+name: dave
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := writeTestFile(t, "access.yaml", tt.content)
+			assertEditRoundTrip(t, filePath, nil, func(changes []journalRecordChange) error {
+				return editYamlFile(filePath, nil, changes, nil)
+			}, tt.mutate)
+
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", content, tt.want)
+			}
+		})
+	}
+}
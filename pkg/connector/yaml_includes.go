@@ -0,0 +1,293 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// includeTag marks a scalar whose value is the path of a YAML file to insert in its place.
+	includeTag = "!include"
+	// includeKey is a mapping key whose value lists YAML files to merge into the containing mapping.
+	includeKey = "$include"
+)
+
+// The yamlPreprocessor struct resolves includes and environment variable references in a YAML node tree.
+// It tracks the chain of files being included so that cycles are reported instead of recursing forever.
+type yamlPreprocessor struct {
+	lookupEnv  func(string) (string, bool)
+	stack      []string
+	included   []string // Absolute paths of every included file, in the order they were read
+	unresolved []string // Names of unset variables referenced without a default, left as written
+}
+
+// newYamlPreprocessor creates a yamlPreprocessor that reads environment variables from the process environment.
+func newYamlPreprocessor() *yamlPreprocessor {
+	return &yamlPreprocessor{lookupEnv: os.LookupEnv}
+}
+
+// parseFile reads a YAML file, parses it into a node tree and resolves its includes and environment variable references.
+func (p *yamlPreprocessor) parseFile(filePath string) (*yaml.Node, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path %s: %w", filePath, err)
+	}
+	for _, seen := range p.stack {
+		if seen == absPath {
+			return nil, fmt.Errorf("include cycle detected: %s -> %s", strings.Join(p.stack, " -> "), absPath)
+		}
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read YAML file %s: %w", filePath, err)
+	}
+
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML data from %s: %w", filePath, err)
+	}
+
+	p.stack = append(p.stack, absPath)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+
+	err = p.process(&doc, filepath.Dir(absPath))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return &doc, nil
+}
+
+// parseStream reads a YAML file that may hold several documents separated by "---".
+// Each document is parsed and preprocessed in turn and passed to fn, so only one document is held in memory at a time.
+func (p *yamlPreprocessor) parseStream(filePath string, fn func(doc *yaml.Node) error) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", filePath, err)
+	}
+
+	f, err := os.Open(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read YAML file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	p.stack = append(p.stack, absPath)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc yaml.Node
+		err = decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal YAML data from %s: %w", filePath, err)
+		}
+
+		err = p.process(&doc, filepath.Dir(absPath))
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		err = fn(&doc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+}
+
+// process resolves includes and environment variable references in a node and its children.
+// Paths are resolved relative to baseDir, the directory of the file containing the node.
+func (p *yamlPreprocessor) process(n *yaml.Node, baseDir string) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			err := p.process(c, baseDir)
+			if err != nil {
+				return err
+			}
+		}
+
+	case yaml.ScalarNode:
+		if n.Tag == includeTag {
+			included, err := p.include(n.Value, baseDir)
+			if err != nil {
+				return err
+			}
+			*n = *included
+			return nil
+		}
+		n.Value = p.interpolate(n.Value)
+
+	case yaml.SequenceNode:
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for _, c := range n.Content {
+			spliced := c.Kind == yaml.ScalarNode && c.Tag == includeTag
+			err := p.process(c, baseDir)
+			if err != nil {
+				return err
+			}
+			// An included sequence inside a sequence contributes its items rather than a nested list.
+			if spliced && c.Kind == yaml.SequenceNode {
+				content = append(content, c.Content...)
+				continue
+			}
+			content = append(content, c)
+		}
+		n.Content = content
+
+	case yaml.MappingNode:
+		var includes []*yaml.Node
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == includeKey {
+				includes = append(includes, value)
+				continue
+			}
+			err := p.process(value, baseDir)
+			if err != nil {
+				return err
+			}
+			content = append(content, key, value)
+		}
+		n.Content = content
+
+		for _, value := range includes {
+			paths := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				paths = value.Content
+			}
+			for _, pathNode := range paths {
+				if pathNode.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: %s expects a path or a list of paths", pathNode.Line, includeKey)
+				}
+				included, err := p.include(pathNode.Value, baseDir)
+				if err != nil {
+					return err
+				}
+				if included.Kind != yaml.MappingNode {
+					return fmt.Errorf("line %d: %s file %s must contain a mapping", pathNode.Line, includeKey, pathNode.Value)
+				}
+				mergeMappings(n, included)
+			}
+		}
+
+	case yaml.AliasNode:
+		// Aliases point at nodes that are processed where their anchor is defined.
+	}
+	return nil
+}
+
+// include loads the file referenced by an include path and returns its root node.
+func (p *yamlPreprocessor) include(rawPath string, baseDir string) (*yaml.Node, error) {
+	includePath := p.interpolate(strings.TrimSpace(rawPath))
+	if includePath == "" {
+		return nil, fmt.Errorf("empty include path")
+	}
+	if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(baseDir, includePath)
+	}
+
+	doc, err := p.parseFile(includePath)
+	if err != nil {
+		return nil, err
+	}
+	if absPath, err := filepath.Abs(includePath); err == nil {
+		p.included = append(p.included, absPath)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// interpolate replaces ${VAR} and ${VAR:-default} references with environment variable values.
+// "$${" produces a literal "${". A reference to an unset variable without a default, as well as an empty or unterminated
+// one, is left as written, so text such as shell snippets in descriptions loads unchanged; the names of unset variables
+// are recorded in unresolved.
+func (p *yamlPreprocessor) interpolate(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+
+	var sb strings.Builder
+	for {
+		idx := strings.Index(value, "${")
+		if idx == -1 {
+			sb.WriteString(value)
+			return sb.String()
+		}
+		if idx > 0 && value[idx-1] == '$' {
+			sb.WriteString(value[:idx-1])
+			sb.WriteString("${")
+			value = value[idx+2:]
+			continue
+		}
+		end := strings.Index(value[idx:], "}")
+		if end == -1 {
+			sb.WriteString(value)
+			return sb.String()
+		}
+		sb.WriteString(value[:idx])
+
+		expr := value[idx+2 : idx+end]
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		envValue, ok := p.lookupEnv(name)
+		switch {
+		case name == "":
+			sb.WriteString(value[idx : idx+end+1])
+		case ok:
+			sb.WriteString(envValue)
+		case hasDefault:
+			sb.WriteString(def)
+		default:
+			sb.WriteString(value[idx : idx+end+1])
+			p.addUnresolved(name)
+		}
+		value = value[idx+end+1:]
+	}
+}
+
+// addUnresolved records the name of an unset variable referenced without a default, once.
+func (p *yamlPreprocessor) addUnresolved(name string) {
+	for _, seen := range p.unresolved {
+		if seen == name {
+			return
+		}
+	}
+	p.unresolved = append(p.unresolved, name)
+}
+
+// mergeMappings merges an included mapping into the including mapping.
+// Sequences present in both are concatenated (included items first); for any other key the including mapping wins.
+func mergeMappings(dst *yaml.Node, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			existing.Content = append(append([]*yaml.Node{}, value.Content...), existing.Content...)
+		}
+	}
+}
+
+// mappingValue returns the value node for a key in a mapping node, or nil if the key is absent.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,623 @@
+package connector
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The yamlSplice struct replaces the bytes of a file's content from start to end with text; start equals end for an insertion.
+type yamlSplice struct {
+	start int
+	end   int
+	text  string
+}
+
+// The yamlSplicer struct writes the changes made to the node trees of a YAML file back into the file's text. The node trees
+// are compared with the children their collections held when the file was read, and only the text of added, removed and
+// replaced nodes is spliced, so comments, blank lines and the indentation of everything else stay as they are. The lines
+// of a removed sequence item start at its dash, so comments above it stay with the item that follows.
+type yamlSplicer struct {
+	content   []byte
+	lines     []int                       // Offset of the start of each line
+	docs      []*yaml.Node                // Documents as read
+	contents  map[*yaml.Node][]*yaml.Node // Children of each collection node as read
+	styles    map[*yaml.Node]yaml.Style   // Style of each collection node as read
+	lastLine  map[*yaml.Node]int          // Last line on which a node or one of its descendants starts
+	limit     map[*yaml.Node]int          // Line on which the node following a node and its descendants starts
+	indent    int                         // Indentation of nested mappings
+	seqIndent int                         // Indentation of block sequences relative to their key
+	splices   []yamlSplice
+}
+
+// newYamlSplicer records the layout of the documents of a YAML file, as decoded from content, before they are changed.
+func newYamlSplicer(content []byte, docs []*yaml.Node) *yamlSplicer {
+	s := &yamlSplicer{
+		content:   content,
+		lines:     []int{0},
+		docs:      slices.Clone(docs),
+		contents:  make(map[*yaml.Node][]*yaml.Node),
+		styles:    make(map[*yaml.Node]yaml.Style),
+		lastLine:  make(map[*yaml.Node]int),
+		limit:     make(map[*yaml.Node]int),
+		indent:    yamlIndent(content),
+		seqIndent: -1,
+	}
+	for i, c := range content {
+		if c == '\n' && i+1 < len(content) {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+
+	for i, doc := range docs {
+		docEnd := len(s.lines) + 1
+		if i+1 < len(docs) {
+			docEnd = docs[i+1].Line
+		}
+		// Nodes are walked in the order they appear in the text; each is limited by the first one after its descendants
+		var order []*yaml.Node
+		next := make(map[*yaml.Node]int)
+		var walk func(n *yaml.Node) int
+		walk = func(n *yaml.Node) int {
+			order = append(order, n)
+			if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+				s.contents[n] = slices.Clone(n.Content)
+				s.styles[n] = n.Style
+			}
+			if n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0 && s.seqIndent < 0 {
+				for j := 0; j+1 < len(n.Content); j += 2 {
+					key, value := n.Content[j], n.Content[j+1]
+					if value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0 && value.Line > key.Line {
+						s.seqIndent = max(value.Column-key.Column, 0)
+						break
+					}
+				}
+			}
+			last := n.Line
+			for _, c := range n.Content {
+				last = max(last, walk(c))
+			}
+			s.lastLine[n] = last
+			next[n] = len(order)
+			return last
+		}
+		walk(doc)
+		for _, n := range order {
+			if j := next[n]; j < len(order) {
+				s.limit[n] = order[j].Line
+			} else {
+				s.limit[n] = docEnd
+			}
+		}
+	}
+	if s.seqIndent < 0 {
+		s.seqIndent = s.indent
+	}
+	return s
+}
+
+// splice returns the file's content with the changes made to its documents since they were read. Removed documents
+// are nil, and added documents follow the documents read.
+func (s *yamlSplicer) splice(docs []*yaml.Node) ([]byte, error) {
+	for i, doc := range s.docs {
+		if docs[i] == nil {
+			s.add(s.lineStart(doc.Line), s.lineStart(s.limit[doc]), "")
+			continue
+		}
+		for _, root := range doc.Content {
+			if err := s.diff(root, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, doc := range docs[len(s.docs):] {
+		if doc == nil || len(doc.Content) == 0 {
+			continue
+		}
+		text, err := s.block(doc.Content[0], 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.docs) > 0 {
+			text = "---\n" + text
+		}
+		s.insert(len(s.content), text)
+	}
+
+	sort.SliceStable(s.splices, func(i, j int) bool { return s.splices[i].start < s.splices[j].start })
+	var out bytes.Buffer
+	pos := 0
+	for _, splice := range s.splices {
+		if splice.start > pos {
+			out.Write(s.content[pos:splice.start])
+		}
+		out.WriteString(splice.text)
+		pos = max(pos, splice.end)
+	}
+	out.Write(s.content[pos:])
+	return out.Bytes(), nil
+}
+
+// diff splices the changes made to a node read from the file. Scalars and aliases are replaced rather than changed,
+// and flow collections are written again as a whole. The key is the mapping key of the node, if any.
+func (s *yamlSplicer) diff(n *yaml.Node, key *yaml.Node) error {
+	old, ok := s.contents[n]
+	if !ok {
+		return nil
+	}
+	if s.styles[n]&yaml.FlowStyle != 0 {
+		if s.changed(n) {
+			return s.replace(key, n, n)
+		}
+		return nil
+	}
+	if n.Kind == yaml.MappingNode {
+		return s.diffMapping(n, old)
+	}
+	return s.diffSequence(n, key, old)
+}
+
+// changed reports whether a collection read from the file, or one of its descendants, was changed.
+func (s *yamlSplicer) changed(n *yaml.Node) bool {
+	old, ok := s.contents[n]
+	if !ok {
+		return false
+	}
+	if s.styles[n] != n.Style || !slices.Equal(old, n.Content) {
+		return true
+	}
+	for _, c := range n.Content {
+		if s.changed(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffMapping splices the changes made to a block mapping: removed keys have their lines removed, added keys are
+// inserted after its last line, and replaced values have their text replaced.
+func (s *yamlSplicer) diffMapping(n *yaml.Node, old []*yaml.Node) error {
+	kept := make(map[*yaml.Node]*yaml.Node, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		kept[n.Content[i]] = n.Content[i+1]
+	}
+	values := make(map[*yaml.Node]*yaml.Node, len(old)/2)
+	for i := 0; i+1 < len(old); i += 2 {
+		values[old[i]] = old[i+1]
+	}
+
+	for i := 0; i+1 < len(old); i += 2 {
+		key, value := old[i], old[i+1]
+		if _, ok := kept[key]; ok {
+			continue
+		}
+		start := s.lineStart(key.Line)
+		if keyStart := s.offset(key.Line, key.Column); strings.TrimSpace(string(s.content[start:keyStart])) != "" {
+			// The key follows the dash of a sequence item: the next key kept takes its place
+			j := i + 2
+			for j+1 < len(old) && kept[old[j]] == nil {
+				j += 2
+			}
+			if j+1 < len(old) {
+				s.add(keyStart, s.offset(old[j].Line, old[j].Column), "")
+			} else {
+				s.add(keyStart, s.lineEnd(s.endLine(old[len(old)-1])), "{}")
+			}
+			i = j - 2
+			continue
+		}
+		s.add(start, s.lineStart(s.endLine(value)+1), "")
+	}
+
+	column := 0
+	if len(old) > 0 {
+		column = old[0].Column - 1
+	}
+	var added strings.Builder
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		oldValue, ok := values[key]
+		switch {
+		case !ok:
+			text, err := s.pair(key, value, column)
+			if err != nil {
+				return err
+			}
+			added.WriteString(text)
+		case oldValue == value:
+			if err := s.diff(value, key); err != nil {
+				return err
+			}
+		default:
+			if err := s.replace(key, oldValue, value); err != nil {
+				return err
+			}
+		}
+	}
+	if added.Len() > 0 {
+		s.insert(s.lineStart(s.endLine(n)+1), added.String())
+	}
+	return nil
+}
+
+// diffSequence splices the changes made to a block sequence: removed items have their lines removed, from their dash on,
+// and are replaced by the items added in their place; other added items are inserted after its last line.
+// A sequence left empty is written as [].
+func (s *yamlSplicer) diffSequence(n *yaml.Node, key *yaml.Node, old []*yaml.Node) error {
+	if len(old) == 0 {
+		return nil
+	}
+	if len(n.Content) == 0 && key != nil {
+		s.add(s.keyEnd(key), s.lineEnd(s.endLine(n)), " []")
+		return nil
+	}
+	kept := make(map[*yaml.Node]bool, len(n.Content))
+	for _, item := range n.Content {
+		kept[item] = true
+	}
+	read := make(map[*yaml.Node]bool, len(old))
+	for _, item := range old {
+		read[item] = true
+	}
+	dash := s.dashOffset(old[0])
+	column := dash - s.lineStart(s.lineOf(dash))
+
+	j := 0
+	for _, item := range old {
+		if !kept[item] {
+			if j < len(n.Content) && !read[n.Content[j]] {
+				start, end := s.span(item)
+				text, err := s.valueText(n.Content[j], item.Column-1)
+				if err != nil {
+					return err
+				}
+				s.add(start, end, text)
+				j++
+				continue
+			}
+			s.add(s.lineStart(s.lineOf(s.dashOffset(item))), s.lineStart(s.endLine(item)+1), "")
+			continue
+		}
+		var inserted strings.Builder
+		for ; j < len(n.Content) && n.Content[j] != item; j++ {
+			text, err := s.item(n.Content[j], column)
+			if err != nil {
+				return err
+			}
+			inserted.WriteString(text)
+		}
+		if inserted.Len() > 0 {
+			s.insert(s.lineStart(s.lineOf(s.dashOffset(item))), inserted.String())
+		}
+		j++
+		if err := s.diff(item, nil); err != nil {
+			return err
+		}
+	}
+	var added strings.Builder
+	for ; j < len(n.Content); j++ {
+		text, err := s.item(n.Content[j], column)
+		if err != nil {
+			return err
+		}
+		added.WriteString(text)
+	}
+	if added.Len() > 0 {
+		s.insert(s.lineStart(s.endLine(n)+1), added.String())
+	}
+	return nil
+}
+
+// replace replaces the text of a node read from the file with a node. A block collection replacing a value written on
+// the line of its key starts on the next line.
+func (s *yamlSplicer) replace(key, old, n *yaml.Node) error {
+	start, end := s.span(old)
+	if isInlineYamlNode(n) || key == nil || old.Line > key.Line {
+		text, err := s.valueText(n, old.Column-1)
+		if err != nil {
+			return err
+		}
+		s.add(start, end, text)
+		return nil
+	}
+	column := key.Column - 1 + s.indent
+	if n.Kind == yaml.SequenceNode {
+		column = key.Column - 1 + s.seqIndent
+	}
+	text, err := s.block(n, column)
+	if err != nil {
+		return err
+	}
+	for start > 0 && (s.content[start-1] == ' ' || s.content[start-1] == '\t') {
+		start--
+	}
+	s.add(start, end, "\n"+strings.TrimSuffix(text, "\n"))
+	return nil
+}
+
+// add records a splice.
+func (s *yamlSplicer) add(start, end int, text string) {
+	s.splices = append(s.splices, yamlSplice{start: start, end: end, text: text})
+}
+
+// insert records the insertion of lines at an offset, on a line of their own.
+func (s *yamlSplicer) insert(offset int, text string) {
+	if offset >= len(s.content) && len(s.content) > 0 && s.content[len(s.content)-1] != '\n' {
+		text = "\n" + text
+	}
+	s.add(offset, offset, text)
+}
+
+// pair returns the lines of a mapping key and its value, indented to column. Block sequences are indented like the file's.
+func (s *yamlSplicer) pair(key, value *yaml.Node, column int) (string, error) {
+	if value.Kind != yaml.SequenceNode || isInlineYamlNode(value) {
+		return s.block(&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}, column)
+	}
+	keyText, err := s.inline(key)
+	if err != nil {
+		return "", err
+	}
+	items, err := s.block(value, column+s.seqIndent)
+	if err != nil {
+		return "", err
+	}
+	return strings.Repeat(" ", column) + keyText + ":\n" + items, nil
+}
+
+// item returns the lines of a sequence item, with its dash at column.
+func (s *yamlSplicer) item(n *yaml.Node, column int) (string, error) {
+	return s.block(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{n}}, column)
+}
+
+// valueText returns the text of a node starting at column, without a final line break.
+func (s *yamlSplicer) valueText(n *yaml.Node, column int) (string, error) {
+	if isInlineYamlNode(n) {
+		return s.inline(n)
+	}
+	text, err := s.block(n, column)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(text[column:], "\n"), nil
+}
+
+// inline returns the text of a scalar, alias or collection written in flow style, on one line.
+func (s *yamlSplicer) inline(n *yaml.Node) (string, error) {
+	c := stripYamlComments(n)
+	if c.Kind == yaml.MappingNode || c.Kind == yaml.SequenceNode {
+		c.Style |= yaml.FlowStyle
+	}
+	text, err := s.encode(c)
+	if err != nil {
+		return "", err
+	}
+	if text = strings.TrimSuffix(text, "\n"); strings.Contains(text, "\n") && c.Kind == yaml.ScalarNode {
+		c.Style = yaml.DoubleQuotedStyle
+		if text, err = s.encode(c); err != nil {
+			return "", err
+		}
+		text = strings.TrimSuffix(text, "\n")
+	}
+	return text, nil
+}
+
+// block returns the lines of a node written in block style, indented to column.
+func (s *yamlSplicer) block(n *yaml.Node, column int) (string, error) {
+	text, err := s.encode(stripYamlComments(n))
+	if err != nil {
+		return "", err
+	}
+	prefix := strings.Repeat(" ", column)
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// encode encodes a node with the file's indentation.
+func (s *yamlSplicer) encode(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(s.indent)
+	if err := encoder.Encode(n); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// isInlineYamlNode reports whether a node is written on one line: a scalar, an alias, a flow or an empty collection.
+func isInlineYamlNode(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode || n.Kind == yaml.AliasNode || n.Style&yaml.FlowStyle != 0 || len(n.Content) == 0
+}
+
+// stripYamlComments returns a copy of a node without comments; the comments of nodes read from the file stay in its text.
+func stripYamlComments(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = stripYamlComments(child)
+	}
+	return &c
+}
+
+// span returns the offsets of the text of a node read from the file, including its anchor and tag.
+// The text of a block collection ends with its last line holding more than a comment.
+func (s *yamlSplicer) span(n *yaml.Node) (int, int) {
+	start := s.offset(n.Line, n.Column)
+	style := n.Style
+	if read, ok := s.styles[n]; ok {
+		style = read
+	}
+	switch {
+	case n.Kind == yaml.AliasNode:
+		return start, start + 1 + len(n.Value)
+	case n.Kind != yaml.ScalarNode && style&yaml.FlowStyle != 0:
+		return start, s.flowEnd(s.skipProperties(start))
+	case n.Kind == yaml.ScalarNode && style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		return start, s.quotedEnd(s.skipProperties(start))
+	case n.Kind == yaml.ScalarNode && style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 && s.endLine(n) == n.Line:
+		return start, s.plainEnd(s.skipProperties(start))
+	}
+	return start, s.lineEnd(s.endLine(n))
+}
+
+// endLine returns the last line of a node read from the file that holds more than a comment,
+// before the node following it starts.
+func (s *yamlSplicer) endLine(n *yaml.Node) int {
+	end := s.lastLine[n]
+	for line := end + 1; line < s.limit[n] && line <= len(s.lines); line++ {
+		text := strings.TrimSpace(s.lineText(line))
+		if text != "" && !strings.HasPrefix(text, "#") && text != "-" && text != "..." {
+			end = line
+		}
+	}
+	return end
+}
+
+// keyEnd returns the offset just after the colon following a mapping key.
+func (s *yamlSplicer) keyEnd(key *yaml.Node) int {
+	i := s.skipProperties(s.offset(key.Line, key.Column))
+	if i < len(s.content) && (s.content[i] == '"' || s.content[i] == '\'') {
+		i = s.quotedEnd(i)
+	}
+	for ; i < len(s.content); i++ {
+		if s.content[i] == ':' && (i+1 == len(s.content) || strings.IndexByte(" \t\r\n", s.content[i+1]) >= 0) {
+			return i + 1
+		}
+	}
+	return len(s.content)
+}
+
+// dashOffset returns the offset of the dash introducing a block sequence item.
+func (s *yamlSplicer) dashOffset(item *yaml.Node) int {
+	i := s.offset(item.Line, item.Column) - 1
+	for i > 0 && strings.IndexByte(" \t\r\n", s.content[i]) >= 0 {
+		i--
+	}
+	return max(i, 0)
+}
+
+// skipProperties returns the offset of the text of a node after its anchor and tag, if any.
+func (s *yamlSplicer) skipProperties(i int) int {
+	for i < len(s.content) && (s.content[i] == '&' || s.content[i] == '!') {
+		for i < len(s.content) && strings.IndexByte(" \t\r\n", s.content[i]) < 0 {
+			i++
+		}
+		for i < len(s.content) && (s.content[i] == ' ' || s.content[i] == '\t') {
+			i++
+		}
+	}
+	return i
+}
+
+// quotedEnd returns the offset after the quoted scalar starting at i.
+func (s *yamlSplicer) quotedEnd(i int) int {
+	quote := s.content[i]
+	for i++; i < len(s.content); i++ {
+		switch c := s.content[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote && quote == '\'' && i+1 < len(s.content) && s.content[i+1] == '\'':
+			i++
+		case c == quote:
+			return i + 1
+		}
+	}
+	return len(s.content)
+}
+
+// plainEnd returns the offset after the plain scalar starting at i, which ends its line but for a comment.
+func (s *yamlSplicer) plainEnd(i int) int {
+	end := i
+	for j := i; j < len(s.content) && s.content[j] != '\n'; j++ {
+		c := s.content[j]
+		if c == '#' && j > i && (s.content[j-1] == ' ' || s.content[j-1] == '\t') {
+			break
+		}
+		if c != ' ' && c != '\t' && c != '\r' {
+			end = j + 1
+		}
+	}
+	return end
+}
+
+// flowEnd returns the offset after the flow collection starting at i, skipping quoted scalars and comments.
+func (s *yamlSplicer) flowEnd(i int) int {
+	depth := 0
+	for ; i < len(s.content); i++ {
+		switch c := s.content[i]; c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case '"', '\'':
+			// A quote opens a scalar only where a scalar starts
+			j := i - 1
+			for j >= 0 && strings.IndexByte(" \t\r\n", s.content[j]) >= 0 {
+				j--
+			}
+			if j < 0 || strings.IndexByte("[{,:", s.content[j]) >= 0 {
+				i = s.quotedEnd(i) - 1
+			}
+		case '#':
+			if s.content[i-1] == ' ' || s.content[i-1] == '\t' || s.content[i-1] == '\n' {
+				for i < len(s.content) && s.content[i] != '\n' {
+					i++
+				}
+			}
+		}
+	}
+	return len(s.content)
+}
+
+// offset returns the offset of a 1-based line and column, as yaml.v3 counts them: in characters.
+func (s *yamlSplicer) offset(line, column int) int {
+	i := s.lineStart(line)
+	for ; column > 1 && i < len(s.content) && s.content[i] != '\n'; column-- {
+		_, size := utf8.DecodeRune(s.content[i:])
+		i += size
+	}
+	return i
+}
+
+// lineOf returns the 1-based line holding an offset.
+func (s *yamlSplicer) lineOf(offset int) int {
+	return sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset })
+}
+
+// lineStart returns the offset of the start of a 1-based line, or the length of the content past its last line.
+func (s *yamlSplicer) lineStart(line int) int {
+	if line > len(s.lines) {
+		return len(s.content)
+	}
+	return s.lines[max(line, 1)-1]
+}
+
+// lineEnd returns the offset of the line break ending a 1-based line, or the length of the content for its last line.
+func (s *yamlSplicer) lineEnd(line int) int {
+	if line < len(s.lines) {
+		return s.lines[line] - 1
+	}
+	if end := len(s.content); end > 0 && s.content[end-1] == '\n' {
+		return end - 1
+	}
+	return len(s.content)
+}
+
+// lineText returns the text of a 1-based line, without its line break.
+func (s *yamlSplicer) lineText(line int) string {
+	return string(s.content[s.lineStart(line):s.lineEnd(line)])
+}